@@ -0,0 +1,59 @@
+// The dns-plugin-reference command serves the reference DNS provider plugin,
+// that keeps the published records in memory, over the plugin protocol.
+// It is meant to be run as a sidecar of the GLBC controller, with the
+// controller DNS provider set to plugin, and as a starting point for plugins
+// targeting actual DNS services.
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	genericapiserver "k8s.io/apiserver/pkg/server"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/env"
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/plugin"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/plugin/reference"
+)
+
+var options struct {
+	// The address the plugin listens to
+	Address string
+}
+
+func init() {
+	flag.StringVar(&options.Address, "address", env.GetEnvString("DNS_PLUGIN_ADDRESS", ":8081"), "The address the plugin listens to")
+
+	opts := log.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	log.Logger = log.New(log.UseFlagOptions(&opts))
+}
+
+func main() {
+	ctx := genericapiserver.SetupSignalContext()
+
+	server := &http.Server{
+		Addr:    options.Address,
+		Handler: plugin.NewHandler(reference.NewBackend()),
+	}
+
+	go func() {
+		<-ctx.Done()
+		log.Logger.Info("Stopping DNS plugin")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Logger.Info("Started serving DNS plugin", "address", options.Address, "version", plugin.APIVersion)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Logger.Error(err, "Serving DNS plugin failed")
+		os.Exit(1)
+	}
+}
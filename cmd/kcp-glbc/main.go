@@ -30,6 +30,7 @@ import (
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 
@@ -37,12 +38,17 @@ import (
 	"github.com/kcp-dev/kcp/pkg/apis/tenancy/v1alpha1/helper"
 	conditionsutil "github.com/kcp-dev/kcp/pkg/apis/third_party/conditions/util/conditions"
 	kcp "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	kcpinformer "github.com/kcp-dev/kcp/pkg/client/informers/externalversions"
+	workloadlisters "github.com/kcp-dev/kcp/pkg/client/listers/workload/v1alpha1"
 	"github.com/kcp-dev/logicalcluster/v2"
 
 	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 	kuadrantv1 "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/clientset/versioned"
 	kuadrantinformer "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/informers/externalversions"
 	"github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/embedded"
 	"github.com/kuadrant/kcp-glbc/pkg/domains/domainverification"
 	"github.com/kuadrant/kcp-glbc/pkg/metrics"
 	"github.com/kuadrant/kcp-glbc/pkg/migration/deployment"
@@ -69,14 +75,45 @@ var options struct {
 	TLSProvider string
 	// The base domain
 	Domain string
-	// The DNS provider
+	// The comma separated list of DNS providers
 	DNSProvider string
+	// The address the embedded DNS server listens to
+	EmbeddedDNSAddress string
 	// The AWS Route53 region
 	Region string
 	// The port number of the metrics endpoint
 	MonitoringPort int
 	// The glbc exports to use
 	ExportName string
+	// The workspace of the SyncTargets
+	SyncTargetWorkspace string
+	// The default DNS routing policy
+	DNSRoutingPolicy string
+	// The period the DNS records are compared with the live zones at
+	DNSDriftCheckPeriod time.Duration
+	// What is done with the DNS records drifted from the live zones
+	DNSDriftMode string
+	// The owner the published DNS records are marked with
+	DNSOwnerID string
+	// The period the orphaned DNS records are garbage collected at
+	DNSGarbageCollectionPeriod time.Duration
+	// The default TTL of the DNS records, in seconds
+	DNSRecordTTL int
+	// The bounds of the TTL of the DNS records, in seconds
+	DNSRecordMinTTL int
+	DNSRecordMaxTTL int
+	// The comma separated list of the default TTLs of the DNS records per workspace
+	DNSRecordWorkspaceTTLs string
+	// The TTL of the DNS records while their targets are migrated, in seconds
+	DNSRecordMigrationTTL int
+	// The period the targets of the DNS records must be stable for, before their TTL is stepped back up
+	DNSRecordTTLStablePeriod time.Duration
+	// Whether the updates removing all the targets of the DNS records, or too many of them at once, are refused
+	DNSEndpointSafeguard bool
+	// The percentage of the targets of the DNS records an update can remove at once
+	DNSMaxEndpointDropPercent int
+	// The number of workers of the DNSRecord controllers
+	DNSRecordWorkers int
 }
 
 type APIExportClusterInformers struct {
@@ -92,10 +129,26 @@ func init() {
 	flagSet.StringVar(&options.GLBCWorkspace, "glbc-workspace", env.GetEnvString("GLBC_WORKSPACE", "root:kuadrant"), "The GLBC workspace")
 	flagSet.StringVar(&options.ExportName, "glbc-export", env.GetEnvString("GLBC_EXPORT", "glbc-root-kuadrant"), "comma separated list of glbc APIExport names")
 	flagSet.StringVar(&options.LogicalClusterTarget, "logical-cluster", env.GetEnvString("GLBC_LOGICAL_CLUSTER_TARGET", "*"), "set the target logical cluster")
+	flagSet.StringVar(&options.SyncTargetWorkspace, "sync-target-workspace", env.GetEnvString("GLBC_SYNC_TARGET_WORKSPACE", ""), "The workspace of the SyncTargets the traffic is scheduled to, used for geo-aware DNS, draining and readiness. SyncTargets are not watched when empty")
 	flagSet.StringVar(&options.TLSProvider, "glbc-tls-provider", env.GetEnvString("GLBC_TLS_PROVIDER", "glbc-ca"), "The TLS certificate issuer, one of [glbc-ca, le-staging, le-production]")
 	// DNS management options
 	flagSet.StringVar(&options.Domain, "domain", env.GetEnvString("GLBC_DOMAIN", "dev.hcpapps.net"), "The domain to use to expose ingresses")
-	flag.StringVar(&options.DNSProvider, "dns-provider", env.GetEnvString("GLBC_DNS_PROVIDER", "fake"), "The DNS provider being used [aws, fake]")
+	flag.StringVar(&options.DNSProvider, "dns-provider", env.GetEnvString("GLBC_DNS_PROVIDER", "embedded"), "Comma separated list of the DNS providers being used [aws, azure, gcp, rfc2136, plugin, embedded], fake being an alias of embedded")
+	flagSet.StringVar(&options.DNSRoutingPolicy, "dns-routing-policy", env.GetEnvString("GLBC_DNS_ROUTING_POLICY", traffic.RoutingPolicyGeo), "The default routing policy of the DNS records, one of [geo, latency, weighted]. It can be overridden per object with the kuadrant.dev/dns-routing-policy annotation")
+	flagSet.DurationVar(&options.DNSDriftCheckPeriod, "dns-drift-check-period", env.GetEnvDuration("GLBC_DNS_DRIFT_CHECK_PERIOD", 10*time.Minute), "The period the published DNS records are compared with the live zones at, to detect the records edited or deleted out of band (can be set to \"0\" to disable the drift check)")
+	flagSet.StringVar(&options.DNSDriftMode, "dns-drift-mode", env.GetEnvString("GLBC_DNS_DRIFT_MODE", dns.DriftModeRepair), "What is done with the DNS records drifted from the live zones, one of [repair, report]")
+	flagSet.StringVar(&options.DNSOwnerID, "dns-owner-id", env.GetEnvString("GLBC_DNS_OWNER_ID", aws.DefaultOwnerID), "The owner the published DNS records are marked with, that must be unique among the GLBC instances sharing the same zones")
+	flagSet.DurationVar(&options.DNSGarbageCollectionPeriod, "dns-gc-period", env.GetEnvDuration("GLBC_DNS_GC_PERIOD", 0), "The period the owned DNS records whose DNSRecord no longer exists are deleted at, requiring a --dns-owner-id other than the default one (\"0\" disables the garbage collection)")
+	flagSet.IntVar(&options.DNSRecordTTL, "dns-record-ttl", env.GetEnvInt("GLBC_DNS_RECORD_TTL", int(traffic.DefaultRecordTTL)), "The default TTL of the DNS records, in seconds. It can be overridden per object with the kuadrant.dev/dns-record-ttl annotation")
+	flagSet.IntVar(&options.DNSRecordMinTTL, "dns-record-min-ttl", env.GetEnvInt("GLBC_DNS_RECORD_MIN_TTL", int(traffic.DefaultMinRecordTTL)), "The minimum TTL of the DNS records, in seconds (can be set to \"0\" to disable the bound)")
+	flagSet.IntVar(&options.DNSRecordMaxTTL, "dns-record-max-ttl", env.GetEnvInt("GLBC_DNS_RECORD_MAX_TTL", int(traffic.DefaultMaxRecordTTL)), "The maximum TTL of the DNS records, in seconds (can be set to \"0\" to disable the bound)")
+	flagSet.StringVar(&options.DNSRecordWorkspaceTTLs, "dns-record-workspace-ttls", env.GetEnvString("GLBC_DNS_RECORD_WORKSPACE_TTLS", ""), "Comma separated list of workspace=seconds default TTLs of the DNS records of the workspaces")
+	flagSet.IntVar(&options.DNSRecordMigrationTTL, "dns-record-migration-ttl", env.GetEnvInt("GLBC_DNS_RECORD_MIGRATION_TTL", int(traffic.DefaultMigrationRecordTTL)), "The TTL of the DNS records while their targets are migrated, in seconds (can be set to \"0\" to disable the adaptive TTL)")
+	flagSet.DurationVar(&options.DNSRecordTTLStablePeriod, "dns-record-ttl-stable-period", env.GetEnvDuration("GLBC_DNS_RECORD_TTL_STABLE_PERIOD", traffic.DefaultTTLStablePeriod), "The period the targets of the DNS records must be stable for, before their TTL is stepped back up after a migration")
+	flagSet.BoolVar(&options.DNSEndpointSafeguard, "dns-endpoint-safeguard", env.GetEnvBool("GLBC_DNS_ENDPOINT_SAFEGUARD", true), "Refuse the updates of the DNS records removing all their targets, or more than --dns-max-endpoint-drop-percent of them at once. It can be bypassed per object with the kuadrant.dev/dns-allow-endpoint-drop annotation")
+	flagSet.IntVar(&options.DNSMaxEndpointDropPercent, "dns-max-endpoint-drop-percent", env.GetEnvInt("GLBC_DNS_MAX_ENDPOINT_DROP_PERCENT", traffic.DefaultMaxEndpointDropPercent), "The percentage of the targets of the DNS records an update can remove at once, when the endpoint safeguard is enabled")
+	flagSet.IntVar(&options.DNSRecordWorkers, "dns-record-workers", env.GetEnvInt("GLBC_DNS_RECORD_WORKERS", 10), "The number of DNSRecords reconciled concurrently per APIExport. The workers are blocked while the AWS changes are batched, so that enough of them are needed for the changes to be coalesced")
+	flagSet.StringVar(&options.EmbeddedDNSAddress, "embedded-dns-address", env.GetEnvString("GLBC_EMBEDDED_DNS_ADDRESS", embedded.DefaultAddress), "The address the embedded DNS server listens to, when the embedded DNS provider is used")
 
 	// // AWS Route53 options
 	flag.StringVar(&options.Region, "region", env.GetEnvString("AWS_REGION", "eu-central-1"), "the region we should target with AWS clients")
@@ -188,6 +241,59 @@ func main() {
 
 	exitOnError(err, "Failed to create TLS certificate controller")
 
+	var embeddedDNSProvider *embedded.Provider
+	if hasDNSProvider(options.DNSProvider, "embedded") {
+		embeddedDNSProvider, err = embedded.NewProvider(embedded.Config{
+			Address: options.EmbeddedDNSAddress,
+			Zone:    options.Domain,
+		})
+		exitOnError(err, "Failed to create embedded DNS server")
+		g.Go(embeddedDNSProvider.Start)
+	}
+
+	switch options.DNSRoutingPolicy {
+	case traffic.RoutingPolicyGeo, traffic.RoutingPolicyLatency, traffic.RoutingPolicyWeighted:
+	default:
+		exitOnError(fmt.Errorf("unsupported DNS routing policy %q", options.DNSRoutingPolicy), "Invalid DNS routing policy")
+	}
+
+	workspaceTTLs, err := traffic.ParseWorkspaceTTLs(options.DNSRecordWorkspaceTTLs)
+	exitOnError(err, "Invalid DNS record workspace TTLs")
+	dnsTTLPolicy := &traffic.TTLPolicy{
+		Default:    v1.TTL(options.DNSRecordTTL),
+		Workspaces: workspaceTTLs,
+		Min:        v1.TTL(options.DNSRecordMinTTL),
+		Max:        v1.TTL(options.DNSRecordMaxTTL),
+		// The TTL is lowered while the targets are migrated
+		Migration:    v1.TTL(options.DNSRecordMigrationTTL),
+		StablePeriod: options.DNSRecordTTLStablePeriod,
+	}
+	exitOnError(dnsTTLPolicy.Validate(), "Invalid DNS record TTL policy")
+	var dnsEndpointSafeguard *traffic.EndpointSafeguard
+	if options.DNSEndpointSafeguard {
+		dnsEndpointSafeguard = &traffic.EndpointSafeguard{MaxDropPercent: options.DNSMaxEndpointDropPercent}
+		exitOnError(dnsEndpointSafeguard.Validate(), "Invalid DNS endpoint safeguard")
+	}
+
+	// SyncTargets are optionally watched, to read the location of the clusters the traffic is scheduled to, and whether
+	// they are drained
+	var kcpInformerFactory kcpinformer.SharedInformerFactory
+	var syncTargetLister workloadlisters.SyncTargetLister
+	var syncTargetInformer cache.SharedIndexInformer
+	if options.SyncTargetWorkspace != "" {
+		kcpInformerFactory = kcpinformer.NewSharedInformerFactory(kcpClient.Cluster(logicalcluster.New(options.SyncTargetWorkspace)), resyncPeriod)
+		syncTargetLister = kcpInformerFactory.Workload().V1alpha1().SyncTargets().Lister()
+		syncTargetInformer = kcpInformerFactory.Workload().V1alpha1().SyncTargets().Informer()
+	}
+
+	// The records of the GLBC instances sharing the default owner ID cannot be told apart, so that the garbage collector
+	// of an instance would delete the records of the others
+	if options.DNSGarbageCollectionPeriod > 0 && options.DNSOwnerID == aws.DefaultOwnerID {
+		exitOnError(fmt.Errorf("the DNS garbage collection requires an explicit owner ID, unique among the GLBC instances sharing the same zones"), "Invalid DNS owner ID")
+	}
+	// The orphaned DNS records are garbage collected from the zones shared by the DNSRecord controllers of all the APIExports
+	dnsGarbageCollector := dns.NewGarbageCollector(options.DNSGarbageCollectionPeriod)
+
 	apiExportNames := strings.Split(options.ExportName, ",")
 	log.Logger.Info(fmt.Sprintf("Instantiating controllers for APIExports: %v", apiExportNames))
 
@@ -224,6 +330,25 @@ func main() {
 
 		dnsClient, domainVerifier := getDNSUtilities(os.Getenv("GLBC_HOST_RESOLVER"))
 
+		dnsRecordController, err := dns.NewController(&dns.ControllerConfig{
+			ControllerConfig: &reconciler.ControllerConfig{
+				NameSuffix: name,
+			},
+			DnsRecordClient:       kcpKuadrantClient,
+			SharedInformerFactory: kcpKuadrantInformerFactory,
+			DNSProvider:           options.DNSProvider,
+			EmbeddedDNSProvider:   embeddedDNSProvider,
+			DriftCheckPeriod:      options.DNSDriftCheckPeriod,
+			DriftMode:             options.DNSDriftMode,
+			DNSOwnerID:            options.DNSOwnerID,
+			GarbageCollector:      dnsGarbageCollector,
+		})
+		exitOnError(err, "Failed to create DNSRecord controller")
+		// The DNSRecord workers wait for the change batches they submit to, so more of them are run
+		controllers = append(controllers, sizedController{Controller: dnsRecordController, workers: options.DNSRecordWorkers})
+		// The traffic objects are published with the routing features supported by the DNS providers
+		dnsRoutingCapabilities := dnsRecordController.RoutingCapabilities()
+
 		routeController := route.NewController(&route.ControllerConfig{
 			ControllerConfig: &reconciler.ControllerConfig{
 				NameSuffix: name,
@@ -241,6 +366,12 @@ func main() {
 			CertProvider:                    certProvider,
 			HostResolver:                    dnsClient,
 			GLBCWorkspace:                   logicalcluster.New(options.GLBCWorkspace),
+			SyncTargetLister:                syncTargetLister,
+			SyncTargetInformer:              syncTargetInformer,
+			DNSRoutingPolicy:                options.DNSRoutingPolicy,
+			DNSTTLPolicy:                    dnsTTLPolicy,
+			DNSEndpointSafeguard:            dnsEndpointSafeguard,
+			DNSRoutingCapabilities:          dnsRoutingCapabilities,
 		})
 
 		controllers = append(controllers, routeController)
@@ -260,20 +391,15 @@ func main() {
 			CertProvider:             certProvider,
 			HostResolver:             dnsClient,
 			GLBCWorkspace:            logicalcluster.New(options.GLBCWorkspace),
+			SyncTargetLister:         syncTargetLister,
+			SyncTargetInformer:       syncTargetInformer,
+			DNSRoutingPolicy:         options.DNSRoutingPolicy,
+			DNSTTLPolicy:             dnsTTLPolicy,
+			DNSEndpointSafeguard:     dnsEndpointSafeguard,
+			DNSRoutingCapabilities:   dnsRoutingCapabilities,
 		})
 		controllers = append(controllers, ingressController)
 
-		dnsRecordController, err := dns.NewController(&dns.ControllerConfig{
-			ControllerConfig: &reconciler.ControllerConfig{
-				NameSuffix: name,
-			},
-			DnsRecordClient:       kcpKuadrantClient,
-			SharedInformerFactory: kcpKuadrantInformerFactory,
-			DNSProvider:           options.DNSProvider,
-		})
-		exitOnError(err, "Failed to create DNSRecord controller")
-		controllers = append(controllers, dnsRecordController)
-
 		domainVerificationController, err := domainverification.NewController(&domainverification.ControllerConfig{
 			ControllerConfig: &reconciler.ControllerConfig{
 				NameSuffix: name,
@@ -288,12 +414,17 @@ func main() {
 		exitOnError(err, "Failed to create DomainVerification controller")
 		controllers = append(controllers, domainVerificationController)
 
+		// The deletion of the migrated workloads is delayed by the TTL of the DNS records of their namespace
+		dnsRecordLister := kcpKuadrantInformerFactory.Kuadrant().V1().DNSRecords().Lister()
+
 		serviceController, err := service.NewController(&service.ControllerConfig{
 			ControllerConfig: &reconciler.ControllerConfig{
 				NameSuffix: name,
 			},
 			ServicesClient:        kcpKubeClient,
 			SharedInformerFactory: kcpKubeInformerFactory,
+			DNSTTLPolicy:          dnsTTLPolicy,
+			DNSRecordLister:       dnsRecordLister,
 		})
 		exitOnError(err, "Failed to create Service controller")
 
@@ -303,6 +434,8 @@ func main() {
 			},
 			DeploymentClient:      kcpKubeClient,
 			SharedInformerFactory: kcpKubeInformerFactory,
+			DNSTTLPolicy:          dnsTTLPolicy,
+			DNSRecordLister:       dnsRecordLister,
 		})
 		exitOnError(err, "Failed to create Deployment controller")
 
@@ -314,6 +447,8 @@ func main() {
 				},
 				SecretsClient:         kcpKubeClient,
 				SharedInformerFactory: kcpKubeInformerFactory,
+				DNSTTLPolicy:          dnsTTLPolicy,
+				DNSRecordLister:       dnsRecordLister,
 			})
 			exitOnError(err, "Failed to create Secret controller")
 
@@ -325,6 +460,7 @@ func main() {
 
 		apiExportClusterInformers = append(apiExportClusterInformers, *clusterInformers)
 	}
+	controllers = append(controllers, dnsGarbageCollector)
 
 	for _, clusterInformers := range apiExportClusterInformers {
 		clusterInformers.SharedInformerFactory.Start(ctx.Done())
@@ -341,6 +477,10 @@ func main() {
 	certificateInformerFactory.WaitForCacheSync(ctx.Done())
 	glbcKubeInformerFactory.Start(ctx.Done())
 	glbcKubeInformerFactory.WaitForCacheSync(ctx.Done())
+	if kcpInformerFactory != nil {
+		kcpInformerFactory.Start(ctx.Done())
+		kcpInformerFactory.WaitForCacheSync(ctx.Done())
+	}
 
 	for _, controller := range controllers {
 		start(gCtx, controller)
@@ -349,6 +489,11 @@ func main() {
 	g.Go(func() error {
 		// wait until the controllers have return before stopping serving metrics
 		controllersGroup.Wait()
+		if embeddedDNSProvider != nil {
+			if err := embeddedDNSProvider.Shutdown(); err != nil {
+				return err
+			}
+		}
 		return metricsServer.Shutdown()
 	})
 
@@ -359,6 +504,16 @@ type Controller interface {
 	Start(context.Context, int)
 }
 
+// sizedController is started with its own number of workers
+type sizedController struct {
+	Controller
+	workers int
+}
+
+func (c sizedController) Start(ctx context.Context, _ int) {
+	c.Controller.Start(ctx, c.workers)
+}
+
 func start(ctx context.Context, runnable Controller) {
 	controllersGroup.Add(1)
 	go func() {
@@ -413,3 +568,12 @@ func getDNSUtilities(hostResolverType string) (dns.HostResolver, domainverificat
 		return dns.NewDefaultHostResolver(), dns.NewVerifier(gonet.DefaultResolver)
 	}
 }
+
+func hasDNSProvider(providers, name string) bool {
+	for _, provider := range dns.ParseProviders(providers) {
+		if provider == name {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 // These tests cannot be run in parallel and should be updated to use testing.SetEnv if/when we update to go 1.17+ https://pkg.go.dev/testing#B.Setenv
@@ -92,14 +93,50 @@ func TestGetEnvString(t *testing.T) {
 	}
 }
 
+func TestGetEnvDuration(t *testing.T) {
+	setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	tests := []struct {
+		name string
+		key  string
+		want time.Duration
+	}{
+		{
+			name: "returns fallback",
+			key:  "GLBC_TST_NO_ENVAR",
+			want: time.Minute,
+		},
+		{
+			name: "returns env var value",
+			key:  "GLBC_TST_DURATION",
+			want: 5 * time.Minute,
+		},
+		{
+			name: "returns fallback for non duration env var value",
+			key:  "GLBC_TST_FOO_STR",
+			want: time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetEnvDuration(tt.key, time.Minute); got != tt.want {
+				t.Errorf("GetEnvDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func setupTestEnv(t *testing.T) {
 	_ = os.Setenv("GLBC_TST_FALSE_BOOL", "false")
 	_ = os.Setenv("GLBC_TST_NOT_BOOL", "notabool")
 	_ = os.Setenv("GLBC_TST_FOO_STR", "foo")
+	_ = os.Setenv("GLBC_TST_DURATION", "5m")
 }
 
 func teardownTestEnv(t *testing.T) {
 	_ = os.Unsetenv("GLBC_TST_FALSE_BOOL")
 	_ = os.Unsetenv("GLBC_TST_NOT_BOOL")
 	_ = os.Unsetenv("GLBC_TST_FOO_STR")
+	_ = os.Unsetenv("GLBC_TST_DURATION")
 }
@@ -143,6 +143,21 @@ type DNSRecordStatus struct {
 	// needs to retry the update for that specific zone.
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// failover is the status of the failover endpoints of the record, it is
+	// only set when the record has failover endpoints.
+	// +optional
+	Failover *DNSRecordFailoverStatus `json:"failover,omitempty"`
+}
+
+// DNSRecordFailoverStatus is the status of the failover endpoints of a record.
+type DNSRecordFailoverStatus struct {
+	// active is the failover role of the endpoints answering the queries,
+	// PRIMARY while the primary endpoints are healthy, SECONDARY otherwise.
+	Active string `json:"active"`
+
+	// lastTransitionTime is the last time the active role changed.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
 }
 
 // DNSZone is used to define a DNS hosted zone.
@@ -173,10 +188,21 @@ type DNSZone struct {
 type DNSZoneStatus struct {
 	// dnsZone is the zone where the record is published.
 	DNSZone DNSZone `json:"dnsZone"`
+	// provider is the name of the DNS provider the record is published with
+	// in the zone.
+	// +optional
+	Provider string `json:"provider,omitempty"`
+	// changeID is the identifier of the last change of the record submitted to
+	// the provider, when the provider propagates the changes to its name servers
+	// asynchronously.
+	// +optional
+	ChangeID string `json:"changeID,omitempty"`
 	// conditions are any conditions associated with the record in the zone.
 	//
 	// If publishing the record fails, the "Failed" condition will be set with a
-	// reason and message describing the cause of the failure.
+	// reason and message describing the cause of the failure. The "Propagated"
+	// condition is set once the record is served by the name servers of the
+	// provider.
 	Conditions []DNSZoneCondition `json:"conditions,omitempty"`
 	// endpoints are the last endpoints that were successfully published to the provider
 	//
@@ -191,6 +217,12 @@ type DNSZoneStatus struct {
 var (
 	// Failed means the record is not available within a zone.
 	DNSRecordFailedConditionType = "Failed"
+	// Propagated means the record is served by the name servers of the provider
+	// within a zone.
+	DNSRecordPropagatedConditionType = "Propagated"
+	// Drifted means the live zone is missing the record, or differs from it,
+	// as last checked.
+	DNSRecordDriftedConditionType = "Drifted"
 )
 
 // DNSZoneCondition is just the standard condition fields.
@@ -209,7 +241,7 @@ type DNSZoneCondition struct {
 }
 
 // DNSRecordType is a DNS resource record type.
-// +kubebuilder:validation:Enum=CNAME;A
+// +kubebuilder:validation:Enum=CNAME;A;AAAA
 type DNSRecordType string
 
 const (
@@ -218,6 +250,9 @@ const (
 
 	// ARecordType is an RFC 1035 A record.
 	ARecordType DNSRecordType = "A"
+
+	// AAAARecordType is an RFC 3596 AAAA record.
+	AAAARecordType DNSRecordType = "AAAA"
 )
 
 // +kubebuilder:object:root=true
@@ -236,9 +271,9 @@ func (endpoint *Endpoint) SetProviderSpecific(name, value string) {
 		endpoint.ProviderSpecific = ProviderSpecific{}
 	}
 
-	for _, pair := range endpoint.ProviderSpecific {
-		if pair.Name == name {
-			property = &pair
+	for i := range endpoint.ProviderSpecific {
+		if endpoint.ProviderSpecific[i].Name == name {
+			property = &endpoint.ProviderSpecific[i]
 		}
 	}
 
@@ -34,3 +34,15 @@ func TestGetToken(t *testing.T) {
 		})
 	}
 }
+
+func TestSetProviderSpecific(t *testing.T) {
+	endpoint := &Endpoint{}
+	endpoint.SetProviderSpecific("aws/weight", "120")
+	endpoint.SetProviderSpecific("aws/weight", "90")
+	if len(endpoint.ProviderSpecific) != 1 {
+		t.Fatalf("expected a single provider specific property but got %v", endpoint.ProviderSpecific)
+	}
+	if weight, _ := endpoint.GetProviderSpecific("aws/weight"); weight != "90" {
+		t.Errorf("expected the provider specific property to be updated to '90' but got '%s'", weight)
+	}
+}
@@ -0,0 +1,204 @@
+package aws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+const (
+	// DefaultChangeBatchWindow is the default period the changes to a hosted zone are coalesced over
+	DefaultChangeBatchWindow = time.Second
+
+	// The limits of a change batch, see https://docs.aws.amazon.com/Route53/latest/DeveloperGuide/DNSLimitations.html#limits-api-requests-changeresourcerecordsets.
+	// The resource records and the characters of their values of the UPSERT changes count twice.
+	maxChangeBatchRecords    = 1000
+	maxChangeBatchCharacters = 32000
+)
+
+// route53ChangeBatcher is shared by all the providers, so that the changes made to a hosted zone by the DNSRecord
+// controllers of all the APIExports are coalesced into the same change batches
+var route53ChangeBatcher = newChangeBatcher(DefaultChangeBatchWindow)
+
+// changeSubmitter submits a batch of changes to a hosted zone
+type changeSubmitter func(zoneID string, changes []*route53.Change) (*route53.ChangeInfo, error)
+
+// changeBatcher coalesces the changes submitted to the same hosted zone over a window of time, into a single change
+// batch, to reduce the number of requests made to Route53. The changes of a request are always submitted in the same
+// batch, and the callers are blocked until the batch their changes belong to has been submitted.
+type changeBatcher struct {
+	lock    sync.Mutex
+	window  time.Duration
+	pending map[string]*changeBatch
+}
+
+type changeBatch struct {
+	zoneID     string
+	requests   []*changeRequest
+	records    int
+	characters int
+	keys       map[string]bool
+}
+
+type changeRequest struct {
+	changes    []*route53.Change
+	records    int
+	characters int
+	keys       map[string]bool
+	submit     changeSubmitter
+	done       chan changeResult
+}
+
+type changeResult struct {
+	info *route53.ChangeInfo
+	err  error
+}
+
+func newChangeBatcher(window time.Duration) *changeBatcher {
+	return &changeBatcher{
+		window:  window,
+		pending: map[string]*changeBatch{},
+	}
+}
+
+// SetWindow sets the period the changes are coalesced over, batching being disabled when it is zero
+func (b *changeBatcher) SetWindow(window time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.window = window
+}
+
+// Submit adds the changes to the pending batch of the hosted zone, and returns the outcome of the batch once it has
+// been submitted. A batch is submitted with the submitter of its first request, all the Route53 clients being
+// authorized for the same hosted zones. The changes are submitted right away when batching is disabled.
+func (b *changeBatcher) Submit(zoneID string, changes []*route53.Change, submit changeSubmitter) (*route53.ChangeInfo, error) {
+	b.lock.Lock()
+	if b.window <= 0 {
+		b.lock.Unlock()
+		return submit(zoneID, changes)
+	}
+
+	request := newChangeRequest(changes, submit)
+
+	batch := b.pending[zoneID]
+	if batch != nil && !batch.accepts(request) {
+		// The pending batch is submitted right away to make room for the request
+		delete(b.pending, zoneID)
+		go b.flush(batch)
+		batch = nil
+	}
+	if batch == nil {
+		batch = &changeBatch{zoneID: zoneID, keys: map[string]bool{}}
+		b.pending[zoneID] = batch
+		time.AfterFunc(b.window, func() {
+			b.lock.Lock()
+			if b.pending[zoneID] != batch {
+				// The batch has already been submitted
+				b.lock.Unlock()
+				return
+			}
+			delete(b.pending, zoneID)
+			b.lock.Unlock()
+			b.flush(batch)
+		})
+	}
+	batch.add(request)
+	b.lock.Unlock()
+
+	result := <-request.done
+	return result.info, result.err
+}
+
+// flush submits the batch, and reports the outcome to each of its requests. Route53 applies a change batch as a
+// whole, so a single invalid change fails all the requests in the batch. The requests are then submitted separately,
+// so that the failure is only reported to the request it originates from, unless Route53 is unavailable.
+func (b *changeBatcher) flush(batch *changeBatch) {
+	changeBatchRequests.Observe(float64(len(batch.requests)))
+
+	var changes []*route53.Change
+	for _, request := range batch.requests {
+		changes = append(changes, request.changes...)
+	}
+	info, err := batch.requests[0].submit(batch.zoneID, changes)
+	if err == nil || len(batch.requests) == 1 || err == ErrCircuitOpen || isUnavailableError(err) {
+		for _, request := range batch.requests {
+			request.done <- changeResult{info: info, err: err}
+		}
+		return
+	}
+
+	for _, request := range batch.requests {
+		info, err := request.submit(batch.zoneID, request.changes)
+		request.done <- changeResult{info: info, err: err}
+	}
+}
+
+func newChangeRequest(changes []*route53.Change, submit changeSubmitter) *changeRequest {
+	request := &changeRequest{
+		changes: changes,
+		keys:    map[string]bool{},
+		submit:  submit,
+		done:    make(chan changeResult, 1),
+	}
+	for _, change := range changes {
+		records, characters := changeSize(change)
+		request.records += records
+		request.characters += characters
+		request.keys[changeKey(change)] = true
+	}
+	return request
+}
+
+// accepts returns whether the request can be added to the batch, i.e. the batch stays within the limits of Route53,
+// and none of the record sets of the request is already changed in the batch, as a record set can only be changed
+// once in a change batch. A request is always accepted by an empty batch, even when it exceeds the limits on its own,
+// so that it fails with the error returned by Route53.
+func (b *changeBatch) accepts(request *changeRequest) bool {
+	if len(b.requests) == 0 {
+		return true
+	}
+	if b.records+request.records > maxChangeBatchRecords || b.characters+request.characters > maxChangeBatchCharacters {
+		return false
+	}
+	for key := range request.keys {
+		if b.keys[key] {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *changeBatch) add(request *changeRequest) {
+	b.requests = append(b.requests, request)
+	b.records += request.records
+	b.characters += request.characters
+	for key := range request.keys {
+		b.keys[key] = true
+	}
+}
+
+// changeSize returns the number of resource records, and the number of characters of their values, the change counts
+// for in the limits of a change batch. An alias record counts as a single resource record.
+func changeSize(change *route53.Change) (int, int) {
+	records, characters := 1, 0
+	if recordSet := change.ResourceRecordSet; recordSet != nil && len(recordSet.ResourceRecords) > 0 {
+		records = len(recordSet.ResourceRecords)
+		for _, record := range recordSet.ResourceRecords {
+			characters += len(aws.StringValue(record.Value))
+		}
+	}
+	if aws.StringValue(change.Action) == route53.ChangeActionUpsert {
+		records, characters = 2*records, 2*characters
+	}
+	return records, characters
+}
+
+// changeKey returns the key identifying the record set of the change
+func changeKey(change *route53.Change) string {
+	if change.ResourceRecordSet == nil {
+		return ""
+	}
+	return recordSetKey(change.ResourceRecordSet)
+}
@@ -0,0 +1,189 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/onsi/gomega"
+)
+
+type recordingSubmitter struct {
+	lock    sync.Mutex
+	batches map[string][][]*route53.Change
+	invalid string
+}
+
+func (s *recordingSubmitter) submit(zoneID string, changes []*route53.Change) (*route53.ChangeInfo, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.batches == nil {
+		s.batches = map[string][][]*route53.Change{}
+	}
+	s.batches[zoneID] = append(s.batches[zoneID], changes)
+	for _, change := range changes {
+		if aws.StringValue(change.ResourceRecordSet.Name) == s.invalid {
+			return nil, errors.New("InvalidChangeBatch")
+		}
+	}
+	return &route53.ChangeInfo{Id: aws.String(zoneID)}, nil
+}
+
+func (s *recordingSubmitter) batchSizes(zoneID string) []int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var sizes []int
+	for _, batch := range s.batches[zoneID] {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func upsertChange(name string, values ...string) *route53.Change {
+	var records []*route53.ResourceRecord
+	for _, value := range values {
+		records = append(records, &route53.ResourceRecord{Value: aws.String(value)})
+	}
+	return &route53.Change{
+		Action: aws.String(route53.ChangeActionUpsert),
+		ResourceRecordSet: &route53.ResourceRecordSet{
+			Name:            aws.String(name),
+			Type:            aws.String(route53.RRTypeA),
+			ResourceRecords: records,
+		},
+	}
+}
+
+// submitAll submits the changes concurrently, and returns the errors in the order of the changes
+func submitAll(batcher *changeBatcher, submit changeSubmitter, zoneID string, changes ...*route53.Change) []error {
+	errs := make([]error, len(changes))
+	var wg sync.WaitGroup
+	for i := range changes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = batcher.Submit(zoneID, []*route53.Change{changes[i]}, submit)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestChangeBatcherCoalescesChanges(t *testing.T) {
+	g := gomega.NewWithT(t)
+	submitter := &recordingSubmitter{}
+	batcher := newChangeBatcher(50 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for _, zoneID := range []string{"Z1", "Z2"} {
+		wg.Add(1)
+		go func(zoneID string) {
+			defer wg.Done()
+			errs := submitAll(batcher, submitter.submit, zoneID, upsertChange("a.example.com", "192.0.2.1"), upsertChange("b.example.com", "192.0.2.2"), upsertChange("c.example.com", "192.0.2.3"))
+			g.Expect(errs).To(gomega.Equal([]error{nil, nil, nil}))
+		}(zoneID)
+	}
+	wg.Wait()
+
+	g.Expect(submitter.batchSizes("Z1")).To(gomega.Equal([]int{3}))
+	g.Expect(submitter.batchSizes("Z2")).To(gomega.Equal([]int{3}))
+}
+
+func TestChangeBatcherSharedByProviders(t *testing.T) {
+	g := gomega.NewWithT(t)
+	route53ChangeBatcher.SetWindow(50 * time.Millisecond)
+	defer route53ChangeBatcher.SetWindow(DefaultChangeBatchWindow)
+
+	// The changes submitted concurrently by the providers of several APIExports are coalesced into a single batch
+	submitters := []*recordingSubmitter{{}, {}, {}}
+	errs := make([]error, len(submitters))
+	var wg sync.WaitGroup
+	for i, submitter := range submitters {
+		wg.Add(1)
+		go func(i int, submitter *recordingSubmitter) {
+			defer wg.Done()
+			name := fmt.Sprintf("app%d.example.com", i)
+			_, errs[i] = route53ChangeBatcher.Submit("Z1", []*route53.Change{upsertChange(name, "192.0.2.1")}, submitter.submit)
+		}(i, submitter)
+	}
+	wg.Wait()
+
+	g.Expect(errs).To(gomega.Equal([]error{nil, nil, nil}))
+	var sizes []int
+	for _, submitter := range submitters {
+		sizes = append(sizes, submitter.batchSizes("Z1")...)
+	}
+	g.Expect(sizes).To(gomega.Equal([]int{3}))
+}
+
+func TestChangeBatcherSplitsConflictingChanges(t *testing.T) {
+	g := gomega.NewWithT(t)
+	submitter := &recordingSubmitter{}
+	batcher := newChangeBatcher(50 * time.Millisecond)
+
+	errs := submitAll(batcher, submitter.submit, "Z1", upsertChange("a.example.com", "192.0.2.1"), upsertChange("a.example.com", "192.0.2.2"))
+
+	g.Expect(errs).To(gomega.Equal([]error{nil, nil}))
+	g.Expect(submitter.batchSizes("Z1")).To(gomega.Equal([]int{1, 1}))
+}
+
+func TestChangeBatcherStaysWithinLimits(t *testing.T) {
+	g := gomega.NewWithT(t)
+	submitter := &recordingSubmitter{}
+	batcher := newChangeBatcher(50 * time.Millisecond)
+
+	// Each change counts for 2 * 300 resource records and 2 * 300 * 20 characters of values
+	value := strings.Repeat("x", 20)
+	values := make([]string, 300)
+	for i := range values {
+		values[i] = value
+	}
+	errs := submitAll(batcher, submitter.submit, "Z1", upsertChange("a.example.com", values...), upsertChange("b.example.com", values...))
+
+	g.Expect(errs).To(gomega.Equal([]error{nil, nil}))
+	g.Expect(submitter.batchSizes("Z1")).To(gomega.Equal([]int{1, 1}))
+}
+
+func TestChangeBatcherReportsFailureToOriginatingRequest(t *testing.T) {
+	g := gomega.NewWithT(t)
+	submitter := &recordingSubmitter{invalid: "b.example.com"}
+	batcher := newChangeBatcher(50 * time.Millisecond)
+
+	errs := submitAll(batcher, submitter.submit, "Z1", upsertChange("a.example.com", "192.0.2.1"), upsertChange("b.example.com", "192.0.2.2"))
+
+	g.Expect(errs[0]).NotTo(gomega.HaveOccurred())
+	g.Expect(errs[1]).To(gomega.HaveOccurred())
+	// The batch is retried as separate requests once it has failed
+	g.Expect(submitter.batchSizes("Z1")).To(gomega.Equal([]int{2, 1, 1}))
+}
+
+func TestChangeBatcherDisabled(t *testing.T) {
+	g := gomega.NewWithT(t)
+	submitter := &recordingSubmitter{}
+	batcher := newChangeBatcher(0)
+
+	info, err := batcher.Submit("Z1", []*route53.Change{upsertChange("a.example.com", "192.0.2.1")}, submitter.submit)
+
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(aws.StringValue(info.Id)).To(gomega.Equal("Z1"))
+	g.Expect(submitter.batchSizes("Z1")).To(gomega.Equal([]int{1}))
+}
+
+func TestChangeSize(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	records, characters := changeSize(upsertChange("a.example.com", "192.0.2.1", "192.0.2.20"))
+	g.Expect(records).To(gomega.Equal(4))
+	g.Expect(characters).To(gomega.Equal(2 * (9 + 10)))
+
+	deletion := upsertChange("a.example.com", "192.0.2.1")
+	deletion.Action = aws.String(route53.ChangeActionDelete)
+	records, characters = changeSize(deletion)
+	g.Expect(records).To(gomega.Equal(1))
+	g.Expect(characters).To(gomega.Equal(9))
+}
@@ -15,6 +15,7 @@ limitations under the License.
 package aws
 
 import (
+	"context"
 	"strconv"
 	"time"
 
@@ -26,9 +27,10 @@ import (
 
 type InstrumentedRoute53 struct {
 	route53 *route53.Route53
+	quota   *quota
 }
 
-func observe(operation string, f func() error) {
+func observe(operation string, f func() error) error {
 	start := time.Now()
 	route53RequestCount.WithLabelValues(operation).Inc()
 	defer route53RequestCount.WithLabelValues(operation).Dec()
@@ -49,26 +51,51 @@ func observe(operation string, f func() error) {
 	}
 	route53RequestDuration.WithLabelValues(operation, code).Observe(duration)
 	route53RequestTotal.WithLabelValues(operation, code).Inc()
+	return err
 }
 
 func (c *InstrumentedRoute53) ListHostedZones(input *route53.ListHostedZonesInput) (output *route53.ListHostedZonesOutput, err error) {
-	observe("ListHostedZones", func() error {
+	err = c.quota.call(context.Background(), "ListHostedZones", func() error {
 		output, err = c.route53.ListHostedZones(input)
 		return err
 	})
 	return
 }
 
+func (c *InstrumentedRoute53) GetHostedZone(input *route53.GetHostedZoneInput) (output *route53.GetHostedZoneOutput, err error) {
+	err = c.quota.call(context.Background(), "GetHostedZone", func() error {
+		output, err = c.route53.GetHostedZone(input)
+		return err
+	})
+	return
+}
+
 func (c *InstrumentedRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (output *route53.ChangeResourceRecordSetsOutput, err error) {
-	observe("ChangeResourceRecordSets", func() error {
+	err = c.quota.call(context.Background(), "ChangeResourceRecordSets", func() error {
 		output, err = c.route53.ChangeResourceRecordSets(input)
 		return err
 	})
 	return
 }
 
+func (c *InstrumentedRoute53) ListResourceRecordSetsWithContext(ctx aws.Context, input *route53.ListResourceRecordSetsInput, opts ...request.Option) (output *route53.ListResourceRecordSetsOutput, err error) {
+	err = c.quota.call(ctx, "ListResourceRecordSetsWithContext", func() error {
+		output, err = c.route53.ListResourceRecordSetsWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *InstrumentedRoute53) GetChangeWithContext(ctx aws.Context, input *route53.GetChangeInput, opts ...request.Option) (output *route53.GetChangeOutput, err error) {
+	err = c.quota.call(ctx, "GetChangeWithContext", func() error {
+		output, err = c.route53.GetChangeWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
 func (c *InstrumentedRoute53) CreateHealthCheck(input *route53.CreateHealthCheckInput) (output *route53.CreateHealthCheckOutput, err error) {
-	observe("CreateHealthCheck", func() error {
+	err = c.quota.call(context.Background(), "CreateHealthCheck", func() error {
 		output, err = c.route53.CreateHealthCheck(input)
 		return err
 	})
@@ -76,15 +103,23 @@ func (c *InstrumentedRoute53) CreateHealthCheck(input *route53.CreateHealthCheck
 }
 
 func (c *InstrumentedRoute53) GetHealthCheckWithContext(ctx aws.Context, input *route53.GetHealthCheckInput, opts ...request.Option) (output *route53.GetHealthCheckOutput, err error) {
-	observe("GetHealthCheckWithContext", func() error {
+	err = c.quota.call(ctx, "GetHealthCheckWithContext", func() error {
 		output, err = c.route53.GetHealthCheckWithContext(ctx, input, opts...)
 		return err
 	})
 	return
 }
 
+func (c *InstrumentedRoute53) GetHealthCheckStatusWithContext(ctx aws.Context, input *route53.GetHealthCheckStatusInput, opts ...request.Option) (output *route53.GetHealthCheckStatusOutput, err error) {
+	err = c.quota.call(ctx, "GetHealthCheckStatusWithContext", func() error {
+		output, err = c.route53.GetHealthCheckStatusWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
 func (c *InstrumentedRoute53) UpdateHealthCheckWithContext(ctx aws.Context, input *route53.UpdateHealthCheckInput, opts ...request.Option) (output *route53.UpdateHealthCheckOutput, err error) {
-	observe("UpdateHealthCheckWithContext", func() error {
+	err = c.quota.call(ctx, "UpdateHealthCheckWithContext", func() error {
 		output, err = c.route53.UpdateHealthCheckWithContext(ctx, input, opts...)
 		return err
 	})
@@ -92,7 +127,7 @@ func (c *InstrumentedRoute53) UpdateHealthCheckWithContext(ctx aws.Context, inpu
 }
 
 func (c *InstrumentedRoute53) DeleteHealthCheckWithContext(ctx aws.Context, input *route53.DeleteHealthCheckInput, opts ...request.Option) (output *route53.DeleteHealthCheckOutput, err error) {
-	observe("DeleteHealthCheckWithContext", func() error {
+	err = c.quota.call(ctx, "DeleteHealthCheckWithContext", func() error {
 		output, err = c.route53.DeleteHealthCheckWithContext(ctx, input, opts...)
 		return err
 	})
@@ -100,7 +135,7 @@ func (c *InstrumentedRoute53) DeleteHealthCheckWithContext(ctx aws.Context, inpu
 }
 
 func (c *InstrumentedRoute53) ChangeTagsForResourceWithContext(ctx aws.Context, input *route53.ChangeTagsForResourceInput, opts ...request.Option) (output *route53.ChangeTagsForResourceOutput, err error) {
-	observe("ChangeTagsForResourceWithContext", func() error {
+	err = c.quota.call(ctx, "ChangeTagsForResourceWithContext", func() error {
 		output, err = c.route53.ChangeTagsForResourceWithContext(ctx, input, opts...)
 		return err
 	})
@@ -4,15 +4,20 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"golang.org/x/time/rate"
 
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
@@ -28,12 +33,25 @@ const (
 	ProviderSpecificFailover             = "aws/failover"
 	ProviderSpecificMultiValueAnswer     = "aws/multi-value-answer"
 	ProviderSpecificHealthCheckID        = "aws/health-check-id"
+	// ProviderSpecificAddressHealthCheckIDs holds the IDs of the health checks of each address of the record sets with
+	// several addresses, that are aggregated by the health check of the record set
+	ProviderSpecificAddressHealthCheckIDs = "aws/address-health-check-ids"
+	// ProviderSpecificGeolocationContinentCode is the continent code of the geolocation routing policy, or * for the
+	// default record, that answers the queries from the locations without a specific record
+	ProviderSpecificGeolocationContinentCode = "aws/geolocation-continent-code"
+
+	// FailoverPrimary is the failover role of the records answering the queries while their health check is healthy
+	FailoverPrimary = route53.ResourceRecordSetFailoverPrimary
+	// FailoverSecondary is the failover role of the records answering the queries when the primary records are unhealthy
+	FailoverSecondary = route53.ResourceRecordSetFailoverSecondary
 )
 
 // Inspired by https://github.com/openshift/cluster-ingress-operator/blob/master/pkg/dns/aws/dns.go
 type Provider struct {
 	route53               *InstrumentedRoute53
+	tagging               *resourcegroupstaggingapi.ResourceGroupsTaggingAPI
 	healthCheckReconciler *Route53HealthCheckReconciler
+	batcher               *changeBatcher
 	config                Config
 	logger                logr.Logger
 }
@@ -42,6 +60,15 @@ type Provider struct {
 type Config struct {
 	// Region is the AWS region ELBs are created in.
 	Region string
+	// ChangeBatchWindow is the period the changes to a hosted zone are coalesced over, into a single change batch
+	// shared by all the providers. Batching is disabled when it is zero.
+	ChangeBatchWindow time.Duration
+	// RequestsPerSecond is the rate the requests to Route53 are limited to, shared by all the providers. The default
+	// rate is used when it is zero.
+	RequestsPerSecond float64
+	// OwnerID is the owner the published record sets are marked with, so that they are not mistaken for the records of
+	// another GLBC instance, or of another tool, managing the same hosted zones. DefaultOwnerID is used when it is empty.
+	OwnerID string
 }
 
 func NewProvider(config Config) (*Provider, error) {
@@ -50,6 +77,11 @@ func NewProvider(config Config) (*Provider, error) {
 		region = config.Region
 	}
 
+	if config.RequestsPerSecond > 0 {
+		route53Quota.limiter.SetLimit(rate.Limit(config.RequestsPerSecond))
+	}
+	route53ChangeBatcher.SetWindow(config.ChangeBatchWindow)
+
 	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
 	if err != nil {
 		return nil, fmt.Errorf("couldn't create AWS client session: %v", err)
@@ -78,7 +110,10 @@ func NewProvider(config Config) (*Provider, error) {
 	}
 
 	p := &Provider{
-		route53: &InstrumentedRoute53{route53.New(sess, r53Config)},
+		route53: &InstrumentedRoute53{route53: route53.New(sess, r53Config), quota: route53Quota},
+		// The hosted zones are tagged in the region of the Route 53 API
+		tagging: resourcegroupstaggingapi.New(sess, aws.NewConfig().WithRegion(aws.StringValue(r53Config.Region))),
+		batcher: route53ChangeBatcher,
 		config:  config,
 		logger:  log.Logger.WithName("aws-route53").WithValues("region", r53Config.Region),
 	}
@@ -111,11 +146,28 @@ const (
 )
 
 func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	_, err := p.change(record, zone, upsertAction)
+	return err
+}
+
+// EnsureChange upserts the record, and returns the ID of the Route53 change, that is propagated to the Route53 name
+// servers asynchronously.
+func (p *Provider) EnsureChange(record *v1.DNSRecord, zone v1.DNSZone) (string, error) {
 	return p.change(record, zone, upsertAction)
 }
 
+// ChangePropagated returns whether the change is INSYNC, i.e. it has been propagated to all the Route53 name servers.
+func (p *Provider) ChangePropagated(ctx context.Context, changeID string) (bool, error) {
+	output, err := p.route53.GetChangeWithContext(ctx, &route53.GetChangeInput{Id: aws.String(changeID)})
+	if err != nil {
+		return false, fmt.Errorf("couldn't get change %s: %w", changeID, err)
+	}
+	return aws.StringValue(output.ChangeInfo.Status) == route53.ChangeStatusInsync, nil
+}
+
 func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
-	return p.change(record, zone, deleteAction)
+	_, err := p.change(record, zone, deleteAction)
+	return err
 }
 
 func (p *Provider) ReconcileHealthCheck(ctx context.Context, hc v1.HealthCheck, endpoint *v1.Endpoint) error {
@@ -127,12 +179,110 @@ func (p *Provider) DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint)
 	return p.healthCheckReconciler.deleteHealthCheck(ctx, endpoint)
 }
 
-// change will perform an action on a record.
-func (p *Provider) change(record *v1.DNSRecord, zone v1.DNSZone, action action) error {
+func (p *Provider) HealthCheckHealthy(ctx context.Context, endpoint *v1.Endpoint) (bool, error) {
+	return p.healthCheckReconciler.healthy(ctx, endpoint)
+}
+
+// SupportsWeightedCNAMEs returns true, as Route53 resolves weighted CNAME records of the same name.
+func (p *Provider) SupportsWeightedCNAMEs() bool {
+	return true
+}
+
+// SupportsGeolocationRouting returns true, as Route53 routes the records with a geolocation continent code.
+func (p *Provider) SupportsGeolocationRouting() bool {
+	return true
+}
+
+// SupportsLatencyRouting returns true, as Route53 routes the records with a region by latency.
+func (p *Provider) SupportsLatencyRouting() bool {
+	return true
+}
+
+// SupportsFailoverRouting returns true, as Route53 routes the records with a failover role by the health of the
+// primary record sets.
+func (p *Provider) SupportsFailoverRouting() bool {
+	return true
+}
+
+// IsLatencyRegion returns whether the region is one of the regions latency records can be routed to.
+func IsLatencyRegion(region string) bool {
+	for _, r := range route53.ResourceRecordSetRegion_Values() {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// ZoneDomains returns the domain name of the hosted zones matching the zone, i.e. the hosted zone with its ID, or the
+// hosted zones with all its tags, indexed by hosted zone ID.
+func (p *Provider) ZoneDomains(zone v1.DNSZone) (map[string]string, error) {
+	var ids []string
+	if zone.ID != "" {
+		ids = append(ids, zone.ID)
+	} else if len(zone.Tags) > 0 {
+		tagged, err := p.zoneIDsForTags(zone.Tags)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, tagged...)
+	}
+
+	domains := make(map[string]string, len(ids))
+	for _, id := range ids {
+		output, err := p.route53.GetHostedZone(&route53.GetHostedZoneInput{Id: aws.String(id)})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get hosted zone %s: %v", id, err)
+		}
+		domains[id] = strings.TrimSuffix(aws.StringValue(output.HostedZone.Name), ".")
+	}
+	return domains, nil
+}
+
+// zoneIDsForTags returns the IDs of the hosted zones with all the given tags.
+func (p *Provider) zoneIDsForTags(tags map[string]string) ([]string, error) {
+	input := &resourcegroupstaggingapi.GetResourcesInput{
+		ResourceTypeFilters: []*string{aws.String("route53:hostedzone")},
+	}
+	for key, value := range tags {
+		input.TagFilters = append(input.TagFilters, &resourcegroupstaggingapi.TagFilter{
+			Key:    aws.String(key),
+			Values: []*string{aws.String(value)},
+		})
+	}
+
+	var ids []string
+	err := p.tagging.GetResourcesPages(input, func(output *resourcegroupstaggingapi.GetResourcesOutput, _ bool) bool {
+		for _, resource := range output.ResourceTagMappingList {
+			if id, ok := zoneIDFromARN(aws.StringValue(resource.ResourceARN)); ok {
+				ids = append(ids, id)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get hosted zones with tags %v: %v", tags, err)
+	}
+	return ids, nil
+}
+
+// zoneIDFromARN returns the ID of the hosted zone with the given ARN, e.g. Z123 for arn:aws:route53:::hostedzone/Z123.
+func zoneIDFromARN(arn string) (string, bool) {
+	const prefix = "hostedzone/"
+	i := strings.LastIndex(arn, prefix)
+	if i < 0 || i+len(prefix) == len(arn) {
+		return "", false
+	}
+	return arn[i+len(prefix):], true
+}
+
+// change will perform an action on a record, and returns the ID of the change, or an empty string when the record
+// is up to date.
+func (p *Provider) change(record *v1.DNSRecord, zone v1.DNSZone, action action) (string, error) {
 	// Configure records.
-	err := p.updateRecord(record, zone.ID, string(action))
+	info, err := p.updateRecord(record, zone.ID, string(action))
 	if err != nil {
-		return fmt.Errorf("failed to update record in zone %s: %v", zone.ID, err)
+		return "", fmt.Errorf("failed to update record in zone %s: %w", zone.ID, err)
 	}
 	switch action {
 	case upsertAction:
@@ -140,58 +290,112 @@ func (p *Provider) change(record *v1.DNSRecord, zone v1.DNSZone, action action)
 	case deleteAction:
 		p.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
 	}
-	return nil
+	if info == nil {
+		return "", nil
+	}
+	return aws.StringValue(info.Id), nil
 }
 
-func (p *Provider) updateRecord(record *v1.DNSRecord, zoneID, action string) error {
-	input := route53.ChangeResourceRecordSetsInput{HostedZoneId: aws.String(zoneID)}
-
+func (p *Provider) updateRecord(record *v1.DNSRecord, zoneID, action string) (*route53.ChangeInfo, error) {
 	expectedEndpointsMap := make(map[string]struct{})
 	var changes []*route53.Change
+	aliases := aliasNames(record.Spec.Endpoints)
 	for _, endpoint := range record.Spec.Endpoints {
-		expectedEndpointsMap[endpoint.SetID()] = struct{}{}
-		change, err := p.changeForEndpoint(endpoint, action)
+		expectedEndpointsMap[endpointKey(endpoint)] = struct{}{}
+		change, err := p.changeForEndpoint(endpoint, action, aliases[endpoint.DNSName])
 		if err != nil {
-			return err
+			return nil, err
 		}
 		changes = append(changes, change)
 	}
 
-	// Delete any previously published records that are no longer present in record.Spec.Endpoints
+	// Delete any previously published records that are no longer present in record.Spec.Endpoints.
+	// The deletions come first in the batch, so that A records can be replaced with a CNAME record for the same name.
 	if action != string(deleteAction) {
+		var deletions []*route53.Change
 		lastPublishedEndpoints, err := p.endpointsFromZoneStatus(record, zoneID)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		lastPublishedAliases := aliasNames(lastPublishedEndpoints)
 		for _, endpoint := range lastPublishedEndpoints {
-			if _, found := expectedEndpointsMap[endpoint.SetID()]; !found {
-				change, err := p.changeForEndpoint(endpoint, string(deleteAction))
+			if _, found := expectedEndpointsMap[endpointKey(endpoint)]; !found {
+				change, err := p.changeForEndpoint(endpoint, string(deleteAction), lastPublishedAliases[endpoint.DNSName])
 				if err != nil {
-					return err
+					return nil, err
 				}
-				changes = append(changes, change)
+				deletions = append(deletions, change)
 			}
 		}
+		changes = append(deletions, changes...)
+	}
+
+	// The ownership of the upserted record sets is marked in the same change batch, so that the record sets left
+	// behind by a DNSRecord that no longer exists can be garbage collected, and the markers of the deleted record sets
+	// are deleted along with them
+	resource, err := cache.MetaNamespaceKeyFunc(record)
+	if err != nil {
+		return nil, err
 	}
+	markers, err := p.registryMarkers(context.Background(), zoneID, resource, changes)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, p.registryChanges(changes, resource, markers)...)
 
 	if len(changes) == 0 {
-		return nil
+		return nil, nil
 	}
-	input.ChangeBatch = &route53.ChangeBatch{
-		Changes: changes,
+	info, err := p.batcher.Submit(zoneID, changes, p.submitChanges)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't update DNS record %s in zone %s: %w", record.Name, zoneID, err)
+	}
+	p.logger.Info("Updated DNS record", "record", record, "zone", zoneID, "change", info)
+	return info, nil
+}
+
+// submitChanges submits a batch of changes to the hosted zone
+func (p *Provider) submitChanges(zoneID string, changes []*route53.Change) (*route53.ChangeInfo, error) {
+	input := route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: changes,
+		},
 	}
 	resp, err := p.route53.ChangeResourceRecordSets(&input)
 	if err != nil {
-		return fmt.Errorf("couldn't update DNS record %s in zone %s: %v", record.Name, zoneID, err)
+		return nil, err
 	}
-	p.logger.Info("Updated DNS record", "record", record, "zone", zoneID, "response", resp)
-	return nil
+	return resp.ChangeInfo, nil
 }
 
-func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string) (*route53.Change, error) {
-	if endpoint.RecordType != string(v1.ARecordType) {
-		return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+// endpointKey returns the key identifying the record set of the endpoint, as the same set identifier can be used for
+// records with different names or types.
+func endpointKey(endpoint *v1.Endpoint) string {
+	return endpoint.DNSName + "/" + endpoint.RecordType + "/" + endpoint.SetID()
+}
+
+// aliasNames returns the names of the CNAME endpoints that can be published as alias records, i.e. the names for which
+// all the CNAME targets are ELB hosts, as alias and CNAME records cannot coexist for the same name.
+func aliasNames(endpoints []*v1.Endpoint) map[string]bool {
+	aliases := map[string]bool{}
+	for _, endpoint := range endpoints {
+		if endpoint.RecordType != string(v1.CNAMERecordType) {
+			continue
+		}
+		aliasable := len(endpoint.Targets) == 1
+		if aliasable {
+			_, aliasable = canonicalHostedZone(endpoint.Targets[0])
+		}
+		if previous, ok := aliases[endpoint.DNSName]; ok {
+			aliasable = aliasable && previous
+		}
+		aliases[endpoint.DNSName] = aliasable
 	}
+	return aliases
+}
+
+func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string, alias bool) (*route53.Change, error) {
 	domain, targets := endpoint.DNSName, endpoint.Targets
 	if len(domain) == 0 {
 		return nil, fmt.Errorf("domain is required")
@@ -200,16 +404,42 @@ func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string) (*rou
 		return nil, fmt.Errorf("targets is required")
 	}
 
-	var resourceRecords []*route53.ResourceRecord
-	for _, target := range endpoint.Targets {
-		resourceRecords = append(resourceRecords, &route53.ResourceRecord{Value: aws.String(target)})
+	resourceRecordSet := &route53.ResourceRecordSet{
+		Name: aws.String(endpoint.DNSName),
 	}
 
-	resourceRecordSet := &route53.ResourceRecordSet{
-		Name:            aws.String(endpoint.DNSName),
-		Type:            aws.String(route53.RRTypeA),
-		TTL:             aws.Int64(int64(endpoint.RecordTTL)),
-		ResourceRecords: resourceRecords,
+	switch endpoint.RecordType {
+	case string(v1.ARecordType), string(v1.AAAARecordType):
+		var resourceRecords []*route53.ResourceRecord
+		for _, target := range endpoint.Targets {
+			resourceRecords = append(resourceRecords, &route53.ResourceRecord{Value: aws.String(target)})
+		}
+		resourceRecordSet.Type = aws.String(endpoint.RecordType)
+		resourceRecordSet.TTL = aws.Int64(int64(endpoint.RecordTTL))
+		resourceRecordSet.ResourceRecords = resourceRecords
+	case string(v1.CNAMERecordType):
+		if len(targets) > 1 {
+			return nil, fmt.Errorf("a CNAME record can only have a single target, got %d for %s", len(targets), endpoint.SetID())
+		}
+		if hostedZoneID, ok := canonicalHostedZone(targets[0]); ok && alias {
+			// ELB hosts are published as alias records, that are resolved by Route53 and are free of charge
+			evaluateTargetHealth := false
+			if prop, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificEvaluateTargetHealth); ok {
+				evaluateTargetHealth = prop.Value == "true"
+			}
+			resourceRecordSet.Type = aws.String(route53.RRTypeA)
+			resourceRecordSet.AliasTarget = &route53.AliasTarget{
+				DNSName:              aws.String(targets[0]),
+				HostedZoneId:         aws.String(hostedZoneID),
+				EvaluateTargetHealth: aws.Bool(evaluateTargetHealth),
+			}
+		} else {
+			resourceRecordSet.Type = aws.String(route53.RRTypeCname)
+			resourceRecordSet.TTL = aws.Int64(int64(endpoint.RecordTTL))
+			resourceRecordSet.ResourceRecords = []*route53.ResourceRecord{{Value: aws.String(targets[0])}}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
 	}
 
 	if endpoint.SetIdentifier != "" {
@@ -235,6 +465,14 @@ func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string) (*rou
 	if prop, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificHealthCheckID); ok {
 		resourceRecordSet.HealthCheckId = aws.String(prop.Value)
 	}
+	if prop, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificGeolocationContinentCode); ok {
+		if prop.Value == "*" {
+			// The default geolocation record is identified with the * country code
+			resourceRecordSet.GeoLocation = &route53.GeoLocation{CountryCode: aws.String("*")}
+		} else {
+			resourceRecordSet.GeoLocation = &route53.GeoLocation{ContinentCode: aws.String(prop.Value)}
+		}
+	}
 
 	change := &route53.Change{
 		Action:            aws.String(action),
@@ -0,0 +1,292 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/onsi/gomega"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+func cnameEndpoint(name, host string) *v1.Endpoint {
+	return &v1.Endpoint{
+		DNSName:       name,
+		RecordType:    string(v1.CNAMERecordType),
+		SetIdentifier: host,
+		Targets:       v1.Targets{host},
+		RecordTTL:     60,
+		ProviderSpecific: v1.ProviderSpecific{
+			{Name: ProviderSpecificWeight, Value: "120"},
+		},
+	}
+}
+
+func TestChangeForEndpoint(t *testing.T) {
+	elbHost := "a1234.us-east-1.elb.amazonaws.com"
+	nlbHost := "a1234.elb.eu-west-1.amazonaws.com"
+
+	cases := []struct {
+		name     string
+		endpoint *v1.Endpoint
+		alias    bool
+		expected *route53.ResourceRecordSet
+		err      bool
+	}{
+		{
+			name: "A record",
+			endpoint: &v1.Endpoint{
+				DNSName:       "app.example.com",
+				RecordType:    string(v1.ARecordType),
+				SetIdentifier: "192.0.2.1",
+				Targets:       v1.Targets{"192.0.2.1"},
+				RecordTTL:     60,
+				ProviderSpecific: v1.ProviderSpecific{
+					{Name: ProviderSpecificWeight, Value: "120"},
+				},
+			},
+			expected: &route53.ResourceRecordSet{
+				Name:            aws.String("app.example.com"),
+				Type:            aws.String(route53.RRTypeA),
+				TTL:             aws.Int64(60),
+				ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("192.0.2.1")}},
+				SetIdentifier:   aws.String("192.0.2.1"),
+				Weight:          aws.Int64(120),
+			},
+		},
+		{
+			name: "AAAA record",
+			endpoint: &v1.Endpoint{
+				DNSName:       "app.example.com",
+				RecordType:    string(v1.AAAARecordType),
+				SetIdentifier: "2001:db8::1",
+				Targets:       v1.Targets{"2001:db8::1"},
+				RecordTTL:     60,
+				ProviderSpecific: v1.ProviderSpecific{
+					{Name: ProviderSpecificWeight, Value: "120"},
+				},
+			},
+			expected: &route53.ResourceRecordSet{
+				Name:            aws.String("app.example.com"),
+				Type:            aws.String(route53.RRTypeAaaa),
+				TTL:             aws.Int64(60),
+				ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("2001:db8::1")}},
+				SetIdentifier:   aws.String("2001:db8::1"),
+				Weight:          aws.Int64(120),
+			},
+		},
+		{
+			name:     "weighted CNAME record",
+			endpoint: cnameEndpoint("app.example.com", "lb.example.org"),
+			expected: &route53.ResourceRecordSet{
+				Name:            aws.String("app.example.com"),
+				Type:            aws.String(route53.RRTypeCname),
+				TTL:             aws.Int64(60),
+				ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("lb.example.org")}},
+				SetIdentifier:   aws.String("lb.example.org"),
+				Weight:          aws.Int64(120),
+			},
+		},
+		{
+			name:     "ELB alias record",
+			endpoint: cnameEndpoint("app.example.com", elbHost),
+			alias:    true,
+			expected: &route53.ResourceRecordSet{
+				Name: aws.String("app.example.com"),
+				Type: aws.String(route53.RRTypeA),
+				AliasTarget: &route53.AliasTarget{
+					DNSName:              aws.String(elbHost),
+					HostedZoneId:         aws.String("Z35SXDOTRQ7X7K"),
+					EvaluateTargetHealth: aws.Bool(false),
+				},
+				SetIdentifier: aws.String(elbHost),
+				Weight:        aws.Int64(120),
+			},
+		},
+		{
+			name:     "NLB alias record",
+			endpoint: cnameEndpoint("app.example.com", nlbHost),
+			alias:    true,
+			expected: &route53.ResourceRecordSet{
+				Name: aws.String("app.example.com"),
+				Type: aws.String(route53.RRTypeA),
+				AliasTarget: &route53.AliasTarget{
+					DNSName:              aws.String(nlbHost),
+					HostedZoneId:         aws.String("Z2IFOLAFXWLO4F"),
+					EvaluateTargetHealth: aws.Bool(false),
+				},
+				SetIdentifier: aws.String(nlbHost),
+				Weight:        aws.Int64(120),
+			},
+		},
+		{
+			name:     "ELB CNAME record when alias records cannot be used",
+			endpoint: cnameEndpoint("app.example.com", elbHost),
+			alias:    false,
+			expected: &route53.ResourceRecordSet{
+				Name:            aws.String("app.example.com"),
+				Type:            aws.String(route53.RRTypeCname),
+				TTL:             aws.Int64(60),
+				ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(elbHost)}},
+				SetIdentifier:   aws.String(elbHost),
+				Weight:          aws.Int64(120),
+			},
+		},
+		{
+			name: "geolocation record",
+			endpoint: &v1.Endpoint{
+				DNSName:       "xyz.example.com",
+				RecordType:    string(v1.CNAMERecordType),
+				SetIdentifier: "NA",
+				Targets:       v1.Targets{"xyz.na.example.com"},
+				RecordTTL:     60,
+				ProviderSpecific: v1.ProviderSpecific{
+					{Name: ProviderSpecificGeolocationContinentCode, Value: "NA"},
+				},
+			},
+			expected: &route53.ResourceRecordSet{
+				Name:            aws.String("xyz.example.com"),
+				Type:            aws.String(route53.RRTypeCname),
+				TTL:             aws.Int64(60),
+				ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("xyz.na.example.com")}},
+				SetIdentifier:   aws.String("NA"),
+				GeoLocation:     &route53.GeoLocation{ContinentCode: aws.String("NA")},
+			},
+		},
+		{
+			name: "default geolocation record",
+			endpoint: &v1.Endpoint{
+				DNSName:       "xyz.example.com",
+				RecordType:    string(v1.CNAMERecordType),
+				SetIdentifier: "default",
+				Targets:       v1.Targets{"xyz.na.example.com"},
+				RecordTTL:     60,
+				ProviderSpecific: v1.ProviderSpecific{
+					{Name: ProviderSpecificGeolocationContinentCode, Value: "*"},
+				},
+			},
+			expected: &route53.ResourceRecordSet{
+				Name:            aws.String("xyz.example.com"),
+				Type:            aws.String(route53.RRTypeCname),
+				TTL:             aws.Int64(60),
+				ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("xyz.na.example.com")}},
+				SetIdentifier:   aws.String("default"),
+				GeoLocation:     &route53.GeoLocation{CountryCode: aws.String("*")},
+			},
+		},
+		{
+			name: "CNAME record with multiple targets",
+			endpoint: &v1.Endpoint{
+				DNSName:    "app.example.com",
+				RecordType: string(v1.CNAMERecordType),
+				Targets:    v1.Targets{"lb1.example.org", "lb2.example.org"},
+			},
+			err: true,
+		},
+		{
+			name: "unsupported record type",
+			endpoint: &v1.Endpoint{
+				DNSName:    "app.example.com",
+				RecordType: "TXT",
+				Targets:    v1.Targets{"text"},
+			},
+			err: true,
+		},
+	}
+
+	provider := &Provider{logger: log.Logger}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			change, err := provider.changeForEndpoint(tc.endpoint, string(upsertAction), tc.alias)
+			if tc.err {
+				g.Expect(err).To(gomega.HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			g.Expect(*change.Action).To(gomega.Equal(string(upsertAction)))
+			g.Expect(change.ResourceRecordSet).To(gomega.Equal(tc.expected))
+		})
+	}
+}
+
+func TestAliasNames(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	aliases := aliasNames([]*v1.Endpoint{
+		cnameEndpoint("elb.example.com", "a1.us-east-1.elb.amazonaws.com"),
+		cnameEndpoint("elb.example.com", "a2.eu-west-1.elb.amazonaws.com"),
+		cnameEndpoint("mixed.example.com", "a1.us-east-1.elb.amazonaws.com"),
+		cnameEndpoint("mixed.example.com", "lb.example.org"),
+		cnameEndpoint("other.example.com", "lb.example.org"),
+	})
+	g.Expect(aliases).To(gomega.Equal(map[string]bool{
+		"elb.example.com":   true,
+		"mixed.example.com": false,
+		"other.example.com": false,
+	}))
+}
+
+func TestObservationsHealthy(t *testing.T) {
+	observations := func(statuses ...string) []*route53.HealthCheckObservation {
+		var result []*route53.HealthCheckObservation
+		for _, status := range statuses {
+			result = append(result, &route53.HealthCheckObservation{
+				StatusReport: &route53.StatusReport{Status: aws.String(status)},
+			})
+		}
+		return result
+	}
+	success := "Success: HTTP Status Code 200, OK"
+	failure := "Failure: Connection timed out"
+
+	cases := []struct {
+		name         string
+		observations []*route53.HealthCheckObservation
+		expected     bool
+	}{
+		{
+			name:     "no observations",
+			expected: true,
+		},
+		{
+			name:         "all healthy",
+			observations: observations(success, success, success),
+			expected:     true,
+		},
+		{
+			name:         "all unhealthy",
+			observations: observations(failure, failure, failure),
+			expected:     false,
+		},
+		{
+			name:         "healthy above threshold",
+			observations: observations(success, failure, failure, failure),
+			expected:     true,
+		},
+		{
+			name:         "healthy below threshold",
+			observations: observations(success, failure, failure, failure, failure, failure),
+			expected:     false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			g.Expect(observationsHealthy(tc.observations)).To(gomega.Equal(tc.expected))
+		})
+	}
+}
+
+func TestZoneIDFromARN(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	id, ok := zoneIDFromARN("arn:aws:route53:::hostedzone/Z08652651232L9P84LRSB")
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(id).To(gomega.Equal("Z08652651232L9P84LRSB"))
+
+	_, ok = zoneIDFromARN("arn:aws:route53:::healthcheck/abcdef")
+	g.Expect(ok).To(gomega.BeFalse())
+}
@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// Drift returns the endpoints of the record whose record sets are missing from the hosted zone, or differ from the
+// record sets they are published as.
+func (p *Provider) Drift(ctx context.Context, record *v1.DNSRecord, zone v1.DNSZone) ([]*v1.Endpoint, error) {
+	live := map[string]*route53.ResourceRecordSet{}
+	listed := map[string]bool{}
+	for _, endpoint := range record.Spec.Endpoints {
+		name := normalizeRecordName(endpoint.DNSName)
+		if listed[name] {
+			continue
+		}
+		listed[name] = true
+		if err := p.listRecordSets(ctx, zone.ID, name, live); err != nil {
+			return nil, err
+		}
+	}
+
+	var drifted []*v1.Endpoint
+	aliases := aliasNames(record.Spec.Endpoints)
+	for _, endpoint := range record.Spec.Endpoints {
+		change, err := p.changeForEndpoint(endpoint, string(upsertAction), aliases[endpoint.DNSName])
+		if err != nil {
+			return nil, err
+		}
+		expected := change.ResourceRecordSet
+		if actual, ok := live[recordSetKey(expected)]; !ok || !recordSetsEqual(expected, actual) {
+			drifted = append(drifted, endpoint)
+		}
+	}
+	return drifted, nil
+}
+
+// listRecordSets adds the record sets of the hosted zone with the given name to the live record sets
+func (p *Provider) listRecordSets(ctx context.Context, zoneID, name string, live map[string]*route53.ResourceRecordSet) error {
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneID),
+		StartRecordName: aws.String(name),
+	}
+	for {
+		output, err := p.route53.ListResourceRecordSetsWithContext(ctx, input)
+		if err != nil {
+			return fmt.Errorf("couldn't list record sets %s in zone %s: %w", name, zoneID, err)
+		}
+		// The record sets are listed in the order of their names, starting with the given name
+		for _, recordSet := range output.ResourceRecordSets {
+			if normalizeRecordName(aws.StringValue(recordSet.Name)) != name {
+				return nil
+			}
+			live[recordSetKey(recordSet)] = recordSet
+		}
+		if !aws.BoolValue(output.IsTruncated) {
+			return nil
+		}
+		input.StartRecordName = output.NextRecordName
+		input.StartRecordType = output.NextRecordType
+		input.StartRecordIdentifier = output.NextRecordIdentifier
+	}
+}
+
+// normalizeRecordName returns the name as listed by Route53, without the trailing dot, in lower case, and with the
+// wildcard unescaped
+func normalizeRecordName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	return strings.ReplaceAll(name, `\052`, "*")
+}
+
+// recordSetKey returns the key identifying the record set
+func recordSetKey(recordSet *route53.ResourceRecordSet) string {
+	return strings.Join([]string{
+		normalizeRecordName(aws.StringValue(recordSet.Name)),
+		aws.StringValue(recordSet.Type),
+		aws.StringValue(recordSet.SetIdentifier),
+	}, "/")
+}
+
+// recordSetsEqual returns whether the live record set serves the expected record set. The values are compared
+// regardless of their order, and the alias targets regardless of the trailing dot Route53 adds.
+func recordSetsEqual(expected, actual *route53.ResourceRecordSet) bool {
+	if aws.Int64Value(expected.TTL) != aws.Int64Value(actual.TTL) ||
+		aws.Int64Value(expected.Weight) != aws.Int64Value(actual.Weight) ||
+		aws.StringValue(expected.Region) != aws.StringValue(actual.Region) ||
+		aws.StringValue(expected.Failover) != aws.StringValue(actual.Failover) ||
+		aws.BoolValue(expected.MultiValueAnswer) != aws.BoolValue(actual.MultiValueAnswer) ||
+		aws.StringValue(expected.HealthCheckId) != aws.StringValue(actual.HealthCheckId) ||
+		!reflect.DeepEqual(expected.GeoLocation, actual.GeoLocation) {
+		return false
+	}
+	if (expected.AliasTarget == nil) != (actual.AliasTarget == nil) {
+		return false
+	}
+	if expected.AliasTarget != nil {
+		return normalizeRecordName(aws.StringValue(expected.AliasTarget.DNSName)) == normalizeRecordName(aws.StringValue(actual.AliasTarget.DNSName)) &&
+			aws.StringValue(expected.AliasTarget.HostedZoneId) == aws.StringValue(actual.AliasTarget.HostedZoneId) &&
+			aws.BoolValue(expected.AliasTarget.EvaluateTargetHealth) == aws.BoolValue(actual.AliasTarget.EvaluateTargetHealth)
+	}
+	return reflect.DeepEqual(recordValues(expected), recordValues(actual))
+}
+
+func recordValues(recordSet *route53.ResourceRecordSet) []string {
+	values := make([]string, 0, len(recordSet.ResourceRecords))
+	for _, record := range recordSet.ResourceRecords {
+		values = append(values, strings.TrimSuffix(aws.StringValue(record.Value), "."))
+	}
+	sort.Strings(values)
+	return values
+}
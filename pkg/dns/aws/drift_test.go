@@ -0,0 +1,106 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/onsi/gomega"
+)
+
+func TestRecordSetsEqual(t *testing.T) {
+	expected := &route53.ResourceRecordSet{
+		Name:          aws.String("app.example.com"),
+		Type:          aws.String(route53.RRTypeA),
+		TTL:           aws.Int64(60),
+		SetIdentifier: aws.String("cluster-1"),
+		Weight:        aws.Int64(120),
+		ResourceRecords: []*route53.ResourceRecord{
+			{Value: aws.String("192.0.2.1")},
+			{Value: aws.String("192.0.2.2")},
+		},
+	}
+	alias := &route53.ResourceRecordSet{
+		Name: aws.String("app.example.com"),
+		Type: aws.String(route53.RRTypeA),
+		AliasTarget: &route53.AliasTarget{
+			DNSName:              aws.String("a1234.us-east-1.elb.amazonaws.com"),
+			HostedZoneId:         aws.String("Z35SXDOTRQ7X7K"),
+			EvaluateTargetHealth: aws.Bool(false),
+		},
+	}
+
+	cases := []struct {
+		name     string
+		expected *route53.ResourceRecordSet
+		actual   func(recordSet *route53.ResourceRecordSet)
+		equal    bool
+	}{
+		{
+			name:     "live record set in a different order",
+			expected: expected,
+			actual: func(recordSet *route53.ResourceRecordSet) {
+				recordSet.Name = aws.String("app.example.com.")
+				recordSet.ResourceRecords[0], recordSet.ResourceRecords[1] = recordSet.ResourceRecords[1], recordSet.ResourceRecords[0]
+			},
+			equal: true,
+		},
+		{
+			name:     "edited value",
+			expected: expected,
+			actual: func(recordSet *route53.ResourceRecordSet) {
+				recordSet.ResourceRecords[0].Value = aws.String("192.0.2.3")
+			},
+		},
+		{
+			name:     "edited TTL",
+			expected: expected,
+			actual: func(recordSet *route53.ResourceRecordSet) {
+				recordSet.TTL = aws.Int64(300)
+			},
+		},
+		{
+			name:     "edited weight",
+			expected: expected,
+			actual: func(recordSet *route53.ResourceRecordSet) {
+				recordSet.Weight = aws.Int64(0)
+			},
+		},
+		{
+			name:     "alias with trailing dot",
+			expected: alias,
+			actual: func(recordSet *route53.ResourceRecordSet) {
+				recordSet.AliasTarget.DNSName = aws.String("a1234.us-east-1.elb.amazonaws.com.")
+			},
+			equal: true,
+		},
+		{
+			name:     "alias replaced with values",
+			expected: alias,
+			actual: func(recordSet *route53.ResourceRecordSet) {
+				recordSet.AliasTarget = nil
+				recordSet.TTL = aws.Int64(60)
+				recordSet.ResourceRecords = []*route53.ResourceRecord{{Value: aws.String("192.0.2.1")}}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			actual := &route53.ResourceRecordSet{}
+			awsutil.Copy(actual, tc.expected)
+			tc.actual(actual)
+			g.Expect(recordSetsEqual(tc.expected, actual)).To(gomega.Equal(tc.equal))
+		})
+	}
+}
+
+func TestRecordSetKey(t *testing.T) {
+	g := gomega.NewWithT(t)
+	g.Expect(recordSetKey(&route53.ResourceRecordSet{
+		Name:          aws.String(`\052.Example.com.`),
+		Type:          aws.String(route53.RRTypeCname),
+		SetIdentifier: aws.String("cluster-1"),
+	})).To(gomega.Equal("*.example.com/CNAME/cluster-1"))
+}
@@ -3,37 +3,75 @@ package aws
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/rs/xid"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 )
 
 const (
 	idTag = "kuadrant.dev/healthcheck"
+	// healthyCheckersThreshold is the ratio of health checkers above which Route53 considers an endpoint healthy,
+	// see https://docs.aws.amazon.com/Route53/latest/DeveloperGuide/dns-failover-determining-health-of-endpoints.html
+	healthyCheckersThreshold = 0.18
 )
 
 var (
 	callerReference func(id string) *string
 )
 
+// healthCheckClient is the part of the Route53 API the health checks are managed with
+type healthCheckClient interface {
+	CreateHealthCheck(input *route53.CreateHealthCheckInput) (*route53.CreateHealthCheckOutput, error)
+	GetHealthCheckWithContext(ctx aws.Context, input *route53.GetHealthCheckInput, opts ...request.Option) (*route53.GetHealthCheckOutput, error)
+	GetHealthCheckStatusWithContext(ctx aws.Context, input *route53.GetHealthCheckStatusInput, opts ...request.Option) (*route53.GetHealthCheckStatusOutput, error)
+	UpdateHealthCheckWithContext(ctx aws.Context, input *route53.UpdateHealthCheckInput, opts ...request.Option) (*route53.UpdateHealthCheckOutput, error)
+	DeleteHealthCheckWithContext(ctx aws.Context, input *route53.DeleteHealthCheckInput, opts ...request.Option) (*route53.DeleteHealthCheckOutput, error)
+	ChangeTagsForResourceWithContext(ctx aws.Context, input *route53.ChangeTagsForResourceInput, opts ...request.Option) (*route53.ChangeTagsForResourceOutput, error)
+}
+
+var _ healthCheckClient = &InstrumentedRoute53{}
+
 type Route53HealthCheckReconciler struct {
-	client *InstrumentedRoute53
+	client healthCheckClient
 	logger logr.Logger
 }
 
-func newRoute53HealthCheckReconciler(c *InstrumentedRoute53, l logr.Logger) *Route53HealthCheckReconciler {
+func newRoute53HealthCheckReconciler(c healthCheckClient, l logr.Logger) *Route53HealthCheckReconciler {
 	return &Route53HealthCheckReconciler{
 		client: c,
 		logger: l.WithName("health"),
 	}
 }
 
+// reconcile reconciles the health check of the endpoint. Route53 associates a single health check to a record set, so
+// the addresses of the endpoints with several addresses, e.g. failover record sets, are probed by a health check each,
+// that are aggregated by a calculated health check associated to the record set, healthy while all the addresses are.
 func (r *Route53HealthCheckReconciler) reconcile(ctx context.Context, spec v1.HealthCheck, endpoint *v1.Endpoint) error {
+	if sets.NewString(endpoint.Targets...).Len() > 1 {
+		return r.reconcileCalculatedHealthCheck(ctx, spec, endpoint)
+	}
+
+	// The calculated health check is replaced once the endpoint has a single address left
+	if len(getAddressHealthCheckIds(endpoint)) > 0 {
+		if err := r.deleteHealthCheck(ctx, endpoint); err != nil {
+			return err
+		}
+	}
+	return r.reconcileAddressHealthCheck(ctx, spec, endpoint)
+}
+
+// reconcileAddressHealthCheck reconciles the health check probing the address of the endpoint
+func (r *Route53HealthCheckReconciler) reconcileAddressHealthCheck(ctx context.Context, spec v1.HealthCheck, endpoint *v1.Endpoint) error {
 	healthCheck, exists, err := r.findHealthCheck(ctx, endpoint)
 	if err != nil {
 		return err
@@ -53,25 +91,150 @@ func (r *Route53HealthCheckReconciler) reconcile(ctx context.Context, spec v1.He
 	return err
 }
 
+// reconcileCalculatedHealthCheck reconciles the health checks probing each address of the endpoint, and the calculated
+// health check aggregating them. The IDs of the health checks of the addresses are recorded on the endpoint, as soon as
+// they are created, so that they are deleted along with the endpoint.
+func (r *Route53HealthCheckReconciler) reconcileCalculatedHealthCheck(ctx context.Context, spec v1.HealthCheck, endpoint *v1.Endpoint) error {
+	addressIds := getAddressHealthCheckIds(endpoint)
+	// The health check probing the single address the endpoint had is replaced by the calculated health check
+	if len(addressIds) == 0 {
+		if err := r.deleteHealthCheck(ctx, endpoint); err != nil {
+			return err
+		}
+	}
+
+	addresses := sets.NewString(endpoint.Targets...).List()
+	for _, address := range addresses {
+		addressEndpoint := &v1.Endpoint{
+			DNSName:       endpoint.DNSName,
+			SetIdentifier: endpoint.SetIdentifier,
+			Targets:       v1.Targets{address},
+		}
+		if id, ok := addressIds[address]; ok {
+			addressEndpoint.SetProviderSpecific(ProviderSpecificHealthCheckID, id)
+		}
+		err := r.reconcileAddressHealthCheck(ctx, addressHealthCheckSpec(spec, address), addressEndpoint)
+		if id, ok := getHealthCheckId(addressEndpoint); ok {
+			addressIds[address] = id
+			setAddressHealthCheckIds(endpoint, addressIds)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	children := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		children = append(children, addressIds[address])
+	}
+	healthCheck, exists, err := r.findHealthCheck(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if diff := calculatedHealthCheckDiff(healthCheck, children); diff != nil {
+			r.logger.Info("Updating health check", "id", *healthCheck.Id, "change", diff)
+			if _, err := r.client.UpdateHealthCheckWithContext(ctx, diff); err != nil {
+				return err
+			}
+		}
+	} else {
+		if healthCheck, err = r.createCalculatedHealthCheck(ctx, spec, children); err != nil {
+			return err
+		}
+		endpoint.SetProviderSpecific(ProviderSpecificHealthCheckID, *healthCheck.Id)
+	}
+
+	// The health checks of the addresses the endpoint no longer has are deleted once they are no longer aggregated
+	return r.deleteAddressHealthChecks(ctx, endpoint, sets.NewString(addresses...))
+}
+
+// deleteHealthCheck deletes the health check of the endpoint, and the health checks of its addresses, after the
+// calculated health check aggregating them.
 func (r *Route53HealthCheckReconciler) deleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint) error {
 	healthCheck, found, err := r.findHealthCheck(ctx, endpoint)
 	if err != nil {
 		return err
 	}
-	if !found {
-		return nil
+	if found {
+		_, err = r.client.DeleteHealthCheckWithContext(ctx, &route53.DeleteHealthCheckInput{
+			HealthCheckId: healthCheck.Id,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		endpoint.DeleteProviderSpecific(ProviderSpecificHealthCheckID)
 	}
 
-	_, err = r.client.DeleteHealthCheckWithContext(ctx, &route53.DeleteHealthCheckInput{
-		HealthCheckId: healthCheck.Id,
-	})
+	return r.deleteAddressHealthChecks(ctx, endpoint, sets.NewString())
+}
+
+// deleteAddressHealthChecks deletes the health checks of the addresses of the endpoint, but the ones to keep
+func (r *Route53HealthCheckReconciler) deleteAddressHealthChecks(ctx context.Context, endpoint *v1.Endpoint, keep sets.String) error {
+	addressIds := getAddressHealthCheckIds(endpoint)
+	for address, id := range addressIds {
+		if keep.Has(address) {
+			continue
+		}
+		_, err := r.client.DeleteHealthCheckWithContext(ctx, &route53.DeleteHealthCheckInput{
+			HealthCheckId: aws.String(id),
+		})
+		if err != nil && !isNoSuchHealthCheck(err) {
+			return err
+		}
+		delete(addressIds, address)
+		setAddressHealthCheckIds(endpoint, addressIds)
+	}
+	return nil
+}
+
+// healthy returns whether the health check of the endpoint is healthy, i.e. the health checks of all its addresses when
+// it has several, as Route53 does not report the status of calculated health checks. Endpoints without health check
+// are healthy, as Route53 considers them so.
+func (r *Route53HealthCheckReconciler) healthy(ctx context.Context, endpoint *v1.Endpoint) (bool, error) {
+	if addressIds := getAddressHealthCheckIds(endpoint); len(addressIds) > 0 {
+		for _, address := range sets.StringKeySet(addressIds).List() {
+			healthy, err := r.healthCheckHealthy(ctx, addressIds[address])
+			if err != nil || !healthy {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	id, hasId := getHealthCheckId(endpoint)
+	if !hasId {
+		return true, nil
+	}
+
+	return r.healthCheckHealthy(ctx, id)
+}
 
+func (r *Route53HealthCheckReconciler) healthCheckHealthy(ctx context.Context, id string) (bool, error) {
+	response, err := r.client.GetHealthCheckStatusWithContext(ctx, &route53.GetHealthCheckStatusInput{
+		HealthCheckId: &id,
+	})
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	endpoint.DeleteProviderSpecific(ProviderSpecificHealthCheckID)
-	return err
+	return observationsHealthy(response.HealthCheckObservations), nil
+}
+
+// observationsHealthy returns whether enough health checkers observed the endpoint as healthy
+func observationsHealthy(observations []*route53.HealthCheckObservation) bool {
+	if len(observations) == 0 {
+		return true
+	}
+	healthy := 0
+	for _, observation := range observations {
+		if observation.StatusReport != nil && strings.HasPrefix(aws.StringValue(observation.StatusReport.Status), "Success") {
+			healthy++
+		}
+	}
+	return float64(healthy)/float64(len(observations)) > healthyCheckersThreshold
 }
 
 func (r *Route53HealthCheckReconciler) findHealthCheck(ctx context.Context, endpoint *v1.Endpoint) (*route53.HealthCheck, bool, error) {
@@ -111,9 +274,38 @@ func (r *Route53HealthCheckReconciler) createHealthCheck(ctx context.Context, sp
 		return nil, err
 	}
 
+	if err := r.tagHealthCheck(ctx, spec, output.HealthCheck); err != nil {
+		return nil, err
+	}
+
+	return output.HealthCheck, nil
+}
+
+// createCalculatedHealthCheck creates the calculated health check that is healthy while all the given health checks are
+func (r *Route53HealthCheckReconciler) createCalculatedHealthCheck(ctx context.Context, spec v1.HealthCheck, children []string) (*route53.HealthCheck, error) {
+	output, err := r.client.CreateHealthCheck(&route53.CreateHealthCheckInput{
+		CallerReference: callerReference(spec.Id + "-calculated"),
+		HealthCheckConfig: &route53.HealthCheckConfig{
+			Type:              aws.String(route53.HealthCheckTypeCalculated),
+			ChildHealthChecks: aws.StringSlice(children),
+			HealthThreshold:   aws.Int64(int64(len(children))),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.tagHealthCheck(ctx, spec, output.HealthCheck); err != nil {
+		return nil, err
+	}
+
+	return output.HealthCheck, nil
+}
+
+// tagHealthCheck adds the tags identifying the health check
+func (r *Route53HealthCheckReconciler) tagHealthCheck(ctx context.Context, spec v1.HealthCheck, healthCheck *route53.HealthCheck) error {
 	name := spec.Name
-	// Add the tag to identify it
-	_, err = r.client.ChangeTagsForResourceWithContext(ctx, &route53.ChangeTagsForResourceInput{
+	_, err := r.client.ChangeTagsForResourceWithContext(ctx, &route53.ChangeTagsForResourceInput{
 		AddTags: []*route53.Tag{
 			{
 				Key:   aws.String(idTag),
@@ -124,14 +316,10 @@ func (r *Route53HealthCheckReconciler) createHealthCheck(ctx context.Context, sp
 				Value: &name,
 			},
 		},
-		ResourceId:   output.HealthCheck.Id,
+		ResourceId:   healthCheck.Id,
 		ResourceType: aws.String(route53.TagResourceTypeHealthcheck),
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	return output.HealthCheck, nil
+	return err
 }
 
 func (r *Route53HealthCheckReconciler) updateHealthCheck(ctx context.Context, spec v1.HealthCheck, endpoint *v1.Endpoint, healthCheck *route53.HealthCheck) error {
@@ -188,6 +376,34 @@ func healthCheckDiff(healthCheck *route53.HealthCheck, spec v1.HealthCheck, endp
 	return result
 }
 
+// calculatedHealthCheckDiff creates a `UpdateHealthCheckInput` object with the child health checks and threshold of
+// the calculated health check to update. If the health check matches the children, returns `nil`
+func calculatedHealthCheckDiff(healthCheck *route53.HealthCheck, children []string) *route53.UpdateHealthCheckInput {
+	current := aws.StringValueSlice(healthCheck.HealthCheckConfig.ChildHealthChecks)
+	sort.Strings(current)
+	threshold := int64(len(children))
+	if strings.Join(current, ",") == strings.Join(children, ",") && aws.Int64Value(healthCheck.HealthCheckConfig.HealthThreshold) == threshold {
+		return nil
+	}
+	return &route53.UpdateHealthCheckInput{
+		HealthCheckId:     healthCheck.Id,
+		ChildHealthChecks: aws.StringSlice(children),
+		HealthThreshold:   aws.Int64(threshold),
+	}
+}
+
+// addressHealthCheckSpec returns the spec of the health check probing the given address of an endpoint
+func addressHealthCheckSpec(spec v1.HealthCheck, address string) v1.HealthCheck {
+	spec.Id = spec.Id + "-" + address
+	spec.Name = spec.Name + "-" + address
+	return spec
+}
+
+func isNoSuchHealthCheck(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == route53.ErrCodeNoSuchHealthCheck
+}
+
 func init() {
 	sid := xid.New()
 	callerReference = func(s string) *string {
@@ -240,3 +456,28 @@ func intValuesEqual(int1, int2 *int64) bool {
 func getHealthCheckId(endpoint *v1.Endpoint) (string, bool) {
 	return endpoint.GetProviderSpecific(ProviderSpecificHealthCheckID)
 }
+
+// getAddressHealthCheckIds returns the IDs of the health checks of the addresses of the endpoint, indexed by address,
+// that are recorded as a comma separated list of address=id pairs
+func getAddressHealthCheckIds(endpoint *v1.Endpoint) map[string]string {
+	ids := map[string]string{}
+	value, _ := endpoint.GetProviderSpecific(ProviderSpecificAddressHealthCheckIDs)
+	for _, pair := range strings.Split(value, ",") {
+		if address, id, ok := strings.Cut(pair, "="); ok && address != "" && id != "" {
+			ids[address] = id
+		}
+	}
+	return ids
+}
+
+func setAddressHealthCheckIds(endpoint *v1.Endpoint, ids map[string]string) {
+	if len(ids) == 0 {
+		endpoint.DeleteProviderSpecific(ProviderSpecificAddressHealthCheckIDs)
+		return
+	}
+	pairs := make([]string, 0, len(ids))
+	for _, address := range sets.StringKeySet(ids).List() {
+		pairs = append(pairs, address+"="+ids[address])
+	}
+	endpoint.SetProviderSpecific(ProviderSpecificAddressHealthCheckIDs, strings.Join(pairs, ","))
+}
@@ -0,0 +1,183 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/onsi/gomega"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// fakeHealthCheckClient keeps the health checks in memory, the health checks of the unhealthy addresses failing
+type fakeHealthCheckClient struct {
+	healthChecks map[string]*route53.HealthCheck
+	unhealthy    map[string]bool
+	created      int
+}
+
+var _ healthCheckClient = &fakeHealthCheckClient{}
+
+func newFakeHealthCheckClient() *fakeHealthCheckClient {
+	return &fakeHealthCheckClient{healthChecks: map[string]*route53.HealthCheck{}, unhealthy: map[string]bool{}}
+}
+
+func (c *fakeHealthCheckClient) CreateHealthCheck(input *route53.CreateHealthCheckInput) (*route53.CreateHealthCheckOutput, error) {
+	c.created++
+	healthCheck := &route53.HealthCheck{
+		Id:                aws.String(fmt.Sprintf("hc-%d", c.created)),
+		CallerReference:   input.CallerReference,
+		HealthCheckConfig: input.HealthCheckConfig,
+	}
+	c.healthChecks[*healthCheck.Id] = healthCheck
+	return &route53.CreateHealthCheckOutput{HealthCheck: healthCheck}, nil
+}
+
+func (c *fakeHealthCheckClient) GetHealthCheckWithContext(_ aws.Context, input *route53.GetHealthCheckInput, _ ...request.Option) (*route53.GetHealthCheckOutput, error) {
+	healthCheck, ok := c.healthChecks[*input.HealthCheckId]
+	if !ok {
+		return nil, awserr.New(route53.ErrCodeNoSuchHealthCheck, "not found", nil)
+	}
+	return &route53.GetHealthCheckOutput{HealthCheck: healthCheck}, nil
+}
+
+func (c *fakeHealthCheckClient) GetHealthCheckStatusWithContext(_ aws.Context, input *route53.GetHealthCheckStatusInput, _ ...request.Option) (*route53.GetHealthCheckStatusOutput, error) {
+	healthCheck, ok := c.healthChecks[*input.HealthCheckId]
+	if !ok {
+		return nil, awserr.New(route53.ErrCodeNoSuchHealthCheck, "not found", nil)
+	}
+	if aws.StringValue(healthCheck.HealthCheckConfig.Type) == route53.HealthCheckTypeCalculated {
+		return nil, awserr.New(route53.ErrCodeInvalidInput, "status of calculated health checks is not available", nil)
+	}
+	status := "Success: HTTP Status Code 200, OK"
+	if c.unhealthy[aws.StringValue(healthCheck.HealthCheckConfig.IPAddress)] {
+		status = "Failure: Connection timed out"
+	}
+	return &route53.GetHealthCheckStatusOutput{HealthCheckObservations: []*route53.HealthCheckObservation{
+		{StatusReport: &route53.StatusReport{Status: aws.String(status)}},
+	}}, nil
+}
+
+func (c *fakeHealthCheckClient) UpdateHealthCheckWithContext(_ aws.Context, input *route53.UpdateHealthCheckInput, _ ...request.Option) (*route53.UpdateHealthCheckOutput, error) {
+	healthCheck := c.healthChecks[*input.HealthCheckId]
+	if input.ChildHealthChecks != nil {
+		healthCheck.HealthCheckConfig.ChildHealthChecks = input.ChildHealthChecks
+		healthCheck.HealthCheckConfig.HealthThreshold = input.HealthThreshold
+	}
+	if input.IPAddress != nil {
+		healthCheck.HealthCheckConfig.IPAddress = input.IPAddress
+	}
+	return &route53.UpdateHealthCheckOutput{HealthCheck: healthCheck}, nil
+}
+
+func (c *fakeHealthCheckClient) DeleteHealthCheckWithContext(_ aws.Context, input *route53.DeleteHealthCheckInput, _ ...request.Option) (*route53.DeleteHealthCheckOutput, error) {
+	if _, ok := c.healthChecks[*input.HealthCheckId]; !ok {
+		return nil, awserr.New(route53.ErrCodeNoSuchHealthCheck, "not found", nil)
+	}
+	// Route53 refuses to delete the health checks aggregated by a calculated health check
+	for _, healthCheck := range c.healthChecks {
+		for _, child := range healthCheck.HealthCheckConfig.ChildHealthChecks {
+			if *child == *input.HealthCheckId {
+				return nil, awserr.New(route53.ErrCodeHealthCheckInUse, "in use", nil)
+			}
+		}
+	}
+	delete(c.healthChecks, *input.HealthCheckId)
+	return &route53.DeleteHealthCheckOutput{}, nil
+}
+
+func (c *fakeHealthCheckClient) ChangeTagsForResourceWithContext(_ aws.Context, _ *route53.ChangeTagsForResourceInput, _ ...request.Option) (*route53.ChangeTagsForResourceOutput, error) {
+	return &route53.ChangeTagsForResourceOutput{}, nil
+}
+
+// addresses returns the addresses probed by the health checks, and the number of calculated health checks
+func (c *fakeHealthCheckClient) addresses() ([]string, int) {
+	var addresses []string
+	calculated := 0
+	for _, healthCheck := range c.healthChecks {
+		if aws.StringValue(healthCheck.HealthCheckConfig.Type) == route53.HealthCheckTypeCalculated {
+			calculated++
+			continue
+		}
+		addresses = append(addresses, aws.StringValue(healthCheck.HealthCheckConfig.IPAddress))
+	}
+	return addresses, calculated
+}
+
+func TestHealthCheckReconcilerFailoverAddresses(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+	client := newFakeHealthCheckClient()
+	reconciler := newRoute53HealthCheckReconciler(client, log.Logger)
+
+	port := int64(80)
+	protocol := v1.HealthCheckProtocolHTTP
+	spec := v1.HealthCheck{Id: "id", Name: "primary", Port: &port, Path: "/healthz", Protocol: &protocol}
+	primary := &v1.Endpoint{
+		DNSName:       "xyz.dev.hcpapps.net",
+		RecordType:    string(v1.ARecordType),
+		SetIdentifier: "primary",
+		Targets:       v1.Targets{"192.168.0.1", "192.168.0.2"},
+	}
+	primary.SetProviderSpecific(ProviderSpecificFailover, FailoverPrimary)
+
+	// Each primary address is probed, the record set being associated to the calculated health check aggregating them
+	g.Expect(reconciler.reconcile(ctx, spec, primary)).To(gomega.Succeed())
+	addresses, calculated := client.addresses()
+	g.Expect(addresses).To(gomega.ConsistOf("192.168.0.1", "192.168.0.2"))
+	g.Expect(calculated).To(gomega.Equal(1))
+	addressIds := getAddressHealthCheckIds(primary)
+	g.Expect(addressIds).To(gomega.HaveLen(2))
+	id, ok := getHealthCheckId(primary)
+	g.Expect(ok).To(gomega.BeTrue())
+	config := client.healthChecks[id].HealthCheckConfig
+	g.Expect(aws.StringValue(config.Type)).To(gomega.Equal(route53.HealthCheckTypeCalculated))
+	g.Expect(aws.StringValueSlice(config.ChildHealthChecks)).To(gomega.ConsistOf(addressIds["192.168.0.1"], addressIds["192.168.0.2"]))
+	g.Expect(aws.Int64Value(config.HealthThreshold)).To(gomega.Equal(int64(2)))
+
+	// The primary record set is unhealthy when any of its addresses is
+	healthy, err := reconciler.healthy(ctx, primary)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(healthy).To(gomega.BeTrue())
+	client.unhealthy["192.168.0.2"] = true
+	healthy, err = reconciler.healthy(ctx, primary)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(healthy).To(gomega.BeFalse())
+
+	// The reconciliation is idempotent
+	g.Expect(reconciler.reconcile(ctx, spec, primary)).To(gomega.Succeed())
+	g.Expect(client.healthChecks).To(gomega.HaveLen(3))
+
+	// The health checks of the addresses removed from the record set are deleted
+	primary.Targets = v1.Targets{"192.168.0.1", "192.168.0.3"}
+	g.Expect(reconciler.reconcile(ctx, spec, primary)).To(gomega.Succeed())
+	addresses, calculated = client.addresses()
+	g.Expect(addresses).To(gomega.ConsistOf("192.168.0.1", "192.168.0.3"))
+	g.Expect(calculated).To(gomega.Equal(1))
+	g.Expect(getAddressHealthCheckIds(primary)).To(gomega.HaveKey("192.168.0.3"))
+	g.Expect(aws.Int64Value(client.healthChecks[id].HealthCheckConfig.HealthThreshold)).To(gomega.Equal(int64(2)))
+
+	// The calculated health check is replaced once the record set has a single address left
+	primary.Targets = v1.Targets{"192.168.0.3"}
+	g.Expect(reconciler.reconcile(ctx, spec, primary)).To(gomega.Succeed())
+	addresses, calculated = client.addresses()
+	g.Expect(addresses).To(gomega.ConsistOf("192.168.0.3"))
+	g.Expect(calculated).To(gomega.Equal(0))
+	g.Expect(getAddressHealthCheckIds(primary)).To(gomega.BeEmpty())
+
+	// All the health checks are deleted along with the record set
+	primary.Targets = v1.Targets{"192.168.0.1", "192.168.0.2"}
+	g.Expect(reconciler.reconcile(ctx, spec, primary)).To(gomega.Succeed())
+	g.Expect(client.healthChecks).To(gomega.HaveLen(3))
+	g.Expect(reconciler.deleteHealthCheck(ctx, primary)).To(gomega.Succeed())
+	g.Expect(client.healthChecks).To(gomega.BeEmpty())
+	_, ok = getHealthCheckId(primary)
+	g.Expect(ok).To(gomega.BeFalse())
+	g.Expect(getAddressHealthCheckIds(primary)).To(gomega.BeEmpty())
+}
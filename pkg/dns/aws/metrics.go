@@ -75,6 +75,35 @@ var (
 		},
 		[]string{operationLabel, returnCodeLabel},
 	)
+
+	// route53ThrottledRetries is a prometheus counter metrics which holds the
+	// total number of requests to Route53 retried after being throttled.
+	route53ThrottledRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "glbc_aws_route53_throttled_retries_total",
+			Help: "GLBC AWS Route53 total number of retries of throttled requests",
+		},
+		[]string{operationLabel},
+	)
+
+	// route53CircuitBreakerOpen is a prometheus metric which holds whether
+	// the requests to Route53 are suspended by the circuit breaker.
+	route53CircuitBreakerOpen = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "glbc_aws_route53_circuit_breaker_open",
+			Help: "GLBC AWS Route53 circuit breaker open, 1 when the requests are suspended",
+		},
+	)
+
+	// changeBatchRequests is a prometheus metric which records the number
+	// of DNS record changes coalesced into each Route53 change batch.
+	changeBatchRequests = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "glbc_aws_route53_change_batch_requests",
+			Help:    "GLBC AWS Route53 number of DNS record changes per change batch",
+			Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+		},
+	)
 )
 
 var operationLabelValues []string
@@ -86,6 +115,9 @@ func init() {
 		route53RequestTotal,
 		route53RequestErrors,
 		route53RequestDuration,
+		route53ThrottledRetries,
+		route53CircuitBreakerOpen,
+		changeBatchRequests,
 	)
 
 	monitoredRoute53 := reflect.PtrTo(reflect.TypeOf(InstrumentedRoute53{}))
@@ -98,5 +130,6 @@ func init() {
 		route53RequestCount.WithLabelValues(operation).Set(0)
 		route53RequestTotal.WithLabelValues(operation, returnCodeLabelDefault).Add(0)
 		route53RequestErrors.WithLabelValues(operation, returnCodeLabelDefault).Add(0)
+		route53ThrottledRetries.WithLabelValues(operation).Add(0)
 	}
 }
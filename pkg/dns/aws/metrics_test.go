@@ -24,9 +24,13 @@ func TestRegister(t *testing.T) {
 	g := gomega.NewWithT(t)
 	g.Expect(operationLabelValues).To(gomega.ConsistOf(
 		"ListHostedZones",
+		"GetHostedZone",
 		"ChangeResourceRecordSets",
+		"GetChangeWithContext",
+		"ListResourceRecordSetsWithContext",
 		"CreateHealthCheck",
 		"GetHealthCheckWithContext",
+		"GetHealthCheckStatusWithContext",
 		"UpdateHealthCheckWithContext",
 		"DeleteHealthCheckWithContext",
 		"ChangeTagsForResourceWithContext",
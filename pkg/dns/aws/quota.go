@@ -0,0 +1,189 @@
+package aws
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultRequestsPerSecond is the default rate of the requests to Route53, which are limited to five requests per
+	// second per AWS account, see https://docs.aws.amazon.com/Route53/latest/DeveloperGuide/DNSLimitations.html#limits-api-requests.
+	DefaultRequestsPerSecond = 5
+
+	// The number of times, and the base delay, a throttled request is retried with
+	maxThrottlingRetries     = 3
+	throttlingRetryBaseDelay = 200 * time.Millisecond
+
+	// The number of consecutive failed requests the circuit breaker opens after, and the period it stays open for,
+	// before a trial request is let through
+	circuitBreakerFailureThreshold = 5
+	circuitBreakerOpenPeriod       = 30 * time.Second
+)
+
+// route53Quota is shared by all the Route53 clients, as the Route53 rate limit applies to the AWS account
+var route53Quota = newQuota(DefaultRequestsPerSecond, circuitBreakerFailureThreshold, circuitBreakerOpenPeriod)
+
+// ErrCircuitOpen is returned instead of calling Route53 while the circuit breaker is open
+var ErrCircuitOpen error = &circuitOpenError{}
+
+type circuitOpenError struct{}
+
+func (*circuitOpenError) Error() string {
+	return "the Route53 API is unavailable, requests are suspended until it recovers"
+}
+
+// ProviderDegraded reports that the provider is temporarily not calling its API
+func (*circuitOpenError) ProviderDegraded() bool {
+	return true
+}
+
+// quota limits the rate of the requests to Route53, retries the throttled requests, and stops calling Route53
+// altogether while it keeps failing.
+type quota struct {
+	limiter    *rate.Limiter
+	breaker    *circuitBreaker
+	retryDelay time.Duration
+}
+
+func newQuota(requestsPerSecond float64, failureThreshold int, openPeriod time.Duration) *quota {
+	return &quota{
+		limiter:    rate.NewLimiter(rate.Limit(requestsPerSecond), DefaultRequestsPerSecond),
+		breaker:    &circuitBreaker{failureThreshold: failureThreshold, openPeriod: openPeriod, now: time.Now},
+		retryDelay: throttlingRetryBaseDelay,
+	}
+}
+
+// call makes the request once the rate limit allows it, and retries it with a jittered exponential backoff while it
+// is throttled. It returns ErrCircuitOpen without making the request while the circuit breaker is open.
+func (q *quota) call(ctx context.Context, operation string, f func() error) error {
+	if !q.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	for retries := 0; ; retries++ {
+		if err := q.limiter.Wait(ctx); err != nil {
+			// The request is not made, so that it cannot be the trial request of the breaker
+			q.breaker.abort()
+			return err
+		}
+		err := observe(operation, f)
+		if err != nil && isThrottlingError(err) && retries < maxThrottlingRetries {
+			route53ThrottledRetries.WithLabelValues(operation).Inc()
+			select {
+			case <-time.After(wait.Jitter(q.retryDelay<<retries, 1)):
+				continue
+			case <-ctx.Done():
+				q.breaker.record(false)
+				return err
+			}
+		}
+		// Only the errors of Route53 being unavailable open the circuit breaker, the other errors being caused by the
+		// requests themselves
+		q.breaker.record(err == nil || !isUnavailableError(err))
+		return err
+	}
+}
+
+// isThrottlingError returns whether the request was rejected because of the rate limit of Route53, or because a
+// previous request for the same resource is still being processed
+func isThrottlingError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case "Throttling", "ThrottlingException", "PriorRequestNotComplete":
+			return true
+		}
+	}
+	return false
+}
+
+// isUnavailableError returns whether the request failed because Route53 is unavailable, i.e. it is throttled, fails
+// with a server error, or cannot be reached
+func isUnavailableError(err error) bool {
+	if isThrottlingError(err) {
+		return true
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode() >= 500
+	}
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == "RequestError"
+	}
+	return false
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker opens after a number of consecutive failures, and lets a single trial request through once it has
+// been open for a period of time. It closes when the trial request succeeds, and opens again otherwise.
+type circuitBreaker struct {
+	failureThreshold int
+	openPeriod       time.Duration
+	now              func() time.Time
+
+	lock     sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.openPeriod {
+			return false
+		}
+		b.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		// The trial request is in flight
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if success {
+		b.failures = 0
+		b.setState(circuitClosed)
+		return
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.failureThreshold {
+		b.openedAt = b.now()
+		b.setState(circuitOpen)
+	}
+}
+
+// abort reports that the request let through has not been made, e.g. its context was cancelled while it waited for
+// the rate limit, so that the next request is let through as the trial request instead, while the breaker is half-open
+func (b *circuitBreaker) abort() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == circuitHalfOpen {
+		b.setState(circuitOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(state circuitState) {
+	b.state = state
+	if state == circuitOpen {
+		route53CircuitBreakerOpen.Set(1)
+	} else {
+		route53CircuitBreakerOpen.Set(0)
+	}
+}
@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/onsi/gomega"
+)
+
+func testQuota(failureThreshold int, openPeriod time.Duration) *quota {
+	q := newQuota(1000, failureThreshold, openPeriod)
+	q.retryDelay = time.Millisecond
+	return q
+}
+
+func TestQuotaRetriesThrottledRequests(t *testing.T) {
+	g := gomega.NewWithT(t)
+	q := testQuota(circuitBreakerFailureThreshold, circuitBreakerOpenPeriod)
+
+	calls := 0
+	err := q.call(context.Background(), "Test", func() error {
+		calls++
+		if calls < 3 {
+			return awserr.New("Throttling", "Rate exceeded", nil)
+		}
+		return nil
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(calls).To(gomega.Equal(3))
+
+	calls = 0
+	err = q.call(context.Background(), "Test", func() error {
+		calls++
+		return awserr.New("PriorRequestNotComplete", "The request was rejected", nil)
+	})
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(calls).To(gomega.Equal(maxThrottlingRetries + 1))
+
+	calls = 0
+	err = q.call(context.Background(), "Test", func() error {
+		calls++
+		return awserr.New("InvalidChangeBatch", "The request is invalid", nil)
+	})
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(calls).To(gomega.Equal(1))
+}
+
+func TestQuotaCircuitBreaker(t *testing.T) {
+	g := gomega.NewWithT(t)
+	q := testQuota(2, time.Minute)
+	now := time.Now()
+	q.breaker.now = func() time.Time { return now }
+
+	unavailable := func() error {
+		return awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Service unavailable", nil), 503, "id")
+	}
+	invalid := func() error {
+		return awserr.NewRequestFailure(awserr.New("InvalidInput", "Invalid input", nil), 400, "id")
+	}
+	succeed := func() error { return nil }
+
+	// The errors caused by the requests themselves do not open the breaker
+	for i := 0; i < 3; i++ {
+		g.Expect(q.call(context.Background(), "Test", invalid)).NotTo(gomega.Equal(ErrCircuitOpen))
+	}
+
+	g.Expect(q.call(context.Background(), "Test", unavailable)).To(gomega.HaveOccurred())
+	g.Expect(q.call(context.Background(), "Test", unavailable)).To(gomega.HaveOccurred())
+	g.Expect(q.call(context.Background(), "Test", succeed)).To(gomega.Equal(ErrCircuitOpen))
+
+	// A failed trial request opens the breaker again
+	now = now.Add(time.Minute)
+	g.Expect(q.call(context.Background(), "Test", unavailable)).NotTo(gomega.Equal(ErrCircuitOpen))
+	g.Expect(q.call(context.Background(), "Test", succeed)).To(gomega.Equal(ErrCircuitOpen))
+
+	// A successful trial request closes the breaker
+	now = now.Add(time.Minute)
+	g.Expect(q.call(context.Background(), "Test", succeed)).NotTo(gomega.HaveOccurred())
+	g.Expect(q.call(context.Background(), "Test", succeed)).NotTo(gomega.HaveOccurred())
+}
+
+func TestQuotaCircuitBreakerAbortedTrial(t *testing.T) {
+	g := gomega.NewWithT(t)
+	q := testQuota(1, time.Minute)
+	now := time.Now()
+	q.breaker.now = func() time.Time { return now }
+
+	unavailable := func() error {
+		return awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Service unavailable", nil), 503, "id")
+	}
+	succeed := func() error { return nil }
+
+	g.Expect(q.call(context.Background(), "Test", unavailable)).To(gomega.HaveOccurred())
+	g.Expect(q.call(context.Background(), "Test", succeed)).To(gomega.Equal(ErrCircuitOpen))
+
+	// The trial request cancelled while it waits for the rate limit does not leave the breaker half-open
+	now = now.Add(time.Minute)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	g.Expect(q.call(cancelled, "Test", succeed)).To(gomega.MatchError(context.Canceled))
+	g.Expect(q.call(context.Background(), "Test", succeed)).NotTo(gomega.HaveOccurred())
+	g.Expect(q.call(context.Background(), "Test", succeed)).NotTo(gomega.HaveOccurred())
+}
+
+func TestCircuitOpenErrorIsDegraded(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	// The error is wrapped by the provider when updating the records
+	err := fmt.Errorf("failed to update record in zone Z1: %w", fmt.Errorf("couldn't update DNS record: %w", ErrCircuitOpen))
+
+	var degraded interface{ ProviderDegraded() bool }
+	g.Expect(errors.As(err, &degraded)).To(gomega.BeTrue())
+	g.Expect(degraded.ProviderDegraded()).To(gomega.BeTrue())
+}
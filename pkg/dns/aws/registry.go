@@ -0,0 +1,288 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+const (
+	// DefaultOwnerID is the owner the record sets are marked with, when no owner ID is configured
+	DefaultOwnerID = "kcp-glbc"
+
+	// The heritage, and the keys of the attributes, of the TXT records marking the ownership of the record sets
+	registryHeritage          = "kcp-glbc"
+	registryOwnerKey          = "kcp-glbc/owner"
+	registryResourceKey       = "kcp-glbc/resource"
+	registrySetIdentifiersKey = "kcp-glbc/set-identifiers"
+
+	// registryRecordPrefix prefixes the names of the TXT records marking the ownership of the record sets
+	registryRecordPrefix = "kcp-glbc-"
+	registryRecordTTL    = 300
+
+	// maxTXTStringLength is the length of the character strings a TXT record value is split into
+	maxTXTStringLength = 255
+)
+
+// registryRecordName returns the name of the TXT record marking the ownership of the record sets with the given name
+// and type, e.g. kcp-glbc-cname-app.example.com for the CNAME record sets of app.example.com. The ownership is not
+// marked with a TXT record of the same name, as no other record can be published alongside a CNAME record.
+func registryRecordName(name, recordType string) string {
+	return registryRecordPrefix + strings.ToLower(recordType) + "-" + normalizeRecordName(name)
+}
+
+// parseRegistryRecordName returns the name and the type of the record sets whose ownership is marked with the TXT
+// record of the given name.
+func parseRegistryRecordName(name string) (string, string, bool) {
+	name = normalizeRecordName(name)
+	if !strings.HasPrefix(name, registryRecordPrefix) {
+		return "", "", false
+	}
+	name = strings.TrimPrefix(name, registryRecordPrefix)
+	i := strings.Index(name, "-")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", false
+	}
+	return name[i+1:], strings.ToUpper(name[:i]), true
+}
+
+// registryRecordValue returns the value of the TXT record marking the ownership of the record sets with the given set
+// identifiers, by the given owner, for the DNSRecord with the given key. The set identifiers are escaped, and the
+// value is split into character strings of at most 255 characters, the limit of a TXT record string.
+func registryRecordValue(owner, resource string, setIdentifiers []string) string {
+	escaped := make([]string, len(setIdentifiers))
+	for i, setIdentifier := range setIdentifiers {
+		escaped[i] = url.QueryEscape(setIdentifier)
+	}
+	value := fmt.Sprintf("heritage=%s,%s=%s,%s=%s,%s=%s", registryHeritage, registryOwnerKey, owner, registryResourceKey, resource, registrySetIdentifiersKey, strings.Join(escaped, "|"))
+	var quoted []string
+	for len(value) > maxTXTStringLength {
+		quoted = append(quoted, "\""+value[:maxTXTStringLength]+"\"")
+		value = value[maxTXTStringLength:]
+	}
+	return strings.Join(append(quoted, "\""+value+"\""), " ")
+}
+
+// parseRegistryRecordValue returns the owner, the key of the DNSRecord, and the set identifiers of the record sets, of
+// the TXT record value. A value without set identifiers marks the record set without set identifier. It returns false
+// for the TXT records not written by GLBC.
+func parseRegistryRecordValue(value string) (string, string, []string, bool) {
+	attributes := map[string]string{}
+	value = strings.ReplaceAll(strings.Trim(value, "\""), "\" \"", "")
+	for _, attribute := range strings.Split(value, ",") {
+		key, attributeValue, ok := strings.Cut(attribute, "=")
+		if !ok {
+			return "", "", nil, false
+		}
+		attributes[key] = attributeValue
+	}
+	owner, resource := attributes[registryOwnerKey], attributes[registryResourceKey]
+	if attributes["heritage"] != registryHeritage || owner == "" || resource == "" {
+		return "", "", nil, false
+	}
+	var setIdentifiers []string
+	for _, escaped := range strings.Split(attributes[registrySetIdentifiersKey], "|") {
+		setIdentifier, err := url.QueryUnescape(escaped)
+		if err != nil {
+			return "", "", nil, false
+		}
+		setIdentifiers = append(setIdentifiers, setIdentifier)
+	}
+	return owner, resource, setIdentifiers, true
+}
+
+// registryChanges returns the changes upserting the TXT records that mark the ownership of the record sets upserted by
+// the changes, for the DNSRecord with the given key. Each TXT record lists the set identifiers of the record sets it
+// marks, so that the record sets of the same name and type published by others are not deleted along with them, and
+// is rewritten when the set identifiers change. The markers, indexed by name, of the record sets of a name and type
+// that are only deleted by the changes are deleted along with them.
+func (p *Provider) registryChanges(changes []*route53.Change, resource string, markers map[string]*route53.ResourceRecordSet) []*route53.Change {
+	var names, deleted []string
+	marked := map[string]sets.String{}
+	for _, change := range changes {
+		name := registryRecordName(aws.StringValue(change.ResourceRecordSet.Name), aws.StringValue(change.ResourceRecordSet.Type))
+		if aws.StringValue(change.Action) != route53.ChangeActionUpsert {
+			deleted = append(deleted, name)
+			continue
+		}
+		if _, ok := marked[name]; !ok {
+			names = append(names, name)
+			marked[name] = sets.NewString()
+		}
+		marked[name].Insert(aws.StringValue(change.ResourceRecordSet.SetIdentifier))
+	}
+	var registry []*route53.Change
+	for _, name := range sets.NewString(deleted...).List() {
+		if marker, ok := markers[name]; ok && marked[name] == nil {
+			registry = append(registry, &route53.Change{Action: aws.String(route53.ChangeActionDelete), ResourceRecordSet: marker})
+		}
+	}
+	for _, name := range names {
+		registry = append(registry, &route53.Change{
+			Action: aws.String(route53.ChangeActionUpsert),
+			ResourceRecordSet: &route53.ResourceRecordSet{
+				Name:            aws.String(name),
+				Type:            aws.String(route53.RRTypeTxt),
+				TTL:             aws.Int64(registryRecordTTL),
+				ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(registryRecordValue(p.ownerID(), resource, marked[name].List()))}},
+			},
+		})
+	}
+	return registry
+}
+
+// registryMarkers returns the live TXT records marking the ownership, by the provider for the DNSRecord with the given
+// key, of the record sets deleted by the changes, indexed by name. The names whose record sets are upserted by the
+// changes as well are not listed, as their markers are rewritten.
+func (p *Provider) registryMarkers(ctx context.Context, zoneID, resource string, changes []*route53.Change) (map[string]*route53.ResourceRecordSet, error) {
+	upserted, deleted := sets.NewString(), sets.NewString()
+	for _, change := range changes {
+		name := registryRecordName(aws.StringValue(change.ResourceRecordSet.Name), aws.StringValue(change.ResourceRecordSet.Type))
+		if aws.StringValue(change.Action) == route53.ChangeActionUpsert {
+			upserted.Insert(name)
+		} else {
+			deleted.Insert(name)
+		}
+	}
+	markers := map[string]*route53.ResourceRecordSet{}
+	for _, name := range deleted.Difference(upserted).List() {
+		live := map[string]*route53.ResourceRecordSet{}
+		if err := p.listRecordSets(ctx, zoneID, name, live); err != nil {
+			return nil, err
+		}
+		marker, ok := live[recordSetKey(&route53.ResourceRecordSet{Name: aws.String(name), Type: aws.String(route53.RRTypeTxt)})]
+		if !ok {
+			continue
+		}
+		if _, _, markedFor, owned := p.ownership(marker); owned && markedFor == resource {
+			markers[name] = marker
+		}
+	}
+	return markers, nil
+}
+
+func (p *Provider) ownerID() string {
+	if p.config.OwnerID == "" {
+		return DefaultOwnerID
+	}
+	return p.config.OwnerID
+}
+
+// ownedRecordKey returns the key identifying the record sets with the given name and type
+func ownedRecordKey(name, recordType string) string {
+	return normalizeRecordName(name) + "/" + recordType
+}
+
+// OwnedRecords returns the keys of the record sets of the hosted zone marked with the owner ID of the provider, indexed
+// by the key of the DNSRecord they have been published for. The ownership marks of the record sets already deleted are
+// returned as well, so that they are deleted along with their DNSRecord.
+func (p *Provider) OwnedRecords(ctx context.Context, zone v1.DNSZone) (map[string][]string, error) {
+	owned := map[string][]string{}
+	input := &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zone.ID)}
+	for {
+		output, err := p.route53.ListResourceRecordSetsWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't list record sets in zone %s: %w", zone.ID, err)
+		}
+		for _, recordSet := range output.ResourceRecordSets {
+			if name, recordType, resource, ok := p.ownership(recordSet); ok {
+				owned[resource] = append(owned[resource], ownedRecordKey(name, recordType))
+			}
+		}
+		if !aws.BoolValue(output.IsTruncated) {
+			return owned, nil
+		}
+		input.StartRecordName = output.NextRecordName
+		input.StartRecordType = output.NextRecordType
+		input.StartRecordIdentifier = output.NextRecordIdentifier
+	}
+}
+
+// ownership returns the name and the type of the record sets, and the key of the DNSRecord, the TXT record marks as
+// owned by the provider.
+func (p *Provider) ownership(recordSet *route53.ResourceRecordSet) (string, string, string, bool) {
+	if aws.StringValue(recordSet.Type) != route53.RRTypeTxt || len(recordSet.ResourceRecords) != 1 {
+		return "", "", "", false
+	}
+	name, recordType, ok := parseRegistryRecordName(aws.StringValue(recordSet.Name))
+	if !ok {
+		return "", "", "", false
+	}
+	owner, resource, _, ok := parseRegistryRecordValue(aws.StringValue(recordSet.ResourceRecords[0].Value))
+	if !ok || owner != p.ownerID() {
+		return "", "", "", false
+	}
+	return name, recordType, resource, true
+}
+
+// DeleteOwnedRecords deletes the record sets with the given keys, published for the DNSRecord with the given key, along
+// with the TXT records marking their ownership. The ownership is checked against the live hosted zone, and the record
+// sets no longer owned by the provider for the DNSRecord are left untouched.
+func (p *Provider) DeleteOwnedRecords(ctx context.Context, zone v1.DNSZone, resource string, records []string) error {
+	var errs []error
+	for _, key := range records {
+		i := strings.LastIndex(key, "/")
+		if i < 0 {
+			errs = append(errs, fmt.Errorf("invalid owned record key %q", key))
+			continue
+		}
+		if err := p.deleteOwnedRecord(ctx, zone.ID, resource, key[:i], key[i+1:]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+func (p *Provider) deleteOwnedRecord(ctx context.Context, zoneID, resource, name, recordType string) error {
+	registryName := registryRecordName(name, recordType)
+	registry := map[string]*route53.ResourceRecordSet{}
+	if err := p.listRecordSets(ctx, zoneID, registryName, registry); err != nil {
+		return err
+	}
+	marker, ok := registry[recordSetKey(&route53.ResourceRecordSet{Name: aws.String(registryName), Type: aws.String(route53.RRTypeTxt)})]
+	if !ok {
+		return nil
+	}
+	if _, _, markedFor, owned := p.ownership(marker); !owned || markedFor != resource {
+		return nil
+	}
+	_, _, setIdentifiers, _ := parseRegistryRecordValue(aws.StringValue(marker.ResourceRecords[0].Value))
+
+	live := map[string]*route53.ResourceRecordSet{}
+	if err := p.listRecordSets(ctx, zoneID, normalizeRecordName(name), live); err != nil {
+		return err
+	}
+	var changes []*route53.Change
+	for _, recordSet := range markedRecordSets(live, recordType, setIdentifiers) {
+		changes = append(changes, &route53.Change{Action: aws.String(route53.ChangeActionDelete), ResourceRecordSet: recordSet})
+	}
+	// The record sets are deleted along with their ownership mark, in the same change batch
+	changes = append(changes, &route53.Change{Action: aws.String(route53.ChangeActionDelete), ResourceRecordSet: marker})
+	if _, err := p.batcher.Submit(zoneID, changes, p.submitChanges); err != nil {
+		return fmt.Errorf("couldn't delete owned record sets %s %s in zone %s: %w", recordType, name, zoneID, err)
+	}
+	p.logger.Info("Deleted owned DNS record sets", "name", name, "type", recordType, "zone", zoneID, "count", len(changes)-1)
+	return nil
+}
+
+// markedRecordSets returns the live record sets of the given type with the set identifiers marked as owned, the
+// record sets of the same name and type published by others, with other set identifiers, being left untouched
+func markedRecordSets(live map[string]*route53.ResourceRecordSet, recordType string, setIdentifiers []string) []*route53.ResourceRecordSet {
+	marked := sets.NewString(setIdentifiers...)
+	var recordSets []*route53.ResourceRecordSet
+	for _, recordSet := range live {
+		if aws.StringValue(recordSet.Type) == recordType && marked.Has(aws.StringValue(recordSet.SetIdentifier)) {
+			recordSets = append(recordSets, recordSet)
+		}
+	}
+	return recordSets
+}
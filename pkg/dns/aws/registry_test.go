@@ -0,0 +1,164 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/onsi/gomega"
+)
+
+func TestRegistryRecordName(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(registryRecordName("App.Example.com.", route53.RRTypeCname)).To(gomega.Equal("kcp-glbc-cname-app.example.com"))
+	g.Expect(registryRecordName("*.example.com", route53.RRTypeA)).To(gomega.Equal("kcp-glbc-a-*.example.com"))
+
+	cases := map[string][]string{
+		"kcp-glbc-cname-app.example.com":       {"app.example.com", route53.RRTypeCname},
+		`kcp-glbc-a-\052.example.com.`:         {"*.example.com", route53.RRTypeA},
+		"kcp-glbc-aaaa-kcp-glbc-a.example.com": {"kcp-glbc-a.example.com", route53.RRTypeAaaa},
+	}
+	for registryName, expected := range cases {
+		name, recordType, ok := parseRegistryRecordName(registryName)
+		g.Expect(ok).To(gomega.BeTrue(), registryName)
+		g.Expect([]string{name, recordType}).To(gomega.Equal(expected), registryName)
+	}
+	for _, registryName := range []string{"app.example.com", "kcp-glbc-a", "kcp-glbc--app.example.com"} {
+		_, _, ok := parseRegistryRecordName(registryName)
+		g.Expect(ok).To(gomega.BeFalse(), registryName)
+	}
+}
+
+func TestParseRegistryRecordValue(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	owner, resource, setIdentifiers, ok := parseRegistryRecordValue(registryRecordValue("glbc-1", "default/root:org:ws|app", []string{"cluster-1", "a,b|c=d"}))
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(owner).To(gomega.Equal("glbc-1"))
+	g.Expect(resource).To(gomega.Equal("default/root:org:ws|app"))
+	g.Expect(setIdentifiers).To(gomega.Equal([]string{"cluster-1", "a,b|c=d"}))
+
+	// The record set without set identifier is marked by default
+	_, _, setIdentifiers, ok = parseRegistryRecordValue(`"heritage=kcp-glbc,kcp-glbc/owner=glbc-1,kcp-glbc/resource=default/app"`)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(setIdentifiers).To(gomega.Equal([]string{""}))
+
+	// The values exceeding the length of a TXT record string are split into several strings
+	many := make([]string, 50)
+	for i := range many {
+		many[i] = fmt.Sprintf("cluster-%d", i)
+	}
+	value := registryRecordValue("glbc-1", "default/app", many)
+	for _, str := range strings.Split(value, " ") {
+		g.Expect(len(strings.Trim(str, "\""))).To(gomega.BeNumerically("<=", maxTXTStringLength))
+	}
+	_, _, setIdentifiers, ok = parseRegistryRecordValue(value)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(setIdentifiers).To(gomega.Equal(many))
+
+	for _, value := range []string{
+		`"heritage=external-dns,external-dns/owner=default,external-dns/resource=ingress/default/app"`,
+		`"heritage=kcp-glbc,kcp-glbc/owner=glbc-1"`,
+		`"v=spf1 include:example.com ~all"`,
+	} {
+		_, _, _, ok := parseRegistryRecordValue(value)
+		g.Expect(ok).To(gomega.BeFalse(), value)
+	}
+}
+
+func TestRegistryChanges(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider := &Provider{config: Config{OwnerID: "glbc-1"}}
+
+	weighted := upsertChange("app.example.com", "192.0.2.1")
+	weighted.ResourceRecordSet.SetIdentifier = aws.String("cluster-1")
+	other := upsertChange("app.example.com", "192.0.2.2")
+	other.ResourceRecordSet.SetIdentifier = aws.String("cluster-2")
+	deletion := upsertChange("old.example.com", "192.0.2.3")
+	deletion.Action = aws.String(route53.ChangeActionDelete)
+
+	changes := provider.registryChanges([]*route53.Change{deletion, weighted, other}, "default/root:org:ws|app", nil)
+
+	g.Expect(changes).To(gomega.HaveLen(1))
+	g.Expect(aws.StringValue(changes[0].Action)).To(gomega.Equal(route53.ChangeActionUpsert))
+	recordSet := changes[0].ResourceRecordSet
+	g.Expect(aws.StringValue(recordSet.Name)).To(gomega.Equal("kcp-glbc-a-app.example.com"))
+	g.Expect(aws.StringValue(recordSet.Type)).To(gomega.Equal(route53.RRTypeTxt))
+	g.Expect(recordValues(recordSet)).To(gomega.Equal([]string{registryRecordValue("glbc-1", "default/root:org:ws|app", []string{"cluster-1", "cluster-2"})}))
+
+	// The marker of the record sets that are only deleted is deleted along with them
+	marker := upsertChange("kcp-glbc-a-old.example.com", registryRecordValue("glbc-1", "default/root:org:ws|app", nil)).ResourceRecordSet
+	marker.Type = aws.String(route53.RRTypeTxt)
+	markers := map[string]*route53.ResourceRecordSet{"kcp-glbc-a-old.example.com": marker}
+	changes = provider.registryChanges([]*route53.Change{deletion, deletion}, "default/root:org:ws|app", markers)
+	g.Expect(changes).To(gomega.HaveLen(1))
+	g.Expect(aws.StringValue(changes[0].Action)).To(gomega.Equal(route53.ChangeActionDelete))
+	g.Expect(changes[0].ResourceRecordSet).To(gomega.BeIdenticalTo(marker))
+
+	// The marker of the record sets whose set identifiers change is rewritten with the remaining set identifiers
+	removed := upsertChange("app.example.com", "192.0.2.1")
+	removed.Action = aws.String(route53.ChangeActionDelete)
+	removed.ResourceRecordSet.SetIdentifier = aws.String("cluster-1")
+	markers["kcp-glbc-a-app.example.com"] = marker
+	changes = provider.registryChanges([]*route53.Change{removed, other}, "default/root:org:ws|app", markers)
+	g.Expect(changes).To(gomega.HaveLen(1))
+	g.Expect(aws.StringValue(changes[0].Action)).To(gomega.Equal(route53.ChangeActionUpsert))
+	g.Expect(recordValues(changes[0].ResourceRecordSet)).To(gomega.Equal([]string{registryRecordValue("glbc-1", "default/root:org:ws|app", []string{"cluster-2"})}))
+}
+
+func TestOwnership(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider := &Provider{config: Config{OwnerID: "glbc-1"}}
+	marker := func(name, value string) *route53.ResourceRecordSet {
+		return &route53.ResourceRecordSet{
+			Name:            aws.String(name),
+			Type:            aws.String(route53.RRTypeTxt),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(value)}},
+		}
+	}
+
+	name, recordType, resource, ok := provider.ownership(marker("kcp-glbc-cname-app.example.com.", registryRecordValue("glbc-1", "default/app", nil)))
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect([]string{name, recordType, resource}).To(gomega.Equal([]string{"app.example.com", route53.RRTypeCname, "default/app"}))
+
+	// The records of other GLBC instances, or of other tools, are not owned
+	_, _, _, ok = provider.ownership(marker("kcp-glbc-cname-app.example.com.", registryRecordValue("glbc-2", "default/app", nil)))
+	g.Expect(ok).To(gomega.BeFalse())
+	_, _, _, ok = provider.ownership(marker("app.example.com.", registryRecordValue("glbc-1", "default/app", nil)))
+	g.Expect(ok).To(gomega.BeFalse())
+	_, _, _, ok = provider.ownership(upsertChange("kcp-glbc-a-app.example.com", registryRecordValue("glbc-1", "default/app", nil)).ResourceRecordSet)
+	g.Expect(ok).To(gomega.BeFalse())
+}
+
+func TestMarkedRecordSets(t *testing.T) {
+	g := gomega.NewWithT(t)
+	recordSet := func(recordType, setIdentifier string) *route53.ResourceRecordSet {
+		recordSet := &route53.ResourceRecordSet{Name: aws.String("app.example.com."), Type: aws.String(recordType)}
+		if setIdentifier != "" {
+			recordSet.SetIdentifier = aws.String(setIdentifier)
+		}
+		return recordSet
+	}
+	live := map[string]*route53.ResourceRecordSet{}
+	for _, r := range []*route53.ResourceRecordSet{
+		recordSet(route53.RRTypeA, "cluster-1"),
+		recordSet(route53.RRTypeA, "cluster-2"),
+		recordSet(route53.RRTypeA, "external"),
+		recordSet(route53.RRTypeAaaa, "cluster-1"),
+	} {
+		live[recordSetKey(r)] = r
+	}
+
+	// The record sets published by others with the same name and type are not deleted
+	marked := markedRecordSets(live, route53.RRTypeA, []string{"cluster-1", "cluster-2"})
+	g.Expect(marked).To(gomega.ConsistOf(live[recordSetKey(recordSet(route53.RRTypeA, "cluster-1"))], live[recordSetKey(recordSet(route53.RRTypeA, "cluster-2"))]))
+
+	// The record set without set identifier is only deleted when it is marked
+	simple := recordSet(route53.RRTypeCname, "")
+	live = map[string]*route53.ResourceRecordSet{recordSetKey(simple): simple}
+	g.Expect(markedRecordSets(live, route53.RRTypeCname, []string{""})).To(gomega.ConsistOf(simple))
+	g.Expect(markedRecordSets(live, route53.RRTypeCname, []string{"cluster-1"})).To(gomega.BeEmpty())
+}
@@ -0,0 +1,344 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	azuredns "github.com/Azure/azure-sdk-for-go/services/dns/mgmt/2018-05-01/dns"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// WeightPolicy defines how endpoint weights are handled, as Azure DNS has no
+// weighted routing policy.
+type WeightPolicy string
+
+const (
+	// WeightPolicyIgnore publishes all the endpoints sharing a name and type
+	// in a single record set, so that traffic is split evenly between them.
+	// Endpoints with a weight of 0 are left out, as they are not meant to
+	// receive any traffic.
+	WeightPolicyIgnore WeightPolicy = "ignore"
+	// WeightPolicyReject fails to publish endpoints sharing a name and type
+	// that have different non-zero weights, since the requested split cannot
+	// be honoured.
+	WeightPolicyReject WeightPolicy = "reject"
+)
+
+// Provider manages records in Azure DNS zones.
+// The DNSZone ID is the name of the zone within the configured resource group.
+type Provider struct {
+	recordSets azuredns.RecordSetsClient
+	zones      azuredns.ZonesClient
+	config     Config
+	logger     logr.Logger
+}
+
+// Config is the necessary input to configure the manager.
+type Config struct {
+	// SubscriptionID is the Azure subscription owning the DNS zones.
+	SubscriptionID string
+	// ResourceGroup is the resource group of the DNS zones.
+	ResourceGroup string
+	// TenantID, ClientID and ClientSecret are the service principal
+	// credentials used to authenticate against Azure Resource Manager.
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// WeightPolicy defines how endpoint weights are mapped. Defaults to WeightPolicyIgnore.
+	WeightPolicy WeightPolicy
+	// BaseURI overrides the Azure Resource Manager endpoint.
+	BaseURI string
+	// Authorizer overrides the authorizer built from the service principal credentials.
+	Authorizer autorest.Authorizer
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.SubscriptionID == "" || config.ResourceGroup == "" {
+		return nil, fmt.Errorf("an Azure subscription and resource group are required")
+	}
+	switch config.WeightPolicy {
+	case "":
+		config.WeightPolicy = WeightPolicyIgnore
+	case WeightPolicyIgnore, WeightPolicyReject:
+	default:
+		return nil, fmt.Errorf("invalid weight policy %s. Only supported values are %s and %s", config.WeightPolicy, WeightPolicyIgnore, WeightPolicyReject)
+	}
+	if config.BaseURI == "" {
+		config.BaseURI = azure.PublicCloud.ResourceManagerEndpoint
+	}
+
+	authorizer := config.Authorizer
+	if authorizer == nil {
+		oauthConfig, err := adal.NewOAuthConfig(azure.PublicCloud.ActiveDirectoryEndpoint, config.TenantID)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create Azure OAuth config: %v", err)
+		}
+		token, err := adal.NewServicePrincipalToken(*oauthConfig, config.ClientID, config.ClientSecret, azure.PublicCloud.ResourceManagerEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create Azure service principal token: %v", err)
+		}
+		authorizer = autorest.NewBearerAuthorizer(token)
+	}
+
+	p := &Provider{
+		recordSets: azuredns.NewRecordSetsClientWithBaseURI(config.BaseURI, config.SubscriptionID),
+		zones:      azuredns.NewZonesClientWithBaseURI(config.BaseURI, config.SubscriptionID),
+		config:     config,
+		logger:     log.Logger.WithName("azure-dns").WithValues("resourceGroup", config.ResourceGroup),
+	}
+	p.recordSets.Authorizer = authorizer
+	p.zones.Authorizer = authorizer
+
+	if err := validateServiceEndpoints(p); err != nil {
+		return nil, fmt.Errorf("failed to validate Azure provider service endpoints: %v", err)
+	}
+
+	return p, nil
+}
+
+// validateServiceEndpoints validates that the Azure DNS clients can communicate
+// with the API by listing the zones of the resource group.
+func validateServiceEndpoints(provider *Provider) error {
+	var errs []error
+	if _, err := provider.zones.ListByResourceGroup(context.Background(), provider.config.ResourceGroup, to.Int32Ptr(1)); err != nil {
+		errs = append(errs, fmt.Errorf("failed to list Azure DNS zones: %v", err))
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	ctx := context.Background()
+
+	desired, err := p.recordSetsForEndpoints(record.Spec.Endpoints, zone.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range recordSetKeys(desired) {
+		recordSet := desired[key]
+		if recordSet == nil {
+			// Every endpoint of the record set has a weight of 0
+			if err := p.deleteRecordSet(ctx, zone.ID, key); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := p.recordSets.CreateOrUpdate(ctx, p.config.ResourceGroup, zone.ID, key.name, key.recordType, *recordSet, "", ""); err != nil {
+			return fmt.Errorf("couldn't update DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+		}
+	}
+
+	// Delete any previously published record sets that are no longer present in record.Spec.Endpoints
+	lastPublished, err := p.recordSetsForEndpoints(endpointsFromZoneStatus(record, zone.ID), zone.ID)
+	if err != nil {
+		return err
+	}
+	for _, key := range recordSetKeys(lastPublished) {
+		if _, found := desired[key]; found {
+			continue
+		}
+		if err := p.deleteRecordSet(ctx, zone.ID, key); err != nil {
+			return err
+		}
+	}
+
+	p.logger.Info("Upserted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
+	ctx := context.Background()
+
+	desired, err := p.recordSetsForEndpoints(record.Spec.Endpoints, zone.ID)
+	if err != nil {
+		return err
+	}
+	for _, key := range recordSetKeys(desired) {
+		if err := p.deleteRecordSet(ctx, zone.ID, key); err != nil {
+			return err
+		}
+	}
+
+	p.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+// ReconcileHealthCheck is a no-op, as Azure DNS has no health checks. Health
+// based routing requires Azure Traffic Manager, which GLBC does not manage.
+func (p *Provider) ReconcileHealthCheck(_ context.Context, hc v1.HealthCheck, endpoint *v1.Endpoint) error {
+	p.logger.V(3).Info("Health checks are not supported by Azure DNS, skipping", "name", hc.Name, "endpoint", endpoint.SetID())
+	return nil
+}
+
+func (p *Provider) DeleteHealthCheck(_ context.Context, _ *v1.Endpoint) error {
+	return nil
+}
+
+func (p *Provider) deleteRecordSet(ctx context.Context, zoneName string, key recordSetKey) error {
+	// Deleting a record set that does not exist succeeds
+	if _, err := p.recordSets.Delete(ctx, p.config.ResourceGroup, zoneName, key.name, key.recordType, ""); err != nil {
+		return fmt.Errorf("couldn't delete %s record set %s in zone %s: %v", key.recordType, key.name, zoneName, err)
+	}
+	return nil
+}
+
+type recordSetKey struct {
+	name       string
+	recordType azuredns.RecordType
+}
+
+// recordSetsForEndpoints groups the endpoints into Azure DNS record sets, one
+// per relative name and type. A nil record set means that none of the grouped
+// endpoints should receive traffic.
+func (p *Provider) recordSetsForEndpoints(endpoints []*v1.Endpoint, zoneName string) (map[recordSetKey]*azuredns.RecordSet, error) {
+	grouped := map[recordSetKey][]*v1.Endpoint{}
+	for _, endpoint := range endpoints {
+		if len(endpoint.DNSName) == 0 {
+			return nil, fmt.Errorf("domain is required")
+		}
+		if len(endpoint.Targets) == 0 {
+			return nil, fmt.Errorf("targets is required")
+		}
+		name, err := relativeRecordSetName(endpoint.DNSName, zoneName)
+		if err != nil {
+			return nil, err
+		}
+		var recordType azuredns.RecordType
+		switch endpoint.RecordType {
+		case string(v1.ARecordType):
+			recordType = azuredns.A
+		case string(v1.AAAARecordType):
+			recordType = azuredns.AAAA
+		case string(v1.CNAMERecordType):
+			recordType = azuredns.CNAME
+		default:
+			return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+		}
+		key := recordSetKey{name: name, recordType: recordType}
+		grouped[key] = append(grouped[key], endpoint)
+	}
+
+	recordSets := make(map[recordSetKey]*azuredns.RecordSet, len(grouped))
+	for key, group := range grouped {
+		active, err := p.weightedEndpoints(group)
+		if err != nil {
+			return nil, err
+		}
+		if len(active) == 0 {
+			recordSets[key] = nil
+			continue
+		}
+
+		properties := &azuredns.RecordSetProperties{
+			TTL: to.Int64Ptr(int64(active[0].RecordTTL)),
+		}
+		var targets []string
+		for _, endpoint := range active {
+			targets = append(targets, endpoint.Targets...)
+		}
+		sort.Strings(targets)
+		switch key.recordType {
+		case azuredns.A:
+			records := make([]azuredns.ARecord, 0, len(targets))
+			for _, target := range targets {
+				records = append(records, azuredns.ARecord{Ipv4Address: to.StringPtr(target)})
+			}
+			properties.ARecords = &records
+		case azuredns.AAAA:
+			records := make([]azuredns.AaaaRecord, 0, len(targets))
+			for _, target := range targets {
+				records = append(records, azuredns.AaaaRecord{Ipv6Address: to.StringPtr(target)})
+			}
+			properties.AaaaRecords = &records
+		case azuredns.CNAME:
+			if len(targets) > 1 {
+				return nil, fmt.Errorf("a CNAME record set can only have a single target, got %d for %s", len(targets), key.name)
+			}
+			properties.CnameRecord = &azuredns.CnameRecord{Cname: to.StringPtr(targets[0])}
+		}
+		recordSets[key] = &azuredns.RecordSet{RecordSetProperties: properties}
+	}
+	return recordSets, nil
+}
+
+// weightedEndpoints applies the weight policy to a group of endpoints sharing
+// a name and type, and returns the ones that should be published.
+func (p *Provider) weightedEndpoints(endpoints []*v1.Endpoint) ([]*v1.Endpoint, error) {
+	var active []*v1.Endpoint
+	weights := map[int64]struct{}{}
+	for _, endpoint := range endpoints {
+		prop, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight)
+		if !ok {
+			active = append(active, endpoint)
+			continue
+		}
+		weight, err := strconv.ParseInt(prop.Value, 10, 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight %q for endpoint %s", prop.Value, endpoint.SetID())
+		}
+		if weight == 0 {
+			continue
+		}
+		weights[weight] = struct{}{}
+		active = append(active, endpoint)
+	}
+
+	if len(weights) > 1 {
+		if p.config.WeightPolicy == WeightPolicyReject {
+			return nil, fmt.Errorf("endpoints for %s have different weights, which Azure DNS cannot honour", endpoints[0].DNSName)
+		}
+		p.logger.V(3).Info("Ignoring endpoint weights not supported by Azure DNS", "dnsName", endpoints[0].DNSName)
+	}
+	return active, nil
+}
+
+// relativeRecordSetName returns the name of the record set relative to the
+// zone, as expected by the Azure DNS API.
+func relativeRecordSetName(dnsName, zoneName string) (string, error) {
+	name := strings.TrimSuffix(dnsName, ".")
+	zoneName = strings.TrimSuffix(zoneName, ".")
+	if name == zoneName {
+		return "@", nil
+	}
+	if !strings.HasSuffix(name, "."+zoneName) {
+		return "", fmt.Errorf("%s is not in zone %s", dnsName, zoneName)
+	}
+	return strings.TrimSuffix(name, "."+zoneName), nil
+}
+
+func recordSetKeys(recordSets map[recordSetKey]*azuredns.RecordSet) []recordSetKey {
+	keys := make([]recordSetKey, 0, len(recordSets))
+	for key := range recordSets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name == keys[j].name {
+			return keys[i].recordType < keys[j].recordType
+		}
+		return keys[i].name < keys[j].name
+	})
+	return keys
+}
+
+func endpointsFromZoneStatus(record *v1.DNSRecord, zoneID string) []*v1.Endpoint {
+	for _, zoneStatus := range record.Status.Zones {
+		if zoneStatus.DNSZone.ID == zoneID {
+			return zoneStatus.Endpoints
+		}
+	}
+	return []*v1.Endpoint{}
+}
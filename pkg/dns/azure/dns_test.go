@@ -0,0 +1,199 @@
+package azure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	azuredns "github.com/Azure/azure-sdk-for-go/services/dns/mgmt/2018-05-01/dns"
+	"github.com/Azure/go-autorest/autorest"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+const (
+	testSubscription  = "test-subscription"
+	testResourceGroup = "test-rg"
+	testZone          = "example.com"
+)
+
+// fakeAzureDNS is a minimal in-memory implementation of the Azure DNS API
+// endpoints used by the provider.
+type fakeAzureDNS struct {
+	mu         sync.Mutex
+	recordSets map[string]azuredns.RecordSet
+}
+
+func (f *fakeAzureDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	zonesPath := "/subscriptions/" + testSubscription + "/resourceGroups/" + testResourceGroup + "/providers/Microsoft.Network/dnsZones"
+	recordSetPrefix := zonesPath + "/" + testZone + "/"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == zonesPath:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[{"name":"` + testZone + `"}]}`))
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, recordSetPrefix):
+		recordSet := azuredns.RecordSet{}
+		if err := json.NewDecoder(r.Body).Decode(&recordSet); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.recordSets[strings.TrimPrefix(r.URL.Path, recordSetPrefix)] = recordSet
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(recordSet)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, recordSetPrefix):
+		delete(f.recordSets, strings.TrimPrefix(r.URL.Path, recordSetPrefix))
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+	}
+}
+
+func newTestProvider(t *testing.T, weightPolicy WeightPolicy) (*Provider, *fakeAzureDNS) {
+	fake := &fakeAzureDNS{recordSets: map[string]azuredns.RecordSet{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	provider, err := NewProvider(Config{
+		SubscriptionID: testSubscription,
+		ResourceGroup:  testResourceGroup,
+		WeightPolicy:   weightPolicy,
+		BaseURI:        server.URL,
+		Authorizer:     autorest.NullAuthorizer{},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	return provider, fake
+}
+
+func endpoint(name, ip, weight string) *v1.Endpoint {
+	return &v1.Endpoint{
+		DNSName:       name,
+		RecordType:    string(v1.ARecordType),
+		SetIdentifier: ip,
+		Targets:       v1.Targets{ip},
+		RecordTTL:     60,
+		ProviderSpecific: v1.ProviderSpecific{
+			{Name: aws.ProviderSpecificWeight, Value: weight},
+		},
+	}
+}
+
+func aRecords(recordSet azuredns.RecordSet) []string {
+	var ips []string
+	for _, record := range *recordSet.ARecords {
+		ips = append(ips, *record.Ipv4Address)
+	}
+	return ips
+}
+
+func TestProviderEnsure(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider, fake := newTestProvider(t, WeightPolicyIgnore)
+	zone := v1.DNSZone{ID: testZone}
+
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				endpoint("app.example.com", "192.168.0.2", "60"),
+				endpoint("app.example.com", "192.168.0.1", "120"),
+				endpoint("app.example.com", "192.168.0.3", "0"),
+			},
+		},
+	}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.recordSets).To(gomega.HaveKey("A/app"))
+	recordSet := fake.recordSets["A/app"]
+	g.Expect(*recordSet.TTL).To(gomega.Equal(int64(60)))
+	g.Expect(aRecords(recordSet)).To(gomega.Equal([]string{"192.168.0.1", "192.168.0.2"}))
+
+	// Endpoints moved to a new name are removed from the previous record set
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+	record.Spec.Endpoints = []*v1.Endpoint{
+		endpoint("other.example.com", "192.168.0.1", "120"),
+	}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.recordSets).To(gomega.HaveLen(1))
+	g.Expect(fake.recordSets).To(gomega.HaveKey("A/other"))
+}
+
+func TestProviderEnsureWeightPolicyReject(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider, fake := newTestProvider(t, WeightPolicyReject)
+	zone := v1.DNSZone{ID: testZone}
+
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				endpoint("app.example.com", "192.168.0.1", "120"),
+				endpoint("app.example.com", "192.168.0.2", "60"),
+			},
+		},
+	}
+	g.Expect(provider.Ensure(record, zone)).NotTo(gomega.Succeed())
+	g.Expect(fake.recordSets).To(gomega.BeEmpty())
+
+	// Even weights can be honoured
+	record.Spec.Endpoints[1] = endpoint("app.example.com", "192.168.0.2", "120")
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(aRecords(fake.recordSets["A/app"])).To(gomega.Equal([]string{"192.168.0.1", "192.168.0.2"}))
+}
+
+func TestProviderDelete(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider, fake := newTestProvider(t, WeightPolicyIgnore)
+	zone := v1.DNSZone{ID: testZone}
+
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				endpoint(testZone, "192.168.0.1", "120"),
+			},
+		},
+	}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.recordSets).To(gomega.HaveKey("A/@"))
+
+	g.Expect(provider.Delete(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.recordSets).To(gomega.BeEmpty())
+}
+
+func TestRelativeRecordSetName(t *testing.T) {
+	cases := []struct {
+		dnsName  string
+		expected string
+		err      bool
+	}{
+		{dnsName: "app.example.com", expected: "app"},
+		{dnsName: "a.b.example.com.", expected: "a.b"},
+		{dnsName: "example.com", expected: "@"},
+		{dnsName: "app.example.org", err: true},
+		{dnsName: "badexample.com", err: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.dnsName, func(t *testing.T) {
+			name, err := relativeRecordSetName(tc.dnsName, testZone)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name != tc.expected {
+				t.Errorf("expected %s but got %s", tc.expected, name)
+			}
+		})
+	}
+}
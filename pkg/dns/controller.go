@@ -2,19 +2,26 @@ package dns
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/kcp-dev/logicalcluster/v2"
 
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/slice"
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 	kuadrantv1 "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/clientset/versioned"
 	"github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/informers/externalversions"
 	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/embedded"
 	"github.com/kuadrant/kcp-glbc/pkg/reconciler"
 )
 
@@ -28,27 +35,76 @@ func NewController(config *ControllerConfig) (*Controller, error) {
 		Controller:            reconciler.NewController(controllerName, queue),
 		dnsRecordClient:       config.DnsRecordClient,
 		sharedInformerFactory: config.SharedInformerFactory,
+		driftCheckPeriod:      config.DriftCheckPeriod,
+		driftMode:             config.DriftMode,
+		driftChecks:           map[types.UID]time.Time{},
 	}
 	c.Process = c.process
 
-	dnsProvider, err := DNSProvider(config.DNSProvider)
-	if err != nil {
-		return nil, err
+	if c.driftMode == "" {
+		c.driftMode = DriftModeRepair
+	}
+	if !validDriftMode(c.driftMode) {
+		return nil, fmt.Errorf("unsupported DNS drift mode %q", c.driftMode)
 	}
-	c.dnsProvider = dnsProvider
 
-	var dnsZones []v1.DNSZone
-	zoneID, zoneIDSet := os.LookupEnv("AWS_DNS_PUBLIC_ZONE_ID")
-	if zoneIDSet {
-		dnsZone := &v1.DNSZone{
-			ID: zoneID,
+	// The same records can be published with several providers, each of them
+	// managing its own zones, so that they are served by different vendors
+	c.dnsProviders = map[string]Provider{}
+	c.dnsZoneDomains = map[string]string{}
+	c.dnsZoneProviders = map[string]string{}
+	for _, name := range ParseProviders(config.DNSProvider) {
+		if _, ok := c.dnsProviders[name]; ok {
+			continue
 		}
-		dnsZones = append(dnsZones, *dnsZone)
-		c.Logger.Info("Using AWS DNS zone", "id", zoneID)
-	} else {
-		c.Logger.Info("No AWS DNS zone id set (AWS_DNS_PUBLIC_ZONE_ID), no DNS records will be created!")
+		// The zones are configured with a comma separated list of zone IDs, and
+		// with tags for the providers that support zone discovery
+		zoneIDEnvVar := dnsZoneIDEnvVar(name)
+		zoneIDs := parseZoneIDs(os.Getenv(zoneIDEnvVar))
+		zoneTagsEnvVar := dnsZoneTagsEnvVar(name)
+		zoneTags, err := parseZoneTags(os.Getenv(zoneTagsEnvVar))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", zoneTagsEnvVar, err)
+		}
+		var dnsProvider Provider
+		if name == "embedded" {
+			if config.EmbeddedDNSProvider == nil {
+				return nil, fmt.Errorf("the embedded DNS provider is not running")
+			}
+			// The embedded name server answers queries from the informer caches
+			// of all the DNSRecord controllers
+			config.EmbeddedDNSProvider.AddLister(c.sharedInformerFactory.Kuadrant().V1().DNSRecords().Lister())
+			dnsProvider = config.EmbeddedDNSProvider
+			zoneIDs = []string{config.EmbeddedDNSProvider.Zone().ID}
+		} else {
+			dnsProvider, err = DNSProvider(name, config.DNSOwnerID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		c.dnsProviderNames = append(c.dnsProviderNames, name)
+		c.dnsProviders[name] = dnsProvider
+
+		dnsZones, dnsZoneDomains, err := resolveDNSZones(dnsProvider, zoneIDs, zoneTags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s DNS zones: %v", name, err)
+		}
+		for _, zone := range dnsZones {
+			if other, ok := c.dnsZoneProviders[zone.ID]; ok {
+				return nil, fmt.Errorf("DNS zone %s is configured for both the %s and %s providers", zone.ID, other, name)
+			}
+			c.Logger.Info("Using DNS zone", "id", zone.ID, "domain", dnsZoneDomains[zone.ID], "provider", name)
+			c.dnsZones = append(c.dnsZones, zone)
+			c.dnsZoneDomains[zone.ID] = dnsZoneDomains[zone.ID]
+			c.dnsZoneProviders[zone.ID] = name
+		}
+		if len(dnsZones) == 0 {
+			c.Logger.Info(fmt.Sprintf("No DNS zone set (%s), no DNS records will be created with the %s provider!", zoneIDEnvVar, name))
+		}
+	}
+	if len(c.dnsProviders) == 0 {
+		return nil, fmt.Errorf("no DNS provider set")
 	}
-	c.dnsZones = dnsZones
 
 	//Logging state of AWS credentials
 	awsIdKey := os.Getenv("AWS_ACCESS_KEY_ID")
@@ -78,14 +134,125 @@ func NewController(config *ControllerConfig) (*Controller, error) {
 	c.indexer = c.sharedInformerFactory.Kuadrant().V1().DNSRecords().Informer().GetIndexer()
 	c.lister = c.sharedInformerFactory.Kuadrant().V1().DNSRecords().Lister()
 
+	// The orphaned records are collected from the zones shared by all the
+	// DNSRecord controllers
+	if config.GarbageCollector != nil {
+		config.GarbageCollector.addController(c)
+	}
+
 	return c, nil
 }
 
+// dnsZoneIDEnvVar returns the name of the environment variable holding the
+// identifier of the zone managed with the given DNS provider.
+func dnsZoneIDEnvVar(dnsProvider string) string {
+	switch dnsProvider {
+	case "gcp":
+		return "GCP_DNS_MANAGED_ZONE"
+	case "azure":
+		return "AZURE_DNS_ZONE_NAME"
+	case "rfc2136":
+		return "RFC2136_ZONE"
+	case "plugin":
+		return "DNS_PLUGIN_ZONE"
+	default:
+		return "AWS_DNS_PUBLIC_ZONE_ID"
+	}
+}
+
+// dnsZoneTagsEnvVar returns the name of the environment variable holding the
+// tags of the zones managed with the given DNS provider, or an empty string
+// when the provider does not support zone discovery.
+func dnsZoneTagsEnvVar(dnsProvider string) string {
+	switch dnsProvider {
+	case "aws":
+		return "AWS_DNS_PUBLIC_ZONE_TAGS"
+	case "gcp":
+		return "GCP_DNS_MANAGED_ZONE_LABELS"
+	default:
+		return ""
+	}
+}
+
+// FakeProviderAlias is the name of the former fake DNS provider, replaced with the embedded DNS provider
+const FakeProviderAlias = "fake"
+
+// ParseProviders returns the DNS provider names of the comma separated list. The former fake provider is an alias of
+// the embedded provider, so that the existing configurations keep working.
+func ParseProviders(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name == FakeProviderAlias {
+			name = "embedded"
+		}
+		if name != "" && !slice.ContainsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// providers returns the DNS providers, in the configured order.
+func (c *Controller) providers() []Provider {
+	providers := make([]Provider, 0, len(c.dnsProviderNames))
+	for _, name := range c.dnsProviderNames {
+		providers = append(providers, c.dnsProviders[name])
+	}
+	return providers
+}
+
+// RoutingCapabilities returns the routing features of the DNS records supported by all the DNS providers.
+func (c *Controller) RoutingCapabilities() RoutingCapabilities {
+	return RoutingCapabilitiesOf(c.providers()...)
+}
+
+// zonesForProvider returns the zones managed with the given provider.
+func (c *Controller) zonesForProvider(name string) []v1.DNSZone {
+	var zones []v1.DNSZone
+	for _, zone := range c.dnsZones {
+		if c.dnsZoneProviders[zone.ID] == name {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+// providerForZoneStatus returns the provider the record was published with in the zone. The statuses recorded before
+// the provider was recorded are defaulted to the provider currently managing the zone, or to the first provider. It returns
+// false when the provider the record was published with is no longer configured.
+func (c *Controller) providerForZoneStatus(status v1.DNSZoneStatus) (Provider, string, bool) {
+	name := status.Provider
+	if name == "" {
+		name = c.dnsZoneProviders[status.DNSZone.ID]
+	}
+	if name == "" {
+		name = c.dnsProviderNames[0]
+	}
+	provider, ok := c.dnsProviders[name]
+	return provider, name, ok
+}
+
 type ControllerConfig struct {
 	*reconciler.ControllerConfig
 	DnsRecordClient       kuadrantv1.ClusterInterface
 	SharedInformerFactory externalversions.SharedInformerFactory
-	DNSProvider           string
+	// DNSProvider is the comma separated list of the DNS providers the records are published with
+	DNSProvider string
+	// EmbeddedDNSProvider is the name server shared by all the DNSRecord
+	// controllers, when the embedded DNS provider is used
+	EmbeddedDNSProvider *embedded.Provider
+	// DriftCheckPeriod is the period the published records are compared with
+	// the live zones at, zero disables the drift check
+	DriftCheckPeriod time.Duration
+	// DriftMode is what is done with the drifted records, one of repair or
+	// report
+	DriftMode string
+	// DNSOwnerID is the owner the published records are marked with, by the
+	// providers that support it
+	DNSOwnerID string
+	// GarbageCollector deletes the owned records whose DNSRecord no longer
+	// exists, it is shared by all the DNSRecord controllers
+	GarbageCollector *GarbageCollector
 }
 
 type Controller struct {
@@ -94,8 +261,20 @@ type Controller struct {
 	dnsRecordClient       kuadrantv1.ClusterInterface
 	indexer               cache.Indexer
 	lister                kuadrantv1lister.DNSRecordLister
-	dnsProvider           Provider
-	dnsZones              []v1.DNSZone
+	// dnsProviderNames are the names of the providers, in the configured order
+	dnsProviderNames []string
+	dnsProviders     map[string]Provider
+	dnsZones         []v1.DNSZone
+	// dnsZoneDomains are the domain names of the zones, indexed by zone ID
+	dnsZoneDomains map[string]string
+	// dnsZoneProviders are the names of the providers of the zones, indexed by zone ID
+	dnsZoneProviders map[string]string
+	// driftCheckPeriod is the period the published records are compared with the live zones at, zero disables it
+	driftCheckPeriod time.Duration
+	driftMode        string
+	// driftChecks are the times the records were last checked for drift, indexed by record UID
+	driftChecks     map[types.UID]time.Time
+	driftChecksLock sync.Mutex
 }
 
 func (c *Controller) process(ctx context.Context, key string) error {
@@ -123,6 +302,26 @@ func (c *Controller) process(ctx context.Context, key string) error {
 		current.ObjectMeta.ResourceVersion = refresh.ObjectMeta.ResourceVersion
 	}
 
+	// The health of the primary endpoints is polled, as the provider does not notify when records fail over
+	if current.Status.Failover != nil && current.DeletionTimestamp == nil {
+		c.EnqueueAfter(current, failoverStatusResyncPeriod)
+	}
+
+	// The propagation of the changes is polled, as the provider does not notify when they are propagated
+	if hasPendingChanges(current) && current.DeletionTimestamp == nil {
+		c.EnqueueAfter(current, changePropagationPollPeriod)
+	}
+
+	// The live zones are checked for drift periodically
+	if c.hasDriftCheckedZones(current) && current.DeletionTimestamp == nil {
+		c.EnqueueAfter(current, c.driftCheckPeriod)
+	}
+
+	// The records that could not be published while a provider is degraded are published once it recovers
+	if hasProviderDegradedZones(current) {
+		c.EnqueueAfter(current, providerDegradedRequeuePeriod)
+	}
+
 	if !equality.Semantic.DeepEqual(previous, current) {
 		_, err := c.dnsRecordClient.Cluster(logicalcluster.From(current)).KuadrantV1().DNSRecords(current.Namespace).Update(ctx, current, metav1.UpdateOptions{})
 		if err != nil {
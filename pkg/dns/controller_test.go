@@ -0,0 +1,168 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+func TestParseProviders(t *testing.T) {
+	cases := map[string][]string{
+		"":                  nil,
+		"aws":               {"aws"},
+		"aws,gcp":           {"aws", "gcp"},
+		" aws , , gcp ,":    {"aws", "gcp"},
+		"embedded,rfc2136 ": {"embedded", "rfc2136"},
+		"fake":              {"embedded"},
+		"fake,embedded":     {"embedded"},
+	}
+	for value, expected := range cases {
+		if names := ParseProviders(value); !reflect.DeepEqual(names, expected) {
+			t.Errorf("expected providers %v for %q, got %v", expected, value, names)
+		}
+	}
+}
+
+func TestProviderForZoneStatus(t *testing.T) {
+	aws := &mockProvider{}
+	gcp := &mockZoneResolver{}
+	c := &Controller{
+		dnsProviderNames: []string{"aws", "gcp"},
+		dnsProviders:     map[string]Provider{"aws": aws, "gcp": gcp},
+		dnsZoneProviders: map[string]string{"Z1": "aws", "Z2": "gcp"},
+	}
+
+	cases := []struct {
+		name             string
+		status           v1.DNSZoneStatus
+		expectedName     string
+		expectedProvider Provider
+		expectedOK       bool
+	}{
+		{
+			name:             "provider from status",
+			status:           v1.DNSZoneStatus{DNSZone: v1.DNSZone{ID: "Z1"}, Provider: "gcp"},
+			expectedName:     "gcp",
+			expectedProvider: gcp,
+			expectedOK:       true,
+		},
+		{
+			name:             "provider from zone",
+			status:           v1.DNSZoneStatus{DNSZone: v1.DNSZone{ID: "Z2"}},
+			expectedName:     "gcp",
+			expectedProvider: gcp,
+			expectedOK:       true,
+		},
+		{
+			name:             "first provider for unknown zone",
+			status:           v1.DNSZoneStatus{DNSZone: v1.DNSZone{ID: "Z3"}},
+			expectedName:     "aws",
+			expectedProvider: aws,
+			expectedOK:       true,
+		},
+		{
+			name:         "provider no longer configured",
+			status:       v1.DNSZoneStatus{DNSZone: v1.DNSZone{ID: "Z1"}, Provider: "azure"},
+			expectedName: "azure",
+			expectedOK:   false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, name, ok := c.providerForZoneStatus(tc.status)
+			if name != tc.expectedName || ok != tc.expectedOK {
+				t.Fatalf("expected provider %s (%t), got %s (%t)", tc.expectedName, tc.expectedOK, name, ok)
+			}
+			if ok && provider != tc.expectedProvider {
+				t.Errorf("expected provider %v, got %v", tc.expectedProvider, provider)
+			}
+		})
+	}
+}
+
+func TestZonesForProvider(t *testing.T) {
+	c := &Controller{
+		dnsZones:         []v1.DNSZone{{ID: "Z1"}, {ID: "Z2"}, {ID: "Z3"}},
+		dnsZoneProviders: map[string]string{"Z1": "aws", "Z2": "gcp", "Z3": "aws"},
+	}
+	zones := c.zonesForProvider("aws")
+	if expected := []v1.DNSZone{{ID: "Z1"}, {ID: "Z3"}}; !reflect.DeepEqual(zones, expected) {
+		t.Errorf("expected zones %v, got %v", expected, zones)
+	}
+}
+
+type degradedTestError struct{}
+
+func (degradedTestError) Error() string          { return "degraded" }
+func (degradedTestError) ProviderDegraded() bool { return true }
+
+func TestIsProviderDegraded(t *testing.T) {
+	cases := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil":        {err: nil, expected: false},
+		"error":      {err: errors.New("failed"), expected: false},
+		"degraded":   {err: degradedTestError{}, expected: true},
+		"wrapped":    {err: fmt.Errorf("failed to update record: %w", degradedTestError{}), expected: true},
+		"aggregated": {err: utilerrors.NewAggregate([]error{errors.New("failed"), degradedTestError{}}), expected: true},
+	}
+	for name, tc := range cases {
+		if degraded := isProviderDegraded(tc.err); degraded != tc.expected {
+			t.Errorf("%s: expected degraded %t, got %t", name, tc.expected, degraded)
+		}
+	}
+}
+
+type weightedCNAMETestProvider struct {
+	mockProvider
+}
+
+func (*weightedCNAMETestProvider) SupportsWeightedCNAMEs() bool { return true }
+
+type geolocationTestProvider struct {
+	weightedCNAMETestProvider
+}
+
+func (*geolocationTestProvider) SupportsGeolocationRouting() bool { return true }
+
+type latencyTestProvider struct {
+	weightedCNAMETestProvider
+}
+
+func (*latencyTestProvider) SupportsLatencyRouting() bool { return true }
+
+type failoverTestProvider struct {
+	weightedCNAMETestProvider
+}
+
+func (*failoverTestProvider) SupportsFailoverRouting() bool { return true }
+
+func TestRoutingCapabilitiesOf(t *testing.T) {
+	cases := map[string]struct {
+		providers []Provider
+		expected  RoutingCapabilities
+	}{
+		"no provider":         {providers: nil, expected: RoutingCapabilities{}},
+		"weighted CNAMEs":     {providers: []Provider{&weightedCNAMETestProvider{}}, expected: RoutingCapabilities{WeightedCNAMEs: true}},
+		"no weighted CNAMEs":  {providers: []Provider{&mockProvider{}}, expected: RoutingCapabilities{}},
+		"mixed":               {providers: []Provider{&weightedCNAMETestProvider{}, &mockProvider{}}, expected: RoutingCapabilities{}},
+		"all weighted CNAMEs": {providers: []Provider{&weightedCNAMETestProvider{}, &weightedCNAMETestProvider{}}, expected: RoutingCapabilities{WeightedCNAMEs: true}},
+		"geolocation":         {providers: []Provider{&geolocationTestProvider{}}, expected: RoutingCapabilities{WeightedCNAMEs: true, GeolocationRouting: true}},
+		"mixed geolocation":   {providers: []Provider{&geolocationTestProvider{}, &weightedCNAMETestProvider{}}, expected: RoutingCapabilities{WeightedCNAMEs: true}},
+		"latency":             {providers: []Provider{&latencyTestProvider{}}, expected: RoutingCapabilities{WeightedCNAMEs: true, LatencyRouting: true}},
+		"mixed latency":       {providers: []Provider{&latencyTestProvider{}, &geolocationTestProvider{}}, expected: RoutingCapabilities{WeightedCNAMEs: true}},
+		"failover":            {providers: []Provider{&failoverTestProvider{}}, expected: RoutingCapabilities{WeightedCNAMEs: true, FailoverRouting: true}},
+		"mixed failover":      {providers: []Provider{&failoverTestProvider{}, &weightedCNAMETestProvider{}}, expected: RoutingCapabilities{WeightedCNAMEs: true}},
+	}
+	for name, tc := range cases {
+		if capabilities := RoutingCapabilitiesOf(tc.providers...); capabilities != tc.expected {
+			t.Errorf("%s: expected capabilities %+v, got %+v", name, tc.expected, capabilities)
+		}
+	}
+}
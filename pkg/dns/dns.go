@@ -1,7 +1,13 @@
 package dns
 
 import (
+	"errors"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/embedded"
 )
 
 // Provider knows how to manage DNS zones only as pertains to routing.
@@ -15,11 +21,85 @@ type Provider interface {
 	HealthCheckReconciler
 }
 
-var _ Provider = &FakeProvider{fakeHealthCheckReconciler: &fakeHealthCheckReconciler{}}
+var _ Provider = &embedded.Provider{}
+
+// RoutingCapabilities are the routing features of the DNS records a provider supports, beyond a single record set per
+// name and type.
+type RoutingCapabilities struct {
+	// WeightedCNAMEs is whether several weighted CNAME records can be published for the same name, e.g. one per load
+	// balancer host. The hosts are resolved and published as address records otherwise.
+	WeightedCNAMEs bool
+	// GeolocationRouting is whether the records can be routed by the continent of the users, with the
+	// aws/geolocation-continent-code provider specific property. The records are weighted only otherwise.
+	GeolocationRouting bool
+	// LatencyRouting is whether the records can be routed by the latency of the users to the region of the targets,
+	// with the aws/region provider specific property. The records are weighted only otherwise.
+	LatencyRouting bool
+	// FailoverRouting is whether the records can be routed to the secondary targets only while the primary targets are
+	// unhealthy, with the aws/failover provider specific property. The records follow the routing policy otherwise.
+	FailoverRouting bool
+}
+
+// weightedCNAMEProvider is implemented by the providers that support weighted CNAME records
+type weightedCNAMEProvider interface {
+	SupportsWeightedCNAMEs() bool
+}
+
+// geolocationRoutingProvider is implemented by the providers that support geolocation routing
+type geolocationRoutingProvider interface {
+	SupportsGeolocationRouting() bool
+}
+
+// latencyRoutingProvider is implemented by the providers that support latency routing
+type latencyRoutingProvider interface {
+	SupportsLatencyRouting() bool
+}
+
+// failoverRoutingProvider is implemented by the providers that support failover routing
+type failoverRoutingProvider interface {
+	SupportsFailoverRouting() bool
+}
+
+// RoutingCapabilitiesOf returns the routing capabilities supported by all the providers, as the same records are
+// published with each of them.
+func RoutingCapabilitiesOf(providers ...Provider) RoutingCapabilities {
+	if len(providers) == 0 {
+		return RoutingCapabilities{}
+	}
+	capabilities := RoutingCapabilities{WeightedCNAMEs: true, GeolocationRouting: true, LatencyRouting: true, FailoverRouting: true}
+	for _, provider := range providers {
+		weighted, ok := provider.(weightedCNAMEProvider)
+		capabilities.WeightedCNAMEs = capabilities.WeightedCNAMEs && ok && weighted.SupportsWeightedCNAMEs()
+		geolocation, ok := provider.(geolocationRoutingProvider)
+		capabilities.GeolocationRouting = capabilities.GeolocationRouting && ok && geolocation.SupportsGeolocationRouting()
+		latency, ok := provider.(latencyRoutingProvider)
+		capabilities.LatencyRouting = capabilities.LatencyRouting && ok && latency.SupportsLatencyRouting()
+		failover, ok := provider.(failoverRoutingProvider)
+		capabilities.FailoverRouting = capabilities.FailoverRouting && ok && failover.SupportsFailoverRouting()
+	}
+	return capabilities
+}
+
+// providerDegradedRequeuePeriod is the period the records are reconciled again after, while a provider is degraded
+const providerDegradedRequeuePeriod = 30 * time.Second
 
-type FakeProvider struct {
-	*fakeHealthCheckReconciler
+// degradedError is implemented by the errors of the providers that temporarily stopped calling their API, e.g.
+// while it keeps failing or throttling the requests.
+type degradedError interface {
+	ProviderDegraded() bool
 }
 
-func (_ *FakeProvider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error { return nil }
-func (_ *FakeProvider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error { return nil }
+// isProviderDegraded returns whether the error, or any of the aggregated errors, reports a degraded provider
+func isProviderDegraded(err error) bool {
+	var aggregate utilerrors.Aggregate
+	if errors.As(err, &aggregate) {
+		for _, err := range aggregate.Errors() {
+			if isProviderDegraded(err) {
+				return true
+			}
+		}
+		return false
+	}
+	var degraded degradedError
+	return errors.As(err, &degraded) && degraded.ProviderDegraded()
+}
@@ -2,25 +2,60 @@ package dns
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"time"
 
 	dnsAWS "github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	dnsAzure "github.com/kuadrant/kcp-glbc/pkg/dns/azure"
+	dnsGCP "github.com/kuadrant/kcp-glbc/pkg/dns/gcp"
+	dnsPlugin "github.com/kuadrant/kcp-glbc/pkg/dns/plugin"
+	dnsRFC2136 "github.com/kuadrant/kcp-glbc/pkg/dns/rfc2136"
 )
 
-func DNSProvider(dnsProviderName string) (Provider, error) {
+// DNSProvider returns the DNS provider with the given name. The providers that support it mark the records they
+// publish with the given owner ID.
+func DNSProvider(dnsProviderName, ownerID string) (Provider, error) {
 	var dnsProvider Provider
 	var dnsError error
 	switch dnsProviderName {
 	case "aws":
-		dnsProvider, dnsError = newAWSDNSProvider()
+		dnsProvider, dnsError = newAWSDNSProvider(ownerID)
+	case "gcp":
+		dnsProvider, dnsError = newGCPDNSProvider()
+	case "azure":
+		dnsProvider, dnsError = newAzureDNSProvider()
+	case "rfc2136":
+		dnsProvider, dnsError = newRFC2136DNSProvider()
+	case "plugin":
+		dnsProvider, dnsError = newPluginDNSProvider()
 	default:
-		dnsProvider = &FakeProvider{}
+		dnsError = fmt.Errorf("unsupported DNS provider %q", dnsProviderName)
 	}
 	return dnsProvider, dnsError
 }
 
-func newAWSDNSProvider() (Provider, error) {
+func newAWSDNSProvider(ownerID string) (Provider, error) {
 	var dnsProvider Provider
-	provider, err := dnsAWS.NewProvider(dnsAWS.Config{})
+	batchWindow := dnsAWS.DefaultChangeBatchWindow
+	if value := os.Getenv("AWS_DNS_CHANGE_BATCH_WINDOW"); value != "" {
+		var err error
+		if batchWindow, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid AWS_DNS_CHANGE_BATCH_WINDOW %q: %v", value, err)
+		}
+	}
+	var requestsPerSecond float64
+	if value := os.Getenv("AWS_ROUTE53_REQUESTS_PER_SECOND"); value != "" {
+		var err error
+		if requestsPerSecond, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("invalid AWS_ROUTE53_REQUESTS_PER_SECOND %q: %v", value, err)
+		}
+	}
+	provider, err := dnsAWS.NewProvider(dnsAWS.Config{
+		ChangeBatchWindow: batchWindow,
+		RequestsPerSecond: requestsPerSecond,
+		OwnerID:           ownerID,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create AWS DNS manager: %v", err)
 	}
@@ -28,3 +63,56 @@ func newAWSDNSProvider() (Provider, error) {
 
 	return dnsProvider, nil
 }
+
+func newGCPDNSProvider() (Provider, error) {
+	provider, err := dnsGCP.NewProvider(dnsGCP.Config{
+		Project: os.Getenv("GCP_PROJECT_ID"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCP DNS manager: %v", err)
+	}
+
+	return provider, nil
+}
+
+func newAzureDNSProvider() (Provider, error) {
+	provider, err := dnsAzure.NewProvider(dnsAzure.Config{
+		SubscriptionID: os.Getenv("AZURE_SUBSCRIPTION_ID"),
+		ResourceGroup:  os.Getenv("AZURE_RESOURCE_GROUP"),
+		TenantID:       os.Getenv("AZURE_TENANT_ID"),
+		ClientID:       os.Getenv("AZURE_CLIENT_ID"),
+		ClientSecret:   os.Getenv("AZURE_CLIENT_SECRET"),
+		WeightPolicy:   dnsAzure.WeightPolicy(os.Getenv("AZURE_DNS_WEIGHT_POLICY")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure DNS manager: %v", err)
+	}
+
+	return provider, nil
+}
+
+func newRFC2136DNSProvider() (Provider, error) {
+	provider, err := dnsRFC2136.NewProvider(dnsRFC2136.Config{
+		Nameserver:    os.Getenv("RFC2136_NAMESERVER"),
+		Net:           os.Getenv("RFC2136_NET"),
+		TSIGKeyName:   os.Getenv("RFC2136_TSIG_KEY_NAME"),
+		TSIGSecret:    os.Getenv("RFC2136_TSIG_SECRET"),
+		TSIGAlgorithm: os.Getenv("RFC2136_TSIG_ALGORITHM"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RFC 2136 DNS manager: %v", err)
+	}
+
+	return provider, nil
+}
+
+func newPluginDNSProvider() (Provider, error) {
+	provider, err := dnsPlugin.NewProvider(dnsPlugin.Config{
+		URL: os.Getenv("DNS_PLUGIN_URL"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DNS plugin manager: %v", err)
+	}
+
+	return provider, nil
+}
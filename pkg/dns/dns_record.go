@@ -24,6 +24,10 @@ type ConditionStatus string
 const (
 	DNSRecordFinalizer = "kuadrant.dev/dns-record"
 
+	// providerDegradedReason is the reason of the zone conditions of the records not published because the provider
+	// is degraded
+	providerDegradedReason = "ProviderDegraded"
+
 	ConditionTrue    ConditionStatus = "True"
 	ConditionFalse   ConditionStatus = "False"
 	ConditionUnknown ConditionStatus = "Unknown"
@@ -34,16 +38,17 @@ func (c *Controller) reconcile(ctx context.Context, dnsRecord *v1.DNSRecord) err
 	// If the DNS record was deleted, clean up and return.
 	if dnsRecord.DeletionTimestamp != nil && !dnsRecord.DeletionTimestamp.IsZero() {
 		if err := c.reconcileHealthCheckDeletion(ctx, dnsRecord); err != nil {
-			return err
+			return c.requeueIfProviderDegraded(dnsRecord, err)
 		}
 
 		c.Logger.Info("Deleting DNSRecord", "dnsRecord", dnsRecord)
 		if err := c.deleteRecord(dnsRecord); err != nil && !strings.Contains(err.Error(), "was not found") {
 			c.Logger.Error(err, "Failed to delete DNSRecord", "record", dnsRecord)
-			return err
+			return c.requeueIfProviderDegraded(dnsRecord, err)
 		}
 
 		metadata.RemoveFinalizer(dnsRecord, DNSRecordFinalizer)
+		c.forgetDriftChecks(dnsRecord)
 
 		return nil
 	}
@@ -58,18 +63,57 @@ func (c *Controller) reconcile(ctx context.Context, dnsRecord *v1.DNSRecord) err
 		dnsRecord.Status.ObservedGeneration = dnsRecord.Generation
 	}
 
+	if err := c.reconcileChangePropagation(ctx, dnsRecord); err != nil {
+		c.Logger.Error(err, "Failed to reconcile change propagation for DNSRecord", "record", dnsRecord)
+		return c.requeueIfProviderDegraded(dnsRecord, err)
+	}
+
+	if err := c.reconcileDrift(ctx, dnsRecord); err != nil {
+		c.Logger.Error(err, "Failed to reconcile drift of DNSRecord", "record", dnsRecord)
+		return c.requeueIfProviderDegraded(dnsRecord, err)
+	}
+
 	if err := c.ReconcileHealthChecks(ctx, dnsRecord); err != nil {
 		c.Logger.Error(err, "Failed to reconcile health check for DNSRecord", "record", dnsRecord)
-		return err
+		return c.requeueIfProviderDegraded(dnsRecord, err)
+	}
+
+	if err := c.reconcileFailoverStatus(ctx, dnsRecord); err != nil {
+		c.Logger.Error(err, "Failed to reconcile failover status for DNSRecord", "record", dnsRecord)
+		return c.requeueIfProviderDegraded(dnsRecord, err)
 	}
 
 	return nil
 }
 
+// requeueIfProviderDegraded reconciles the record again once the provider may have recovered, rather than returning
+// the error, so that the record is not dropped from the queue after the maximum number of retries while the provider
+// is degraded.
+func (c *Controller) requeueIfProviderDegraded(dnsRecord *v1.DNSRecord, err error) error {
+	if !isProviderDegraded(err) {
+		return err
+	}
+	c.Logger.Info("DNS provider is degraded, reconciling DNSRecord again later", "record", dnsRecord.Name, "after", providerDegradedRequeuePeriod)
+	c.EnqueueAfter(dnsRecord, providerDegradedRequeuePeriod)
+	return nil
+}
+
 func (c *Controller) publishRecordToZones(zones []v1.DNSZone, record *v1.DNSRecord) []v1.DNSZoneStatus {
 	var statuses []v1.DNSZoneStatus
 	for i := range zones {
 		zone := zones[i]
+		providerName := c.dnsZoneProviders[zone.ID]
+		dnsProvider := c.dnsProviders[providerName]
+
+		// Each endpoint is published to the zone of each provider whose domain is the longest suffix of its name
+		providerZones := c.zonesForProvider(providerName)
+		zoneRecord, unmatched := recordForZone(providerZones, c.dnsZoneDomains, record, zone)
+		if providerZones[0].ID == zone.ID && len(unmatched) > 0 {
+			c.Logger.Info("Skipping endpoints that do not belong to any of the DNS zones", "record", record.Name, "provider", providerName, "endpoints", unmatched)
+		}
+		if len(zoneRecord.Spec.Endpoints) == 0 && len(endpointsFromZoneStatus(record, zone)) == 0 {
+			continue
+		}
 
 		// Only publish the record if the DNSRecord has been modified
 		// (which would mean the target could have changed) or its
@@ -85,56 +129,109 @@ func (c *Controller) publishRecordToZones(zones []v1.DNSZone, record *v1.DNSReco
 			LastTransitionTime: metav1.Now(),
 		}
 
+		endpoints := zoneRecord.Spec.Endpoints
+		var changeID string
+		var err error
 		if recordIsAlreadyPublishedToZone(record, &zone) {
 			c.Logger.Info("replacing DNS record", "record", record, "zone", zone)
 
-			if err := c.dnsProvider.Ensure(record, zone); err != nil {
-				c.Logger.Error(err, "Failed to replace DNS record in zone", "record", record.Spec, "zone", zone)
+			if changeID, err = ensure(dnsProvider, zoneRecord, zone); isProviderDegraded(err) {
+				endpoints = providerDegradedCondition(&condition, record, zone, err)
+			} else if err != nil {
+				c.Logger.Error(err, "Failed to replace DNS record in zone", "record", zoneRecord.Spec, "zone", zone, "provider", providerName)
 				condition.Status = string(ConditionTrue)
 				condition.Reason = "ProviderError"
 				condition.Message = fmt.Sprintf("The DNS provider failed to replace the record: %v", err)
 			} else {
-				c.Logger.Info("Replaced DNS record in zone", "record", record.Spec, "zone", zone)
+				c.Logger.Info("Replaced DNS record in zone", "record", zoneRecord.Spec, "zone", zone)
 				condition.Status = string(ConditionFalse)
 				condition.Reason = "ProviderSuccess"
 				condition.Message = "The DNS provider succeeded in replacing the record"
 			}
 		} else {
-			if err := c.dnsProvider.Ensure(record, zone); err != nil {
-				c.Logger.Error(err, "Failed to publish DNS record to zone", "record", record.Spec, "zone", zone)
+			if changeID, err = ensure(dnsProvider, zoneRecord, zone); isProviderDegraded(err) {
+				endpoints = providerDegradedCondition(&condition, record, zone, err)
+			} else if err != nil {
+				c.Logger.Error(err, "Failed to publish DNS record to zone", "record", zoneRecord.Spec, "zone", zone, "provider", providerName)
 				condition.Status = string(ConditionTrue)
 				condition.Reason = "ProviderError"
 				condition.Message = fmt.Sprintf("The DNS provider failed to ensure the record: %v", err)
 			} else {
-				c.Logger.Info("Published DNS record to zone", "record", record.Spec, "zone", zone)
+				c.Logger.Info("Published DNS record to zone", "record", zoneRecord.Spec, "zone", zone)
 				condition.Status = string(ConditionFalse)
 				condition.Reason = "ProviderSuccess"
 				condition.Message = "The DNS provider succeeded in ensuring the record"
 			}
 		}
+		// The record is only served once the change is propagated to the name servers of the provider
+		propagated := propagatedCondition(changeID)
+		if err != nil {
+			propagated = v1.DNSZoneCondition{
+				Type:    v1.DNSRecordPropagatedConditionType,
+				Status:  string(ConditionFalse),
+				Reason:  condition.Reason,
+				Message: "The record has not been submitted to the DNS provider",
+			}
+		}
+		propagated.LastTransitionTime = condition.LastTransitionTime
 		statuses = append(statuses, v1.DNSZoneStatus{
 			DNSZone:    zone,
-			Conditions: []v1.DNSZoneCondition{condition},
-			Endpoints:  record.Spec.Endpoints,
+			Provider:   providerName,
+			ChangeID:   changeID,
+			Conditions: []v1.DNSZoneCondition{condition, propagated},
+			Endpoints:  endpoints,
 		})
 	}
 	return mergeStatuses(zones, record.Status.DeepCopy().Zones, statuses)
 }
 
+// hasProviderDegradedZones returns whether the record could not be published to any of the zones because of a
+// degraded provider
+func hasProviderDegradedZones(record *v1.DNSRecord) bool {
+	for _, zone := range record.Status.Zones {
+		for _, condition := range zone.Conditions {
+			if condition.Type == v1.DNSRecordFailedConditionType && condition.Reason == providerDegradedReason {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// providerDegradedCondition sets the condition of a record that could not be published to the zone because the
+// provider is degraded. Its status is unknown rather than failed, as the record is published once the provider
+// recovers. It returns the endpoints previously published to the zone, that are still served.
+func providerDegradedCondition(condition *v1.DNSZoneCondition, record *v1.DNSRecord, zone v1.DNSZone, err error) []*v1.Endpoint {
+	condition.Status = string(ConditionUnknown)
+	condition.Reason = providerDegradedReason
+	condition.Message = fmt.Sprintf("The DNS provider is degraded, the record is published once it recovers: %v", err)
+	return endpointsFromZoneStatus(record, zone)
+}
+
 func (c *Controller) deleteRecord(record *v1.DNSRecord) error {
 	var errs []error
 	for i := range record.Status.Zones {
-		zone := record.Status.Zones[i].DNSZone
+		zoneStatus := record.Status.Zones[i]
+		zone := zoneStatus.DNSZone
 		// If the record is currently not published in a zone,
 		// skip deleting it for that zone.
 		if !recordIsAlreadyPublishedToZone(record, &zone) {
 			continue
 		}
-		err := c.dnsProvider.Delete(record, zone)
+		// The record is deleted with the provider it was published with, and
+		// the endpoints that were published to the zone
+		dnsProvider, providerName, ok := c.providerForZoneStatus(zoneStatus)
+		if !ok {
+			errs = append(errs, fmt.Errorf("DNS provider %s of zone %s is not configured", providerName, zone.ID))
+			continue
+		}
+		zoneRecord := record.DeepCopy()
+		zoneRecord.Spec.Endpoints = zoneStatus.Endpoints
+		err := dnsProvider.Delete(zoneRecord, zone)
 		if err != nil {
 			errs = append(errs, err)
 		} else {
-			c.Logger.Info("Deleted DNSRecord from DNS provider", "record", record.Spec, "zone", zone)
+			c.Logger.Info("Deleted DNSRecord from DNS provider", "record", zoneRecord.Spec, "zone", zone, "provider", providerName)
 		}
 	}
 	if len(errs) == 0 {
@@ -145,6 +242,16 @@ func (c *Controller) deleteRecord(record *v1.DNSRecord) error {
 	return utilerrors.NewAggregate(errs)
 }
 
+// endpointsFromZoneStatus returns the endpoints last published to the zone.
+func endpointsFromZoneStatus(record *v1.DNSRecord, zone v1.DNSZone) []*v1.Endpoint {
+	for _, zoneStatus := range record.Status.Zones {
+		if reflect.DeepEqual(zoneStatus.DNSZone, zone) {
+			return zoneStatus.Endpoints
+		}
+	}
+	return nil
+}
+
 // recordIsAlreadyPublishedToZone returns a Boolean value indicating whether the
 // given DNSRecord is already published to the given zone, as determined from
 // the DNSRecord's status conditions.
@@ -173,6 +280,8 @@ func mergeStatuses(zones []v1.DNSZone, statuses, updates []v1.DNSZoneStatus) []v
 		for j, status := range statuses {
 			if cmp.Equal(status.DNSZone, update.DNSZone) {
 				add = false
+				statuses[j].Provider = update.Provider
+				statuses[j].ChangeID = update.ChangeID
 				statuses[j].Conditions = mergeConditions(status.Conditions, update.Conditions)
 				statuses[j].Endpoints = update.Endpoints
 			}
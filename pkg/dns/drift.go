@@ -0,0 +1,144 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+const (
+	// DriftModeRepair publishes the drifted endpoints again
+	DriftModeRepair = "repair"
+	// DriftModeReport only reports the drifted endpoints, in the Drifted zone condition and the drift metric
+	DriftModeReport = "report"
+)
+
+const (
+	noDriftReason       = "NoDrift"
+	driftDetectedReason = "DriftDetected"
+	driftRepairedReason = "DriftRepaired"
+)
+
+// DriftDetector is implemented by the providers that can compare the records published to a zone with the live zone.
+type DriftDetector interface {
+	// Drift returns the endpoints of the record that are missing from the live zone, or differ from the live records.
+	Drift(ctx context.Context, record *v1.DNSRecord, zone v1.DNSZone) ([]*v1.Endpoint, error)
+}
+
+// reconcileDrift compares the endpoints published to each zone with the live zone, once per drift check period, and
+// publishes the drifted endpoints again, unless drift is only reported.
+func (c *Controller) reconcileDrift(ctx context.Context, dnsRecord *v1.DNSRecord) error {
+	if c.driftCheckPeriod <= 0 || !c.driftCheckDue(dnsRecord) {
+		return nil
+	}
+
+	for i := range dnsRecord.Status.Zones {
+		status := &dnsRecord.Status.Zones[i]
+		if !recordIsAlreadyPublishedToZone(dnsRecord, &status.DNSZone) {
+			continue
+		}
+		provider, providerName, ok := c.providerForZoneStatus(*status)
+		if !ok {
+			continue
+		}
+		detector, ok := provider.(DriftDetector)
+		if !ok {
+			continue
+		}
+
+		// The endpoints last published to the zone are compared with the live zone
+		zoneRecord := dnsRecord.DeepCopy()
+		zoneRecord.Spec.Endpoints = status.Endpoints
+		drifted, err := detector.Drift(ctx, zoneRecord, status.DNSZone)
+		if err != nil {
+			return err
+		}
+		if len(drifted) == 0 {
+			status.Conditions = mergeConditions(status.Conditions, []v1.DNSZoneCondition{driftCondition(ConditionFalse, noDriftReason, "The live zone matches the published record")})
+			continue
+		}
+
+		dnsRecordDriftTotal.WithLabelValues(providerName, c.driftMode).Add(float64(len(drifted)))
+		message := fmt.Sprintf("The live zone is missing or differs for the endpoints %s", endpointKeys(drifted))
+		if c.driftMode == DriftModeReport {
+			c.Logger.Info("DNS record drifted from the live zone", "record", dnsRecord.Name, "zone", status.DNSZone, "endpoints", endpointKeys(drifted))
+			status.Conditions = mergeConditions(status.Conditions, []v1.DNSZoneCondition{driftCondition(ConditionTrue, driftDetectedReason, message)})
+			continue
+		}
+
+		c.Logger.Info("Repairing DNS record drifted from the live zone", "record", dnsRecord.Name, "zone", status.DNSZone, "endpoints", endpointKeys(drifted))
+		changeID, err := ensure(provider, zoneRecord, status.DNSZone)
+		if err != nil {
+			status.Conditions = mergeConditions(status.Conditions, []v1.DNSZoneCondition{driftCondition(ConditionTrue, driftDetectedReason, fmt.Sprintf("%s, and repairing them failed: %v", message, err))})
+			return err
+		}
+		status.ChangeID = changeID
+		status.Conditions = mergeConditions(status.Conditions, []v1.DNSZoneCondition{
+			driftCondition(ConditionFalse, driftRepairedReason, fmt.Sprintf("The endpoints %s have been published again", endpointKeys(drifted))),
+			propagatedCondition(changeID),
+		})
+	}
+
+	c.driftChecked(dnsRecord)
+	return nil
+}
+
+// driftCheckDue returns whether the drift check period has elapsed since the record was last checked
+func (c *Controller) driftCheckDue(dnsRecord *v1.DNSRecord) bool {
+	c.driftChecksLock.Lock()
+	defer c.driftChecksLock.Unlock()
+	checked, ok := c.driftChecks[dnsRecord.UID]
+	return !ok || clock.Since(checked) >= c.driftCheckPeriod
+}
+
+func (c *Controller) driftChecked(dnsRecord *v1.DNSRecord) {
+	c.driftChecksLock.Lock()
+	defer c.driftChecksLock.Unlock()
+	c.driftChecks[dnsRecord.UID] = clock.Now()
+}
+
+// forgetDriftChecks forgets when the record was last checked, once it is deleted
+func (c *Controller) forgetDriftChecks(dnsRecord *v1.DNSRecord) {
+	c.driftChecksLock.Lock()
+	defer c.driftChecksLock.Unlock()
+	delete(c.driftChecks, dnsRecord.UID)
+}
+
+// hasDriftCheckedZones returns whether any of the zones of the record is checked for drift
+func (c *Controller) hasDriftCheckedZones(dnsRecord *v1.DNSRecord) bool {
+	if c.driftCheckPeriod <= 0 {
+		return false
+	}
+	for _, status := range dnsRecord.Status.Zones {
+		if provider, _, ok := c.providerForZoneStatus(status); ok {
+			if _, ok := provider.(DriftDetector); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func driftCondition(status ConditionStatus, reason, message string) v1.DNSZoneCondition {
+	return v1.DNSZoneCondition{
+		Type:    v1.DNSRecordDriftedConditionType,
+		Status:  string(status),
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+func endpointKeys(endpoints []*v1.Endpoint) string {
+	keys := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		keys = append(keys, fmt.Sprintf("%s/%s/%s", endpoint.DNSName, endpoint.RecordType, endpoint.SetID()))
+	}
+	return strings.Join(keys, ", ")
+}
+
+// validDriftMode returns whether the drift mode is supported
+func validDriftMode(mode string) bool {
+	return mode == DriftModeRepair || mode == DriftModeReport
+}
@@ -0,0 +1,102 @@
+package dns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	utilclock "k8s.io/utils/clock"
+	testclock "k8s.io/utils/clock/testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+type mockDriftDetector struct {
+	mockProvider
+	drifted []*v1.Endpoint
+	checks  int
+	ensured int
+}
+
+func (m *mockDriftDetector) Ensure(_ *v1.DNSRecord, _ v1.DNSZone) error {
+	m.ensured++
+	return nil
+}
+
+func (m *mockDriftDetector) Drift(_ context.Context, record *v1.DNSRecord, _ v1.DNSZone) ([]*v1.Endpoint, error) {
+	m.checks++
+	return m.drifted, nil
+}
+
+func newDriftTestRecord(c *Controller) *v1.DNSRecord {
+	record := newPropagationTestRecord()
+	record.UID = "uid"
+	record.Status.Zones = c.publishRecordToZones(c.dnsZones, record)
+	record.Status.ObservedGeneration = record.Generation
+	return record
+}
+
+func TestReconcileDrift(t *testing.T) {
+	fakeClock := testclock.NewFakeClock(time.Now())
+	clock = fakeClock
+	defer func() { clock = utilclock.RealClock{} }()
+
+	for _, mode := range []string{DriftModeRepair, DriftModeReport} {
+		t.Run(mode, func(t *testing.T) {
+			detector := &mockDriftDetector{}
+			c := newPropagationTestController(detector)
+			c.driftCheckPeriod = time.Minute
+			c.driftMode = mode
+			c.driftChecks = map[types.UID]time.Time{}
+			record := newDriftTestRecord(c)
+			published := detector.ensured
+
+			// The live zone matches the published record
+			if err := c.reconcileDrift(context.TODO(), record); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if condition, _ := zoneCondition(record.Status.Zones[0], v1.DNSRecordDriftedConditionType); condition.Status != string(ConditionFalse) {
+				t.Fatalf("expected no drift, got %v", condition)
+			}
+
+			// The record is not checked again until the drift check period has elapsed
+			detector.drifted = record.Status.Zones[0].Endpoints
+			if err := c.reconcileDrift(context.TODO(), record); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if detector.checks != 1 {
+				t.Fatalf("expected a single drift check, got %d", detector.checks)
+			}
+
+			fakeClock.Step(time.Minute)
+			if err := c.reconcileDrift(context.TODO(), record); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			condition, _ := zoneCondition(record.Status.Zones[0], v1.DNSRecordDriftedConditionType)
+			switch mode {
+			case DriftModeRepair:
+				if detector.ensured != published+1 || condition.Reason != driftRepairedReason || condition.Status != string(ConditionFalse) {
+					t.Errorf("expected the drift to be repaired, got %d publications and %v", detector.ensured-published, condition)
+				}
+			case DriftModeReport:
+				if detector.ensured != published || condition.Reason != driftDetectedReason || condition.Status != string(ConditionTrue) {
+					t.Errorf("expected the drift to be reported only, got %d publications and %v", detector.ensured-published, condition)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileDriftDisabled(t *testing.T) {
+	detector := &mockDriftDetector{}
+	c := newPropagationTestController(detector)
+	record := newDriftTestRecord(c)
+
+	if err := c.reconcileDrift(context.TODO(), record); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if detector.checks != 0 || c.hasDriftCheckedZones(record) {
+		t.Errorf("expected no drift check when the drift check period is zero")
+	}
+}
@@ -0,0 +1,390 @@
+package embedded
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/miekg/dns"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+const (
+	DefaultAddress = ":1053"
+
+	// negativeTTL is the TTL of the SOA record, and the time resolvers cache
+	// negative answers for.
+	negativeTTL = 30
+)
+
+// Provider is an authoritative name server for the managed domain, that
+// answers queries straight from the DNSRecord informer caches. It requires
+// no external DNS service, which makes it suited to local development and
+// air-gapped installations.
+//
+// When several endpoints share the queried name, one of them is selected at
+// random, in proportion of its aws/weight provider specific property, the
+// same way Route53 weighted records are resolved.
+type Provider struct {
+	config  Config
+	logger  logr.Logger
+	servers []*dns.Server
+
+	mu      sync.RWMutex
+	listers []kuadrantv1lister.DNSRecordLister
+	// random returns a non-negative pseudo-random number in [0,n)
+	random func(n int64) int64
+}
+
+// Config is the necessary input to configure the name server.
+type Config struct {
+	// Address the name server listens to, over both UDP and TCP.
+	Address string
+	// Zone is the domain the name server is authoritative for.
+	Zone string
+}
+
+// NewProvider binds the name server listeners, so that configuration errors
+// are reported before the controllers are started.
+func NewProvider(config Config) (*Provider, error) {
+	if config.Zone == "" {
+		return nil, fmt.Errorf("a zone is required")
+	}
+	if config.Address == "" {
+		config.Address = DefaultAddress
+	}
+	config.Zone = dns.CanonicalName(config.Zone)
+
+	p := &Provider{
+		config: config,
+		logger: log.Logger.WithName("embedded-dns").WithValues("zone", config.Zone),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())).Int63n,
+	}
+
+	packetConn, err := net.ListenPacket("udp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", packetConn.LocalAddr().String())
+	if err != nil {
+		_ = packetConn.Close()
+		return nil, err
+	}
+	p.servers = []*dns.Server{
+		{PacketConn: packetConn, Handler: p},
+		{Listener: listener, Handler: p},
+	}
+
+	return p, nil
+}
+
+// AddLister adds a DNSRecord informer cache to the sources of the name server.
+func (p *Provider) AddLister(lister kuadrantv1lister.DNSRecordLister) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listers = append(p.listers, lister)
+}
+
+// Zone returns the DNS zone the name server is authoritative for.
+func (p *Provider) Zone() v1.DNSZone {
+	return v1.DNSZone{ID: strings.TrimSuffix(p.config.Zone, ".")}
+}
+
+// SupportsWeightedCNAMEs returns true, as the name server resolves weighted CNAME records of the same name.
+func (p *Provider) SupportsWeightedCNAMEs() bool {
+	return true
+}
+
+// Address returns the UDP address the name server listens to.
+func (p *Provider) Address() string {
+	return p.servers[0].PacketConn.LocalAddr().String()
+}
+
+func (p *Provider) Start() error {
+	errs := make(chan error, len(p.servers))
+	for _, server := range p.servers {
+		go func(server *dns.Server) {
+			errs <- server.ActivateAndServe()
+		}(server)
+	}
+	p.logger.Info("Started serving DNS", "address", p.Address())
+
+	var err error
+	for range p.servers {
+		if e := <-errs; e != nil && err == nil {
+			err = fmt.Errorf("serving DNS failed: %v", e)
+		}
+	}
+	return err
+}
+
+func (p *Provider) Shutdown() error {
+	p.logger.Info("Stopping DNS server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, server := range p.servers {
+		if err := server.ShutdownContext(shutdownCtx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ensure only validates the record endpoints, as records are served from
+// the informer caches as soon as they are created or updated.
+func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	for _, endpoint := range record.Spec.Endpoints {
+		if !dns.IsSubDomain(p.config.Zone, dns.CanonicalName(endpoint.DNSName)) {
+			return fmt.Errorf("endpoint %s is outside of zone %s", endpoint.DNSName, zone.ID)
+		}
+		if _, err := resourceRecords(endpoint); err != nil {
+			return err
+		}
+		if _, err := weight(endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete is a no-op, as records are no longer served once they are deleted
+// from the informer caches.
+func (p *Provider) Delete(_ *v1.DNSRecord, _ v1.DNSZone) error {
+	return nil
+}
+
+// ReconcileHealthCheck is a no-op, as the embedded name server has no health checks.
+func (p *Provider) ReconcileHealthCheck(_ context.Context, _ v1.HealthCheck, _ *v1.Endpoint) error {
+	return nil
+}
+
+func (p *Provider) DeleteHealthCheck(_ context.Context, _ *v1.Endpoint) error {
+	return nil
+}
+
+func (p *Provider) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	defer func() {
+		if err := w.WriteMsg(m); err != nil {
+			p.logger.Error(err, "Failed to write DNS response")
+		}
+	}()
+
+	if r.Opcode != dns.OpcodeQuery || len(r.Question) != 1 {
+		m.Authoritative = false
+		m.Rcode = dns.RcodeNotImplemented
+		return
+	}
+	question := r.Question[0]
+	name := dns.CanonicalName(question.Name)
+	if !dns.IsSubDomain(p.config.Zone, name) {
+		m.Authoritative = false
+		m.Rcode = dns.RcodeRefused
+		return
+	}
+
+	if name == p.config.Zone {
+		switch question.Qtype {
+		case dns.TypeSOA:
+			m.Answer = append(m.Answer, p.soa())
+			return
+		case dns.TypeNS:
+			m.Answer = append(m.Answer, p.ns())
+			return
+		}
+	}
+
+	endpoints, err := p.endpoints(name)
+	if err != nil {
+		p.logger.Error(err, "Failed to list DNS records")
+		m.Rcode = dns.RcodeServerFailure
+		return
+	}
+	if len(endpoints) == 0 {
+		if name != p.config.Zone {
+			m.Rcode = dns.RcodeNameError
+		}
+		m.Ns = append(m.Ns, p.soa())
+		return
+	}
+
+	// A and AAAA records are distinct record sets, so the endpoint is selected among the ones answering the question
+	endpoints = endpointsForType(endpoints, question.Qtype)
+	if len(endpoints) == 0 {
+		m.Ns = append(m.Ns, p.soa())
+		return
+	}
+
+	endpoint, err := p.selectEndpoint(endpoints)
+	if err != nil {
+		p.logger.Error(err, "Failed to select endpoint", "name", name)
+		m.Rcode = dns.RcodeServerFailure
+		return
+	}
+	answer, err := resourceRecords(endpoint)
+	if err != nil {
+		p.logger.Error(err, "Failed to build answer", "name", name)
+		m.Rcode = dns.RcodeServerFailure
+		return
+	}
+	for _, rr := range answer {
+		if rr.Header().Rrtype == question.Qtype || rr.Header().Rrtype == dns.TypeCNAME || question.Qtype == dns.TypeANY {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+	if len(m.Answer) == 0 {
+		m.Ns = append(m.Ns, p.soa())
+	}
+}
+
+// endpointsForType returns the endpoints with records of the given type, or
+// CNAME records, that answer all the queries for a name.
+func endpointsForType(endpoints []*v1.Endpoint, qtype uint16) []*v1.Endpoint {
+	if qtype == dns.TypeANY {
+		return endpoints
+	}
+	var matching []*v1.Endpoint
+	for _, endpoint := range endpoints {
+		rrtype, ok := dns.StringToType[endpoint.RecordType]
+		if ok && (rrtype == qtype || rrtype == dns.TypeCNAME) {
+			matching = append(matching, endpoint)
+		}
+	}
+	return matching
+}
+
+// endpoints returns the endpoints of all the DNSRecords named after name,
+// that are not being deleted.
+func (p *Provider) endpoints(name string) ([]*v1.Endpoint, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var endpoints []*v1.Endpoint
+	for _, lister := range p.listers {
+		records, err := lister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if record.DeletionTimestamp != nil {
+				continue
+			}
+			for _, endpoint := range record.Spec.Endpoints {
+				if dns.CanonicalName(endpoint.DNSName) == name && len(endpoint.Targets) > 0 {
+					endpoints = append(endpoints, endpoint)
+				}
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+// selectEndpoint selects one of the endpoints at random, in proportion of
+// their weights. Endpoints are selected evenly when all the weights are 0.
+func (p *Provider) selectEndpoint(endpoints []*v1.Endpoint) (*v1.Endpoint, error) {
+	weights := make([]int64, len(endpoints))
+	var total int64
+	for i, endpoint := range endpoints {
+		w, err := weight(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		weights[i] = w
+		total += w
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if total == 0 {
+		return endpoints[p.random(int64(len(endpoints)))], nil
+	}
+	n := p.random(total)
+	for i, w := range weights {
+		if n < w {
+			return endpoints[i], nil
+		}
+		n -= w
+	}
+	return endpoints[len(endpoints)-1], nil
+}
+
+func (p *Provider) soa() dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: p.config.Zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: negativeTTL},
+		Ns:      "ns." + p.config.Zone,
+		Mbox:    "hostmaster." + p.config.Zone,
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  negativeTTL,
+	}
+}
+
+func (p *Provider) ns() dns.RR {
+	return &dns.NS{
+		Hdr: dns.RR_Header{Name: p.config.Zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: negativeTTL},
+		Ns:  "ns." + p.config.Zone,
+	}
+}
+
+// weight returns the aws/weight of the endpoint, which defaults to 1 when
+// the endpoint is not weighted.
+func weight(endpoint *v1.Endpoint) (int64, error) {
+	prop, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight)
+	if !ok {
+		return 1, nil
+	}
+	w, err := strconv.ParseInt(prop.Value, 10, 64)
+	if err != nil || w < 0 {
+		return 0, fmt.Errorf("invalid weight %q for endpoint %s", prop.Value, endpoint.SetID())
+	}
+	return w, nil
+}
+
+func resourceRecords(endpoint *v1.Endpoint) ([]dns.RR, error) {
+	var records []dns.RR
+	header := dns.RR_Header{
+		Name:  dns.CanonicalName(endpoint.DNSName),
+		Class: dns.ClassINET,
+		Ttl:   uint32(endpoint.RecordTTL),
+	}
+	for _, target := range endpoint.Targets {
+		switch endpoint.RecordType {
+		case string(v1.ARecordType):
+			ip := net.ParseIP(target).To4()
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IPv4 address %s for endpoint %s", target, endpoint.SetID())
+			}
+			header.Rrtype = dns.TypeA
+			records = append(records, &dns.A{Hdr: header, A: ip})
+		case string(v1.AAAARecordType):
+			ip := net.ParseIP(target)
+			if ip == nil || ip.To4() != nil {
+				return nil, fmt.Errorf("invalid IPv6 address %s for endpoint %s", target, endpoint.SetID())
+			}
+			header.Rrtype = dns.TypeAAAA
+			records = append(records, &dns.AAAA{Hdr: header, AAAA: ip})
+		case string(v1.CNAMERecordType):
+			header.Rrtype = dns.TypeCNAME
+			records = append(records, &dns.CNAME{Hdr: header, Target: dns.Fqdn(target)})
+		default:
+			return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,221 @@
+package embedded
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+const testZone = "example.com"
+
+func newTestProvider(t *testing.T, records ...*v1.DNSRecord) (*Provider, cache.Indexer) {
+	provider, err := NewProvider(Config{Address: "127.0.0.1:0", Zone: testZone})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	go func() {
+		_ = provider.Start()
+	}()
+	t.Cleanup(func() {
+		_ = provider.Shutdown()
+	})
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, record := range records {
+		if err := indexer.Add(record); err != nil {
+			t.Fatalf("unexpected error adding record: %v", err)
+		}
+	}
+	provider.AddLister(kuadrantv1lister.NewDNSRecordLister(indexer))
+	return provider, indexer
+}
+
+func query(t *testing.T, provider *Provider, name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	var resp *dns.Msg
+	var err error
+	// The server may not be serving yet
+	for i := 0; i < 10; i++ {
+		if resp, _, err = new(dns.Client).Exchange(m, provider.Address()); err == nil {
+			return resp
+		}
+	}
+	t.Fatalf("unexpected error querying %s: %v", name, err)
+	return nil
+}
+
+func record(name string, endpoints ...*v1.Endpoint) *v1.DNSRecord {
+	return &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1.DNSRecordSpec{Endpoints: endpoints},
+	}
+}
+
+func endpoint(name, ip, weight string) *v1.Endpoint {
+	return &v1.Endpoint{
+		DNSName:       name,
+		RecordType:    string(v1.ARecordType),
+		SetIdentifier: ip,
+		Targets:       v1.Targets{ip},
+		RecordTTL:     60,
+		ProviderSpecific: v1.ProviderSpecific{
+			{Name: aws.ProviderSpecificWeight, Value: weight},
+		},
+	}
+}
+
+func TestProviderServeDNS(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider, indexer := newTestProvider(t,
+		record("app",
+			endpoint("app.example.com", "192.168.0.1", "120"),
+			endpoint("app.example.com", "192.168.0.2", "60"),
+		),
+		record("www", &v1.Endpoint{
+			DNSName:    "www.example.com",
+			RecordType: string(v1.CNAMERecordType),
+			Targets:    v1.Targets{"app.example.com"},
+			RecordTTL:  300,
+		}),
+		record("dual",
+			endpoint("dual.example.com", "192.168.0.1", "120"),
+			&v1.Endpoint{
+				DNSName:       "dual.example.com",
+				RecordType:    string(v1.AAAARecordType),
+				SetIdentifier: "2001:db8::1",
+				Targets:       v1.Targets{"2001:db8::1"},
+				RecordTTL:     60,
+				ProviderSpecific: v1.ProviderSpecific{
+					{Name: aws.ProviderSpecificWeight, Value: "120"},
+				},
+			},
+		),
+	)
+
+	resp := query(t, provider, "app.example.com", dns.TypeA)
+	g.Expect(resp.Rcode).To(gomega.Equal(dns.RcodeSuccess))
+	g.Expect(resp.Authoritative).To(gomega.BeTrue())
+	g.Expect(resp.Answer).To(gomega.HaveLen(1))
+	g.Expect(resp.Answer[0].Header().Ttl).To(gomega.Equal(uint32(60)))
+
+	resp = query(t, provider, "www.example.com", dns.TypeA)
+	g.Expect(resp.Answer).To(gomega.HaveLen(1))
+	g.Expect(resp.Answer[0].(*dns.CNAME).Target).To(gomega.Equal("app.example.com."))
+
+	// Existing name without records of the queried type
+	resp = query(t, provider, "app.example.com", dns.TypeAAAA)
+	g.Expect(resp.Rcode).To(gomega.Equal(dns.RcodeSuccess))
+	g.Expect(resp.Answer).To(gomega.BeEmpty())
+	g.Expect(resp.Ns).To(gomega.HaveLen(1))
+
+	// Dual-stack names are answered with the records of the queried type
+	for i := 0; i < 10; i++ {
+		resp = query(t, provider, "dual.example.com", dns.TypeAAAA)
+		g.Expect(resp.Answer).To(gomega.HaveLen(1))
+		g.Expect(resp.Answer[0].(*dns.AAAA).AAAA.String()).To(gomega.Equal("2001:db8::1"))
+		resp = query(t, provider, "dual.example.com", dns.TypeA)
+		g.Expect(resp.Answer).To(gomega.HaveLen(1))
+		g.Expect(resp.Answer[0].(*dns.A).A.String()).To(gomega.Equal("192.168.0.1"))
+	}
+
+	resp = query(t, provider, "other.example.com", dns.TypeA)
+	g.Expect(resp.Rcode).To(gomega.Equal(dns.RcodeNameError))
+	g.Expect(resp.Ns[0].Header().Rrtype).To(gomega.Equal(dns.TypeSOA))
+
+	resp = query(t, provider, "example.org", dns.TypeA)
+	g.Expect(resp.Rcode).To(gomega.Equal(dns.RcodeRefused))
+
+	resp = query(t, provider, testZone, dns.TypeSOA)
+	g.Expect(resp.Answer).To(gomega.HaveLen(1))
+
+	// Records being deleted are no longer served
+	now := metav1.Now()
+	deleted := record("www")
+	deleted.DeletionTimestamp = &now
+	deleted.Spec = v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{{
+		DNSName:    "www.example.com",
+		RecordType: string(v1.CNAMERecordType),
+		Targets:    v1.Targets{"app.example.com"},
+	}}}
+	g.Expect(indexer.Update(deleted)).To(gomega.Succeed())
+	resp = query(t, provider, "www.example.com", dns.TypeA)
+	g.Expect(resp.Rcode).To(gomega.Equal(dns.RcodeNameError))
+}
+
+func TestProviderSelectEndpoint(t *testing.T) {
+	cases := []struct {
+		name      string
+		endpoints []*v1.Endpoint
+		random    int64
+		expected  string
+	}{
+		{
+			name: "first weighted endpoint",
+			endpoints: []*v1.Endpoint{
+				endpoint("app.example.com", "192.168.0.1", "120"),
+				endpoint("app.example.com", "192.168.0.2", "60"),
+			},
+			random:   119,
+			expected: "192.168.0.1",
+		},
+		{
+			name: "second weighted endpoint",
+			endpoints: []*v1.Endpoint{
+				endpoint("app.example.com", "192.168.0.1", "120"),
+				endpoint("app.example.com", "192.168.0.2", "60"),
+			},
+			random:   120,
+			expected: "192.168.0.2",
+		},
+		{
+			name: "zero weight is never selected",
+			endpoints: []*v1.Endpoint{
+				endpoint("app.example.com", "192.168.0.1", "0"),
+				endpoint("app.example.com", "192.168.0.2", "60"),
+			},
+			random:   0,
+			expected: "192.168.0.2",
+		},
+		{
+			name: "all zero weights are selected evenly",
+			endpoints: []*v1.Endpoint{
+				endpoint("app.example.com", "192.168.0.1", "0"),
+				endpoint("app.example.com", "192.168.0.2", "0"),
+			},
+			random:   1,
+			expected: "192.168.0.2",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &Provider{random: func(n int64) int64 { return tc.random }}
+			selected, err := provider.selectEndpoint(tc.endpoints)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if selected.Targets[0] != tc.expected {
+				t.Errorf("expected %s but got %s", tc.expected, selected.Targets[0])
+			}
+		})
+	}
+}
+
+func TestProviderEnsure(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider := &Provider{config: Config{Zone: testZone + "."}}
+	zone := v1.DNSZone{ID: testZone}
+
+	g.Expect(provider.Ensure(record("app", endpoint("app.example.com", "192.168.0.1", "120")), zone)).To(gomega.Succeed())
+	g.Expect(provider.Ensure(record("app", endpoint("app.example.org", "192.168.0.1", "120")), zone)).NotTo(gomega.Succeed())
+	g.Expect(provider.Ensure(record("app", endpoint("app.example.com", "192.168.0.1", "heavy")), zone)).NotTo(gomega.Succeed())
+	g.Expect(provider.Ensure(record("app", endpoint("app.example.com", "lb.example.com", "120")), zone)).NotTo(gomega.Succeed())
+}
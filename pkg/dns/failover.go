@@ -0,0 +1,90 @@
+package dns
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// failoverStatusResyncPeriod is the period the health of the primary endpoints is checked at, to keep the failover
+// status of the records up to date
+const failoverStatusResyncPeriod = time.Minute
+
+// reconcileFailoverStatus sets the failover status of the record from the health of its primary endpoints. The status
+// is removed when the record has no failover endpoints, or when the provider does not report the health of the health
+// checks.
+func (c *Controller) reconcileFailoverStatus(ctx context.Context, dnsRecord *v1.DNSRecord) error {
+	getter, ok := c.healthCheckStatusGetter()
+	if !ok || !hasFailoverEndpoints(dnsRecord) {
+		dnsRecord.Status.Failover = nil
+		return nil
+	}
+
+	active, err := activeFailoverRole(ctx, getter, dnsRecord.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+	if dnsRecord.Status.Failover == nil || dnsRecord.Status.Failover.Active != active {
+		if active == aws.FailoverSecondary {
+			c.Logger.Info("Primary endpoints are unhealthy, DNS record failed over", "record", dnsRecord.Name)
+		}
+		dnsRecord.Status.Failover = &v1.DNSRecordFailoverStatus{
+			Active:             active,
+			LastTransitionTime: metav1.NewTime(clock.Now()),
+		}
+	}
+	return nil
+}
+
+// healthCheckStatusGetter returns the first of the providers that reports the health of the health checks
+func (c *Controller) healthCheckStatusGetter() (HealthCheckStatusGetter, bool) {
+	for _, dnsProvider := range c.providers() {
+		if getter, ok := dnsProvider.(HealthCheckStatusGetter); ok {
+			return getter, true
+		}
+	}
+	return nil, false
+}
+
+// hasFailoverEndpoints returns whether any of the endpoints of the record has a failover role
+func hasFailoverEndpoints(dnsRecord *v1.DNSRecord) bool {
+	for _, endpoint := range dnsRecord.Spec.Endpoints {
+		if _, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificFailover); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// activeFailoverRole returns the failover role of the endpoints answering the queries, i.e. SECONDARY when any of the
+// primary endpoints is unhealthy and there are secondary endpoints to fail over to, PRIMARY otherwise.
+func activeFailoverRole(ctx context.Context, getter HealthCheckStatusGetter, endpoints []*v1.Endpoint) (string, error) {
+	var primaries []*v1.Endpoint
+	hasSecondary := false
+	for _, endpoint := range endpoints {
+		role, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificFailover)
+		switch role {
+		case aws.FailoverPrimary:
+			primaries = append(primaries, endpoint)
+		case aws.FailoverSecondary:
+			hasSecondary = true
+		}
+	}
+	if !hasSecondary {
+		return aws.FailoverPrimary, nil
+	}
+	for _, endpoint := range primaries {
+		healthy, err := getter.HealthCheckHealthy(ctx, endpoint)
+		if err != nil {
+			return "", err
+		}
+		if !healthy {
+			return aws.FailoverSecondary, nil
+		}
+	}
+	return aws.FailoverPrimary, nil
+}
@@ -0,0 +1,89 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+type mockHealthCheckStatusGetter struct {
+	unhealthy map[string]bool
+}
+
+// HealthCheckHealthy returns whether all the addresses of the endpoint are healthy, as the providers do
+func (m *mockHealthCheckStatusGetter) HealthCheckHealthy(_ context.Context, endpoint *v1.Endpoint) (bool, error) {
+	for _, target := range endpoint.Targets {
+		if m.unhealthy[target] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func failoverEndpoint(role string, targets ...string) *v1.Endpoint {
+	endpoint := &v1.Endpoint{
+		DNSName:       "xyz.dev.hcpapps.net",
+		RecordType:    string(v1.ARecordType),
+		SetIdentifier: role,
+		Targets:       targets,
+	}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificFailover, role)
+	return endpoint
+}
+
+func TestActiveFailoverRole(t *testing.T) {
+	cases := []struct {
+		name      string
+		endpoints []*v1.Endpoint
+		unhealthy map[string]bool
+		expected  string
+	}{
+		{
+			name: "healthy primary",
+			endpoints: []*v1.Endpoint{
+				failoverEndpoint(aws.FailoverPrimary, "192.168.0.1"),
+				failoverEndpoint(aws.FailoverSecondary, "192.168.1.1"),
+			},
+			expected: aws.FailoverPrimary,
+		},
+		{
+			name: "unhealthy primary",
+			endpoints: []*v1.Endpoint{
+				failoverEndpoint(aws.FailoverPrimary, "192.168.0.1"),
+				failoverEndpoint(aws.FailoverSecondary, "192.168.1.1"),
+			},
+			unhealthy: map[string]bool{"192.168.0.1": true},
+			expected:  aws.FailoverSecondary,
+		},
+		{
+			name: "unhealthy address of a primary with two addresses",
+			endpoints: []*v1.Endpoint{
+				failoverEndpoint(aws.FailoverPrimary, "192.168.0.1", "192.168.0.2"),
+				failoverEndpoint(aws.FailoverSecondary, "192.168.1.1"),
+			},
+			unhealthy: map[string]bool{"192.168.0.2": true},
+			expected:  aws.FailoverSecondary,
+		},
+		{
+			name: "unhealthy primary without secondary",
+			endpoints: []*v1.Endpoint{
+				failoverEndpoint(aws.FailoverPrimary, "192.168.0.1"),
+			},
+			unhealthy: map[string]bool{"192.168.0.1": true},
+			expected:  aws.FailoverPrimary,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			active, err := activeFailoverRole(context.TODO(), &mockHealthCheckStatusGetter{unhealthy: tc.unhealthy}, tc.endpoints)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if active != tc.expected {
+				t.Errorf("expected %s to be active but got %s", tc.expected, active)
+			}
+		})
+	}
+}
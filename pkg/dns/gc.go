@@ -0,0 +1,141 @@
+package dns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// OwnershipRegistry is implemented by the providers that mark the record sets they publish with their owner, so that
+// the record sets left behind by the DNSRecords that no longer exist can be garbage collected.
+type OwnershipRegistry interface {
+	// OwnedRecords returns the keys of the record sets of the zone owned by the provider, indexed by the key of the
+	// DNSRecord they have been published for.
+	OwnedRecords(ctx context.Context, zone v1.DNSZone) (map[string][]string, error)
+	// DeleteOwnedRecords deletes the record sets with the given keys, as long as they are still owned by the provider
+	// for the DNSRecord with the given key.
+	DeleteOwnedRecords(ctx context.Context, zone v1.DNSZone, resource string, records []string) error
+}
+
+// GarbageCollector periodically deletes the record sets owned by GLBC whose DNSRecord no longer exists, e.g. because
+// its finalizer has been removed, or because GLBC stopped while it was being updated. It is shared by all the DNSRecord
+// controllers, as the zones are shared by the DNSRecords of all the APIExports. The record sets not owned by GLBC are
+// never deleted.
+type GarbageCollector struct {
+	period time.Duration
+	logger logr.Logger
+
+	lock     sync.Mutex
+	indexers []cache.Indexer
+	synced   []cache.InformerSynced
+	zones    []garbageCollectedZone
+}
+
+type garbageCollectedZone struct {
+	zone     v1.DNSZone
+	provider string
+	registry OwnershipRegistry
+}
+
+// NewGarbageCollector returns a garbage collector running at the given period, zero disables it.
+func NewGarbageCollector(period time.Duration) *GarbageCollector {
+	return &GarbageCollector{
+		period: period,
+		logger: log.Logger.WithName("dns-garbage-collector"),
+	}
+}
+
+// addController adds the DNSRecord informer cache, and the zones managed with an ownership registry, of the controller
+// to the garbage collector.
+func (gc *GarbageCollector) addController(c *Controller) {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	informer := c.sharedInformerFactory.Kuadrant().V1().DNSRecords().Informer()
+	gc.indexers = append(gc.indexers, informer.GetIndexer())
+	gc.synced = append(gc.synced, informer.HasSynced)
+	gc.addZones(c)
+}
+
+func (gc *GarbageCollector) addZones(c *Controller) {
+	for _, zone := range c.dnsZones {
+		if gc.hasZone(zone.ID) {
+			continue
+		}
+		name := c.dnsZoneProviders[zone.ID]
+		if registry, ok := c.dnsProviders[name].(OwnershipRegistry); ok {
+			gc.zones = append(gc.zones, garbageCollectedZone{zone: zone, provider: name, registry: registry})
+		}
+	}
+}
+
+func (gc *GarbageCollector) hasZone(id string) bool {
+	for _, zone := range gc.zones {
+		if zone.zone.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Start collects the orphaned record sets periodically, once the informer caches are synced, until the context is
+// done. The records published for the DNSRecords not yet in the caches would be collected otherwise.
+func (gc *GarbageCollector) Start(ctx context.Context, _ int) {
+	if gc.period <= 0 {
+		return
+	}
+	gc.lock.Lock()
+	synced := gc.synced
+	gc.lock.Unlock()
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return
+	}
+	gc.logger.Info("Starting DNS garbage collector", "period", gc.period)
+	wait.UntilWithContext(ctx, gc.collect, gc.period)
+}
+
+// collect deletes the record sets owned by GLBC, in all the zones, whose DNSRecord no longer exists.
+func (gc *GarbageCollector) collect(ctx context.Context) {
+	gc.lock.Lock()
+	zones := gc.zones
+	gc.lock.Unlock()
+	for _, zone := range zones {
+		owned, err := zone.registry.OwnedRecords(ctx, zone.zone)
+		if err != nil {
+			gc.logger.Error(err, "Failed to list owned DNS records", "zone", zone.zone.ID)
+			continue
+		}
+		for resource, records := range owned {
+			if gc.exists(resource) {
+				continue
+			}
+			gc.logger.Info("Deleting orphaned DNS records", "zone", zone.zone.ID, "record", resource, "recordSets", records)
+			if err := zone.registry.DeleteOwnedRecords(ctx, zone.zone, resource, records); err != nil {
+				gc.logger.Error(err, "Failed to delete orphaned DNS records", "zone", zone.zone.ID, "record", resource)
+				continue
+			}
+			dnsOrphanedRecordsDeletedTotal.WithLabelValues(zone.provider).Add(float64(len(records)))
+		}
+	}
+}
+
+// exists returns whether the DNSRecord with the given key exists in any of the informer caches. A DNSRecord being
+// deleted still exists, its records being deleted by its controller. The records are kept when the caches cannot be
+// read.
+func (gc *GarbageCollector) exists(key string) bool {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	for _, indexer := range gc.indexers {
+		_, exists, err := indexer.GetByKey(key)
+		if err != nil || exists {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package dns
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+type mockOwnershipRegistry struct {
+	mockProvider
+	owned   map[string][]string
+	deleted map[string][]string
+}
+
+func (m *mockOwnershipRegistry) OwnedRecords(_ context.Context, _ v1.DNSZone) (map[string][]string, error) {
+	return m.owned, nil
+}
+
+func (m *mockOwnershipRegistry) DeleteOwnedRecords(_ context.Context, _ v1.DNSZone, resource string, records []string) error {
+	if m.deleted == nil {
+		m.deleted = map[string][]string{}
+	}
+	m.deleted[resource] = records
+	return nil
+}
+
+func TestGarbageCollectorAddZones(t *testing.T) {
+	registry := &mockOwnershipRegistry{}
+	c := &Controller{
+		dnsProviders:     map[string]Provider{"aws": registry, "embedded": &mockProvider{}},
+		dnsZones:         []v1.DNSZone{{ID: "Z1"}, {ID: "example.com"}, {ID: "Z2"}},
+		dnsZoneProviders: map[string]string{"Z1": "aws", "example.com": "embedded", "Z2": "aws"},
+	}
+	gc := &GarbageCollector{logger: logr.Discard()}
+	gc.addZones(c)
+	// The zones are shared by the controllers of all the APIExports
+	gc.addZones(c)
+
+	var zones []string
+	for _, zone := range gc.zones {
+		zones = append(zones, zone.zone.ID)
+	}
+	if expected := []string{"Z1", "Z2"}; !reflect.DeepEqual(zones, expected) {
+		t.Errorf("expected zones %v, got %v", expected, zones)
+	}
+}
+
+func TestGarbageCollectorCollect(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	existing := &v1.DNSRecord{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+	if err := indexer.Add(existing); err != nil {
+		t.Fatal(err)
+	}
+	existingKey, err := cache.MetaNamespaceKeyFunc(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := &mockOwnershipRegistry{owned: map[string][]string{
+		existingKey:       {"app.example.com/A"},
+		"default/deleted": {"deleted.example.com/CNAME", "deleted.example.com/A"},
+	}}
+	gc := &GarbageCollector{
+		logger:   logr.Discard(),
+		indexers: []cache.Indexer{indexer},
+		zones:    []garbageCollectedZone{{zone: v1.DNSZone{ID: "Z1"}, provider: "aws", registry: registry}},
+	}
+	gc.collect(context.TODO())
+
+	expected := map[string][]string{"default/deleted": {"deleted.example.com/CNAME", "deleted.example.com/A"}}
+	if !reflect.DeepEqual(registry.deleted, expected) {
+		t.Errorf("expected deleted records %v, got %v", expected, registry.deleted)
+	}
+}
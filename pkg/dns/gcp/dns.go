@@ -0,0 +1,368 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	dnsv1 "google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// Provider manages records in Google Cloud DNS managed zones. The DNSZone ID is the name of the managed zone within the
+// configured project.
+type Provider struct {
+	service *dnsv1.Service
+	config  Config
+	logger  logr.Logger
+}
+
+// Config is the necessary input to configure the manager.
+type Config struct {
+	// Project is the GCP project that owns the managed zones.
+	Project string
+	// ClientOptions are passed through to the Cloud DNS client, e.g. to override the endpoint or the credentials.
+	ClientOptions []option.ClientOption
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.Project == "" {
+		return nil, fmt.Errorf("a GCP project is required")
+	}
+
+	service, err := dnsv1.NewService(context.Background(), config.ClientOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create Cloud DNS client: %v", err)
+	}
+
+	p := &Provider{
+		service: service,
+		config:  config,
+		logger:  log.Logger.WithName("gcp-cloud-dns").WithValues("project", config.Project),
+	}
+	if err := validateServiceEndpoints(p); err != nil {
+		return nil, fmt.Errorf("failed to validate GCP provider service endpoints: %v", err)
+	}
+
+	return p, nil
+}
+
+// validateServiceEndpoints validates that the Cloud DNS client can communicate with the API by listing the managed
+// zones of the project.
+func validateServiceEndpoints(provider *Provider) error {
+	var errs []error
+	if _, err := provider.service.ManagedZones.List(provider.config.Project).MaxResults(1).Do(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to list Cloud DNS managed zones: %v", err))
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	desired, err := recordSetsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	// Record sets that were previously published but are no longer part of the spec must be removed as well.
+	keys := recordSetKeys(desired)
+	lastPublished, err := recordSetsForEndpoints(endpointsFromZoneStatus(record, zone.ID))
+	if err != nil {
+		return err
+	}
+	for _, key := range recordSetKeys(lastPublished) {
+		if _, ok := desired[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	change := &dnsv1.Change{}
+	for _, key := range keys {
+		existing, err := p.getRecordSet(zone.ID, key)
+		if err != nil {
+			return err
+		}
+		expected := desired[key]
+		if existing != nil && expected != nil && recordSetsEqual(existing, expected) {
+			continue
+		}
+		if existing != nil {
+			change.Deletions = append(change.Deletions, existing)
+		}
+		if expected != nil {
+			change.Additions = append(change.Additions, expected)
+		}
+	}
+
+	if err := p.applyChange(zone.ID, change); err != nil {
+		return fmt.Errorf("couldn't update DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+	p.logger.Info("Upserted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
+	desired, err := recordSetsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	change := &dnsv1.Change{}
+	for _, key := range recordSetKeys(desired) {
+		existing, err := p.getRecordSet(zone.ID, key)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			change.Deletions = append(change.Deletions, existing)
+		}
+	}
+
+	if err := p.applyChange(zone.ID, change); err != nil {
+		return fmt.Errorf("couldn't delete DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+	p.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+// ReconcileHealthCheck is a no-op, as Cloud DNS only supports health checked routing policies for internal load
+// balancers, which GLBC does not manage.
+func (p *Provider) ReconcileHealthCheck(_ context.Context, hc v1.HealthCheck, endpoint *v1.Endpoint) error {
+	p.logger.V(3).Info("Health checks are not supported by Cloud DNS, skipping", "name", hc.Name, "endpoint", endpoint.SetID())
+	return nil
+}
+
+func (p *Provider) DeleteHealthCheck(_ context.Context, _ *v1.Endpoint) error {
+	return nil
+}
+
+// ZoneDomains returns the domain name of the managed zones matching the zone, i.e. the managed zone with its ID, or the
+// managed zones with all its tags as labels, indexed by managed zone name.
+func (p *Provider) ZoneDomains(zone v1.DNSZone) (map[string]string, error) {
+	domains := map[string]string{}
+	if zone.ID != "" {
+		managedZone, err := p.service.ManagedZones.Get(p.config.Project, zone.ID).Do()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get managed zone %s: %v", zone.ID, err)
+		}
+		domains[managedZone.Name] = strings.TrimSuffix(managedZone.DnsName, ".")
+		return domains, nil
+	}
+	if len(zone.Tags) == 0 {
+		return domains, nil
+	}
+
+	err := p.service.ManagedZones.List(p.config.Project).Pages(context.Background(), func(resp *dnsv1.ManagedZonesListResponse) error {
+		for _, managedZone := range resp.ManagedZones {
+			if hasLabels(managedZone.Labels, zone.Tags) {
+				domains[managedZone.Name] = strings.TrimSuffix(managedZone.DnsName, ".")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list managed zones with labels %v: %v", zone.Tags, err)
+	}
+	return domains, nil
+}
+
+func hasLabels(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Provider) applyChange(zoneID string, change *dnsv1.Change) error {
+	if len(change.Additions) == 0 && len(change.Deletions) == 0 {
+		return nil
+	}
+	resp, err := p.service.Changes.Create(p.config.Project, zoneID, change).Do()
+	if err != nil {
+		return err
+	}
+	p.logger.V(3).Info("Applied Cloud DNS change", "zone", zoneID, "id", resp.Id, "status", resp.Status)
+	return nil
+}
+
+// getRecordSet returns the record set currently published in the zone for the given key, or nil if there is none.
+func (p *Provider) getRecordSet(zoneID string, key recordSetKey) (*dnsv1.ResourceRecordSet, error) {
+	resp, err := p.service.ResourceRecordSets.List(p.config.Project, zoneID).Name(key.name).Type(key.recordType).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record sets for %s %s in zone %s: %v", key.recordType, key.name, zoneID, err)
+	}
+	for _, rrset := range resp.Rrsets {
+		if rrset.Name == key.name && rrset.Type == key.recordType {
+			return rrset, nil
+		}
+	}
+	return nil, nil
+}
+
+type recordSetKey struct {
+	name       string
+	recordType string
+}
+
+// recordSetsForEndpoints groups the endpoints into Cloud DNS record sets. Cloud DNS allows a single record set per name
+// and type, so endpoints that share both are merged. When they carry a weight, or are distinguished by a set
+// identifier, they are mapped to the items of a weighted round robin routing policy.
+func recordSetsForEndpoints(endpoints []*v1.Endpoint) (map[recordSetKey]*dnsv1.ResourceRecordSet, error) {
+	grouped := map[recordSetKey][]*v1.Endpoint{}
+	for _, endpoint := range endpoints {
+		if len(endpoint.DNSName) == 0 {
+			return nil, fmt.Errorf("domain is required")
+		}
+		if len(endpoint.Targets) == 0 {
+			return nil, fmt.Errorf("targets is required")
+		}
+		key := recordSetKey{name: ensureTrailingDot(endpoint.DNSName), recordType: endpoint.RecordType}
+		grouped[key] = append(grouped[key], endpoint)
+	}
+
+	recordSets := make(map[recordSetKey]*dnsv1.ResourceRecordSet, len(grouped))
+	for key, group := range grouped {
+		rrset := &dnsv1.ResourceRecordSet{
+			Name: key.name,
+			Type: key.recordType,
+			Ttl:  int64(group[0].RecordTTL),
+		}
+		if isWeighted(group) {
+			policy := &dnsv1.RRSetRoutingPolicyWrrPolicy{}
+			for _, endpoint := range group {
+				weight, err := endpointWeight(endpoint)
+				if err != nil {
+					return nil, err
+				}
+				policy.Items = append(policy.Items, &dnsv1.RRSetRoutingPolicyWrrPolicyWrrPolicyItem{
+					Weight:  weight,
+					Rrdatas: rrdatas(key.recordType, endpoint.Targets),
+					// A zero weight is meaningful and must be sent explicitly
+					ForceSendFields: []string{"Weight"},
+				})
+			}
+			sort.Slice(policy.Items, func(i, j int) bool {
+				return strings.Join(policy.Items[i].Rrdatas, ",") < strings.Join(policy.Items[j].Rrdatas, ",")
+			})
+			rrset.RoutingPolicy = &dnsv1.RRSetRoutingPolicy{Wrr: policy}
+		} else {
+			for _, endpoint := range group {
+				rrset.Rrdatas = append(rrset.Rrdatas, rrdatas(key.recordType, endpoint.Targets)...)
+			}
+		}
+		recordSets[key] = rrset
+	}
+	return recordSets, nil
+}
+
+func isWeighted(endpoints []*v1.Endpoint) bool {
+	for _, endpoint := range endpoints {
+		if _, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight); ok {
+			return true
+		}
+		if endpoint.SetIdentifier != "" && len(endpoints) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointWeight returns the weight of the endpoint, using the same provider specific property as the AWS provider.
+// Endpoints without weight get an equal share.
+func endpointWeight(endpoint *v1.Endpoint) (float64, error) {
+	prop, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight)
+	if !ok {
+		return 1, nil
+	}
+	weight, err := strconv.ParseFloat(prop.Value, 64)
+	if err != nil || weight < 0 {
+		return 0, fmt.Errorf("invalid weight %q for endpoint %s", prop.Value, endpoint.SetID())
+	}
+	return weight, nil
+}
+
+func rrdatas(recordType string, targets v1.Targets) []string {
+	values := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if recordType == string(v1.CNAMERecordType) {
+			target = ensureTrailingDot(target)
+		}
+		values = append(values, target)
+	}
+	return values
+}
+
+func recordSetKeys(recordSets map[recordSetKey]*dnsv1.ResourceRecordSet) []recordSetKey {
+	keys := make([]recordSetKey, 0, len(recordSets))
+	for key := range recordSets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name == keys[j].name {
+			return keys[i].recordType < keys[j].recordType
+		}
+		return keys[i].name < keys[j].name
+	})
+	return keys
+}
+
+// recordSetsEqual compares the fields of the record sets managed by GLBC.
+func recordSetsEqual(a, b *dnsv1.ResourceRecordSet) bool {
+	if a.Name != b.Name || a.Type != b.Type || a.Ttl != b.Ttl {
+		return false
+	}
+	if !equality.Semantic.DeepEqual(a.Rrdatas, b.Rrdatas) {
+		return false
+	}
+	wrrA, wrrB := wrrPolicy(a), wrrPolicy(b)
+	if (wrrA == nil) != (wrrB == nil) {
+		return false
+	}
+	if wrrA == nil {
+		return true
+	}
+	if len(wrrA.Items) != len(wrrB.Items) {
+		return false
+	}
+	for i := range wrrA.Items {
+		if wrrA.Items[i].Weight != wrrB.Items[i].Weight || !equality.Semantic.DeepEqual(wrrA.Items[i].Rrdatas, wrrB.Items[i].Rrdatas) {
+			return false
+		}
+	}
+	return true
+}
+
+func wrrPolicy(rrset *dnsv1.ResourceRecordSet) *dnsv1.RRSetRoutingPolicyWrrPolicy {
+	if rrset.RoutingPolicy == nil {
+		return nil
+	}
+	return rrset.RoutingPolicy.Wrr
+}
+
+func endpointsFromZoneStatus(record *v1.DNSRecord, zoneID string) []*v1.Endpoint {
+	for _, zoneStatus := range record.Status.Zones {
+		if zoneStatus.DNSZone.ID == zoneID {
+			return zoneStatus.Endpoints
+		}
+	}
+	return []*v1.Endpoint{}
+}
+
+func ensureTrailingDot(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
@@ -0,0 +1,217 @@
+package gcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	dnsv1 "google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+const (
+	testProject = "test-project"
+	testZone    = "test-zone"
+)
+
+// fakeCloudDNS is a minimal in-memory implementation of the Cloud DNS API endpoints used by the provider.
+type fakeCloudDNS struct {
+	mu      sync.Mutex
+	rrsets  map[recordSetKey]*dnsv1.ResourceRecordSet
+	changes []*dnsv1.Change
+}
+
+func newFakeCloudDNS() *fakeCloudDNS {
+	return &fakeCloudDNS{rrsets: map[recordSetKey]*dnsv1.ResourceRecordSet{}}
+}
+
+func (f *fakeCloudDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	zonePath := "/dns/v1/projects/" + testProject + "/managedZones"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == zonePath:
+		writeJSON(w, &dnsv1.ManagedZonesListResponse{ManagedZones: []*dnsv1.ManagedZone{{Name: testZone}}})
+	case r.Method == http.MethodGet && r.URL.Path == zonePath+"/"+testZone+"/rrsets":
+		resp := &dnsv1.ResourceRecordSetsListResponse{}
+		key := recordSetKey{name: r.URL.Query().Get("name"), recordType: r.URL.Query().Get("type")}
+		if rrset, ok := f.rrsets[key]; ok {
+			resp.Rrsets = append(resp.Rrsets, rrset)
+		}
+		writeJSON(w, resp)
+	case r.Method == http.MethodPost && r.URL.Path == zonePath+"/"+testZone+"/changes":
+		change := &dnsv1.Change{}
+		if err := json.NewDecoder(r.Body).Decode(change); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, deletion := range change.Deletions {
+			key := recordSetKey{name: deletion.Name, recordType: deletion.Type}
+			if _, ok := f.rrsets[key]; !ok {
+				http.Error(w, "record set not found", http.StatusNotFound)
+				return
+			}
+			delete(f.rrsets, key)
+		}
+		for _, addition := range change.Additions {
+			key := recordSetKey{name: addition.Name, recordType: addition.Type}
+			if _, ok := f.rrsets[key]; ok {
+				http.Error(w, "record set already exists", http.StatusConflict)
+				return
+			}
+			f.rrsets[key] = addition
+		}
+		f.changes = append(f.changes, change)
+		change.Id = "1"
+		change.Status = "done"
+		writeJSON(w, change)
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newTestProvider(t *testing.T, fake *fakeCloudDNS) *Provider {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	provider, err := NewProvider(Config{
+		Project: testProject,
+		ClientOptions: []option.ClientOption{
+			option.WithEndpoint(server.URL),
+			option.WithoutAuthentication(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	return provider
+}
+
+func weightedEndpoint(name, ip, weight string) *v1.Endpoint {
+	return &v1.Endpoint{
+		DNSName:       name,
+		RecordType:    string(v1.ARecordType),
+		SetIdentifier: ip,
+		Targets:       v1.Targets{ip},
+		RecordTTL:     60,
+		ProviderSpecific: v1.ProviderSpecific{
+			{Name: aws.ProviderSpecificWeight, Value: weight},
+		},
+	}
+}
+
+func TestProviderEnsure(t *testing.T) {
+	g := gomega.NewWithT(t)
+	fake := newFakeCloudDNS()
+	provider := newTestProvider(t, fake)
+	zone := v1.DNSZone{ID: testZone}
+
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				weightedEndpoint("app.example.com", "192.168.0.2", "60"),
+				weightedEndpoint("app.example.com", "192.168.0.1", "120"),
+			},
+		},
+	}
+
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.changes).To(gomega.HaveLen(1))
+
+	rrset := fake.rrsets[recordSetKey{name: "app.example.com.", recordType: "A"}]
+	g.Expect(rrset).NotTo(gomega.BeNil())
+	g.Expect(rrset.Ttl).To(gomega.Equal(int64(60)))
+	g.Expect(rrset.Rrdatas).To(gomega.BeEmpty())
+	g.Expect(rrset.RoutingPolicy.Wrr.Items).To(gomega.HaveLen(2))
+	g.Expect(rrset.RoutingPolicy.Wrr.Items[0].Rrdatas).To(gomega.Equal([]string{"192.168.0.1"}))
+	g.Expect(rrset.RoutingPolicy.Wrr.Items[0].Weight).To(gomega.Equal(float64(120)))
+	g.Expect(rrset.RoutingPolicy.Wrr.Items[1].Rrdatas).To(gomega.Equal([]string{"192.168.0.2"}))
+	g.Expect(rrset.RoutingPolicy.Wrr.Items[1].Weight).To(gomega.Equal(float64(60)))
+
+	// Ensuring the same record again must not issue another change
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.changes).To(gomega.HaveLen(1))
+
+	// Changing a weight replaces the record set
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+	record.Spec.Endpoints = []*v1.Endpoint{
+		weightedEndpoint("app.example.com", "192.168.0.1", "120"),
+		weightedEndpoint("app.example.com", "192.168.0.2", "120"),
+	}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.changes).To(gomega.HaveLen(2))
+	g.Expect(fake.changes[1].Deletions).To(gomega.HaveLen(1))
+	g.Expect(fake.changes[1].Additions).To(gomega.HaveLen(1))
+	rrset = fake.rrsets[recordSetKey{name: "app.example.com.", recordType: "A"}]
+	g.Expect(rrset.RoutingPolicy.Wrr.Items[1].Weight).To(gomega.Equal(float64(120)))
+}
+
+func TestProviderEnsureRemovesStaleRecordSets(t *testing.T) {
+	g := gomega.NewWithT(t)
+	fake := newFakeCloudDNS()
+	provider := newTestProvider(t, fake)
+	zone := v1.DNSZone{ID: testZone}
+
+	old := &v1.Endpoint{DNSName: "old.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}, RecordTTL: 60}
+	record := &v1.DNSRecord{Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{old}}}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: []*v1.Endpoint{old}}}
+	record.Spec.Endpoints = []*v1.Endpoint{
+		{DNSName: "new.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}, RecordTTL: 60},
+	}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+
+	g.Expect(fake.rrsets).To(gomega.HaveLen(1))
+	rrset := fake.rrsets[recordSetKey{name: "new.example.com.", recordType: "A"}]
+	g.Expect(rrset).NotTo(gomega.BeNil())
+	g.Expect(rrset.RoutingPolicy).To(gomega.BeNil())
+	g.Expect(rrset.Rrdatas).To(gomega.Equal([]string{"192.168.0.1"}))
+}
+
+func TestProviderDelete(t *testing.T) {
+	g := gomega.NewWithT(t)
+	fake := newFakeCloudDNS()
+	provider := newTestProvider(t, fake)
+	zone := v1.DNSZone{ID: testZone}
+
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				weightedEndpoint("app.example.com", "192.168.0.1", "120"),
+			},
+		},
+	}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.rrsets).To(gomega.HaveLen(1))
+
+	g.Expect(provider.Delete(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.rrsets).To(gomega.BeEmpty())
+
+	// Deleting a record that is not published is a no-op
+	g.Expect(provider.Delete(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.changes).To(gomega.HaveLen(2))
+}
+
+func TestRecordSetsForEndpointsInvalidWeight(t *testing.T) {
+	g := gomega.NewWithT(t)
+	_, err := recordSetsForEndpoints([]*v1.Endpoint{
+		weightedEndpoint("app.example.com", "192.168.0.1", "heavy"),
+	})
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(strings.Contains(err.Error(), "invalid weight")).To(gomega.BeTrue())
+}
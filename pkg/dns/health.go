@@ -13,14 +13,9 @@ type HealthCheckReconciler interface {
 	DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint) error
 }
 
-type fakeHealthCheckReconciler struct{}
-
-func (*fakeHealthCheckReconciler) ReconcileHealthCheck(ctx context.Context, _ v1.HealthCheck, _ *v1.Endpoint) error {
-	return nil
-}
-
-func (*fakeHealthCheckReconciler) DeleteHealthCheck(ctx context.Context, _ *v1.Endpoint) error {
-	return nil
+// HealthCheckStatusGetter is implemented by the providers that report the health of the health checks, which is used
+// to reflect the failover of the records in their status. An endpoint with several addresses is healthy while all its
+// addresses are.
+type HealthCheckStatusGetter interface {
+	HealthCheckHealthy(ctx context.Context, endpoint *v1.Endpoint) (bool, error)
 }
-
-var _ HealthCheckReconciler = &fakeHealthCheckReconciler{}
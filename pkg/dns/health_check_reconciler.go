@@ -79,6 +79,12 @@ func (c *Controller) reconcileHealthCheck(ctx context.Context, config *healthChe
 			c.Logger.Info("Skipping health check creation: no address set", "record", dnsRecord, "endpoint", dnsEndpoint.DNSName)
 			continue
 		}
+		// Health checks probe IP addresses, the endpoints of other types, e.g. the CNAME records pointing to the
+		// continent specific hosts of geo-aware records, rely on the health checks of the records they point to
+		if dnsEndpoint.RecordType != string(v1.ARecordType) && dnsEndpoint.RecordType != string(v1.AAAARecordType) {
+			c.Logger.V(3).Info("Skipping health check creation: not an address record", "record", dnsRecord, "endpoint", dnsEndpoint.DNSName, "type", dnsEndpoint.RecordType)
+			continue
+		}
 
 		endpointId, err := idForEndpoint(dnsRecord, dnsEndpoint)
 		if err != nil {
@@ -96,9 +102,10 @@ func (c *Controller) reconcileHealthCheck(ctx context.Context, config *healthChe
 
 		c.Logger.Info("Reconciling health check for endpoint", "name", dnsEndpoint.DNSName, "identifier", dnsEndpoint.SetIdentifier)
 
-		err = c.dnsProvider.ReconcileHealthCheck(ctx, spec, dnsEndpoint)
-		if err != nil {
-			return err
+		for _, dnsProvider := range c.providers() {
+			if err := dnsProvider.ReconcileHealthCheck(ctx, spec, dnsEndpoint); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -108,8 +115,12 @@ func (c *Controller) reconcileHealthCheck(ctx context.Context, config *healthChe
 func (c *Controller) reconcileHealthCheckDeletion(ctx context.Context, dnsRecord *v1.DNSRecord) error {
 
 	for _, zone := range dnsRecord.Status.Zones {
+		dnsProvider, _, ok := c.providerForZoneStatus(zone)
+		if !ok {
+			continue
+		}
 		for _, endpoint := range zone.Endpoints {
-			if err := c.dnsProvider.DeleteHealthCheck(ctx, endpoint); err != nil {
+			if err := dnsProvider.DeleteHealthCheck(ctx, endpoint); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,183 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	gonet "net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultHostCacheMaxAge is the default age beyond which the last known good addresses of a host are no longer used
+const DefaultHostCacheMaxAge = time.Hour
+
+// CachedHostResolver is a HostResolver that keeps the last known good addresses of the hosts it resolves, so that they
+// can be used when their lookup fails transiently, e.g. while the resolver is unavailable.
+type CachedHostResolver struct {
+	HostResolver
+
+	maxAge time.Duration
+	now    func() time.Time
+	// staleHosts is the number of stale hosts of the resolver
+	staleHosts prometheus.Gauge
+
+	mu    sync.Mutex
+	hosts map[string]*cachedHostAddresses
+	// failing are the hosts whose lookup fails transiently, since they were last resolved
+	failing map[string]*failingHost
+}
+
+type cachedHostAddresses struct {
+	addresses  []HostAddress
+	resolvedAt time.Time
+	// stale is set when the addresses are served after a failed lookup, until the host is resolved again
+	stale bool
+}
+
+type failingHost struct {
+	// since is the time the host was last resolved, or the time its lookup first failed if it was not resolved since
+	// the resolver started
+	since        time.Time
+	lastFailedAt time.Time
+	// stale is set when the published addresses of the host are kept, until the host is resolved again
+	stale bool
+}
+
+var _ HostResolver = &CachedHostResolver{}
+
+// NewCachedHostResolver returns a resolver keeping the last known good addresses of the hosts resolved with the inner
+// resolver, for at most the given age. The stale hosts are reported with the name of the controller using the resolver.
+func NewCachedHostResolver(inner HostResolver, maxAge time.Duration, controllerName string) *CachedHostResolver {
+	return &CachedHostResolver{
+		HostResolver: inner,
+		maxAge:       maxAge,
+		now:          time.Now,
+		staleHosts:   dnsLookupStaleHosts.WithLabelValues(controllerName),
+		hosts:        map[string]*cachedHostAddresses{},
+		failing:      map[string]*failingHost{},
+	}
+}
+
+// LookupIPAddr looks up the addresses of the host, and keeps them as its last known good addresses. The addresses of
+// the hosts that no longer exist are forgotten.
+func (r *CachedHostResolver) LookupIPAddr(ctx context.Context, host string) ([]HostAddress, error) {
+	addresses, err := r.HostResolver.LookupIPAddr(ctx, host)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil && len(addresses) > 0:
+		r.hosts[host] = &cachedHostAddresses{addresses: addresses, resolvedAt: r.now()}
+		delete(r.failing, host)
+		r.expire()
+	case err != nil && !IsTransientLookupError(err):
+		delete(r.hosts, host)
+		delete(r.failing, host)
+	case err != nil:
+		r.fail(host)
+	}
+	r.updateStaleHosts()
+	return addresses, err
+}
+
+// LastKnownAddresses returns the last known good addresses of the host, and their age, as long as they are not older
+// than the max age of the resolver. The addresses are flagged as stale until the host is resolved again.
+func (r *CachedHostResolver) LastKnownAddresses(host string) ([]HostAddress, time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defer r.updateStaleHosts()
+	cached, ok := r.hosts[host]
+	if !ok {
+		return nil, 0, false
+	}
+	age := r.now().Sub(cached.resolvedAt)
+	if r.maxAge > 0 && age > r.maxAge {
+		delete(r.hosts, host)
+		return nil, 0, false
+	}
+	cached.stale = true
+	dnsLookupFallbackTotal.Inc()
+	return cached.addresses, age, true
+}
+
+// KeepPublishedAddresses returns whether the addresses the host is published with can be kept, when it has no last
+// known good addresses, e.g. after a restart, and for how long its lookup has been failing transiently. They can be
+// kept as long as the host has not been resolved for more than the max age of the resolver, and are flagged as stale
+// until the host is resolved again.
+func (r *CachedHostResolver) KeepPublishedAddresses(host string) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defer r.updateStaleHosts()
+	failing, ok := r.failing[host]
+	if !ok {
+		return 0, false
+	}
+	age := r.now().Sub(failing.since)
+	if r.maxAge > 0 && age > r.maxAge {
+		failing.stale = false
+		return 0, false
+	}
+	failing.stale = true
+	dnsLookupFallbackTotal.Inc()
+	return age, true
+}
+
+// fail records the transient failure of the lookup of the host, since the time it was last resolved
+func (r *CachedHostResolver) fail(host string) {
+	failing, ok := r.failing[host]
+	if !ok {
+		failing = &failingHost{since: r.now()}
+		if cached, ok := r.hosts[host]; ok {
+			failing.since = cached.resolvedAt
+		}
+		r.failing[host] = failing
+	}
+	failing.lastFailedAt = r.now()
+}
+
+// expire forgets the addresses older than the max age, e.g. of the hosts no longer resolved, and the failures of the
+// hosts no longer looked up
+func (r *CachedHostResolver) expire() {
+	if r.maxAge <= 0 {
+		return
+	}
+	for host, cached := range r.hosts {
+		if r.now().Sub(cached.resolvedAt) > r.maxAge {
+			delete(r.hosts, host)
+		}
+	}
+	for host, failing := range r.failing {
+		if r.now().Sub(failing.lastFailedAt) > r.maxAge {
+			delete(r.failing, host)
+		}
+	}
+}
+
+func (r *CachedHostResolver) updateStaleHosts() {
+	stale := 0
+	for _, cached := range r.hosts {
+		if cached.stale {
+			stale++
+		}
+	}
+	for host, failing := range r.failing {
+		if _, cached := r.hosts[host]; failing.stale && !cached {
+			stale++
+		}
+	}
+	r.staleHosts.Set(float64(stale))
+}
+
+// IsTransientLookupError returns whether the lookup of a host failed for a reason other than the host not existing,
+// e.g. a timeout, in which case its last known good addresses can be used.
+func IsTransientLookupError(err error) bool {
+	if err == nil || IsNoSuchHostError(err) {
+		return false
+	}
+	var dnsErr *gonet.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	gonet "net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type mockHostResolver struct {
+	addresses []HostAddress
+	err       error
+}
+
+func (m *mockHostResolver) LookupIPAddr(_ context.Context, _ string) ([]HostAddress, error) {
+	return m.addresses, m.err
+}
+
+func TestCachedHostResolver(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	inner := &mockHostResolver{addresses: []HostAddress{{Host: "lb.example.com", IP: gonet.ParseIP("10.0.0.1")}}}
+	resolver := NewCachedHostResolver(inner, time.Hour, "test")
+	resolver.now = func() time.Time { return now }
+
+	if _, _, ok := resolver.LastKnownAddresses("lb.example.com"); ok {
+		t.Errorf("expected no last known addresses before the host is resolved")
+	}
+	if _, err := resolver.LookupIPAddr(context.TODO(), "lb.example.com"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// The last known good addresses are kept when the lookup fails transiently
+	inner.addresses, inner.err = nil, errors.New("i/o timeout")
+	now = now.Add(10 * time.Minute)
+	if _, err := resolver.LookupIPAddr(context.TODO(), "lb.example.com"); err == nil {
+		t.Fatalf("expected the lookup error to be returned")
+	}
+	addresses, age, ok := resolver.LastKnownAddresses("lb.example.com")
+	if !ok || len(addresses) != 1 || !addresses[0].IP.Equal(gonet.ParseIP("10.0.0.1")) || age != 10*time.Minute {
+		t.Errorf("expected the last known addresses resolved 10m ago but got %v, %s", addresses, age)
+	}
+	if !resolver.hosts["lb.example.com"].stale {
+		t.Errorf("expected the last known addresses to be flagged stale")
+	}
+
+	// They are no longer used beyond the max age
+	now = now.Add(time.Hour)
+	if _, _, ok := resolver.LastKnownAddresses("lb.example.com"); ok {
+		t.Errorf("expected the last known addresses to expire")
+	}
+
+	// They are forgotten when the host no longer exists
+	inner.addresses, inner.err = []HostAddress{{Host: "lb.example.com", IP: gonet.ParseIP("10.0.0.2")}}, nil
+	if _, err := resolver.LookupIPAddr(context.TODO(), "lb.example.com"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if resolver.hosts["lb.example.com"].stale {
+		t.Errorf("expected the addresses not to be stale once resolved again")
+	}
+	inner.addresses, inner.err = nil, NoSuchHost
+	_, _ = resolver.LookupIPAddr(context.TODO(), "lb.example.com")
+	if _, _, ok := resolver.LastKnownAddresses("lb.example.com"); ok {
+		t.Errorf("expected the addresses of a host that no longer exists to be forgotten")
+	}
+}
+
+func TestCachedHostResolverKeepPublishedAddresses(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	inner := &mockHostResolver{err: errors.New("i/o timeout")}
+	resolver := NewCachedHostResolver(inner, time.Hour, "test")
+	resolver.now = func() time.Time { return now }
+
+	if _, ok := resolver.KeepPublishedAddresses("lb.example.com"); ok {
+		t.Errorf("expected the published addresses not to be kept before the host is looked up")
+	}
+
+	// The published addresses are kept while the lookup fails transiently, e.g. after a restart
+	_, _ = resolver.LookupIPAddr(context.TODO(), "lb.example.com")
+	now = now.Add(10 * time.Minute)
+	_, _ = resolver.LookupIPAddr(context.TODO(), "lb.example.com")
+	age, ok := resolver.KeepPublishedAddresses("lb.example.com")
+	if !ok || age != 10*time.Minute {
+		t.Errorf("expected the published addresses to be kept for a host failing for 10m but got %t, %s", ok, age)
+	}
+	if !resolver.failing["lb.example.com"].stale {
+		t.Errorf("expected the published addresses to be flagged stale")
+	}
+
+	// They are no longer kept once the host has not been resolved for the max age
+	now = now.Add(time.Hour)
+	_, _ = resolver.LookupIPAddr(context.TODO(), "lb.example.com")
+	if _, ok := resolver.KeepPublishedAddresses("lb.example.com"); ok {
+		t.Errorf("expected the published addresses not to be kept beyond the max age")
+	}
+
+	// The max age is counted from the last time the host was resolved
+	inner.addresses, inner.err = []HostAddress{{Host: "lb.example.com", IP: gonet.ParseIP("10.0.0.1")}}, nil
+	_, _ = resolver.LookupIPAddr(context.TODO(), "lb.example.com")
+	now = now.Add(50 * time.Minute)
+	inner.addresses, inner.err = nil, errors.New("i/o timeout")
+	_, _ = resolver.LookupIPAddr(context.TODO(), "lb.example.com")
+	if age, ok := resolver.KeepPublishedAddresses("lb.example.com"); !ok || age != 50*time.Minute {
+		t.Errorf("expected the published addresses to be kept for a host resolved 50m ago but got %t, %s", ok, age)
+	}
+	now = now.Add(20 * time.Minute)
+	if _, ok := resolver.KeepPublishedAddresses("lb.example.com"); ok {
+		t.Errorf("expected the published addresses not to be kept for a host resolved more than the max age ago")
+	}
+
+	// They are not kept for the hosts that no longer exist
+	inner.err = hostNotFoundError("lb.example.com")
+	_, _ = resolver.LookupIPAddr(context.TODO(), "lb.example.com")
+	if _, ok := resolver.KeepPublishedAddresses("lb.example.com"); ok {
+		t.Errorf("expected the published addresses of a host that no longer exists not to be kept")
+	}
+}
+
+func TestIsTransientLookupError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: NoSuchHost, want: false},
+		{err: &gonet.DNSError{Err: "no such host", IsNotFound: true}, want: false},
+		{err: hostNotFoundError("lb.example.com"), want: false},
+		{err: &gonet.DNSError{Err: "i/o timeout", IsTimeout: true}, want: true},
+		{err: errors.New("connection refused"), want: true},
+	}
+	for _, tt := range tests {
+		if got := IsTransientLookupError(tt.err); got != tt.want {
+			t.Errorf("IsTransientLookupError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCachedHostResolverStaleHostsPerController(t *testing.T) {
+	inner := &mockHostResolver{addresses: []HostAddress{{Host: "lb.example.com", IP: gonet.ParseIP("10.0.0.1")}}}
+	ingresses := NewCachedHostResolver(inner, time.Hour, "ingresses")
+	routes := NewCachedHostResolver(inner, time.Hour, "routes")
+	for _, resolver := range []*CachedHostResolver{ingresses, routes} {
+		if _, err := resolver.LookupIPAddr(context.TODO(), "lb.example.com"); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	// The stale hosts of a resolver are not overwritten by the other resolvers
+	_, _, _ = ingresses.LastKnownAddresses("lb.example.com")
+	_, _ = routes.LookupIPAddr(context.TODO(), "lb.example.com")
+	if stale := testutil.ToFloat64(dnsLookupStaleHosts.WithLabelValues("ingresses")); stale != 1 {
+		t.Errorf("expected 1 stale host for the ingresses but got %v", stale)
+	}
+	if stale := testutil.ToFloat64(dnsLookupStaleHosts.WithLabelValues("routes")); stale != 0 {
+		t.Errorf("expected no stale host for the routes but got %v", stale)
+	}
+}
@@ -51,7 +51,7 @@ func (r *ConfigMapHostResolver) LookupIPAddr(ctx context.Context, host string) (
 
 	ipsValue, ok := configMap.Data[host]
 	if !ok {
-		return nil, &gonet.DNSError{Err: fmt.Sprintf("host %s not found in ConfigMap %s/%s", host, r.Name, r.Namespace)}
+		return nil, &gonet.DNSError{Err: fmt.Sprintf("host %s not found in ConfigMap %s/%s", host, r.Name, r.Namespace), Name: host, IsNotFound: true}
 	}
 
 	var ips []struct {
@@ -122,34 +122,74 @@ func (hr *DefaultHostResolver) LookupIPAddr(ctx context.Context, host string) ([
 		return nil, err
 	}
 
+	// The host is not found only when none of the servers failed to answer, so that the transient errors are returned
+	lookupErr := hostNotFoundError(host)
 	for _, server := range cfg.Servers {
+		results, err := hr.lookupServer(ctx, fmt.Sprintf("%s:53", server), host)
+		if err != nil {
+			if IsTransientLookupError(err) {
+				lookupErr = err
+			}
+			continue
+		}
+
+		return results, nil
+	}
+
+	return nil, lookupErr
+}
+
+// lookupServer looks up both the IPv4 and IPv6 addresses of the host from the given server, so that dual-stack and
+// IPv6 only hosts are supported. The addresses of either family are returned even if the lookup of the other family
+// fails, an error being returned only when none of the lookups returned addresses, or when the host is not found.
+func (hr *DefaultHostResolver) lookupServer(ctx context.Context, address, host string) ([]HostAddress, error) {
+	var results []HostAddress
+	var lookupErr error
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
 		m := dns.Msg{}
-		m.SetQuestion(fmt.Sprintf("%s.", host), dns.TypeA)
+		m.SetQuestion(fmt.Sprintf("%s.", host), qtype)
 
-		r, _, err := hr.Client.ExchangeContext(ctx, &m, fmt.Sprintf("%s:53", server))
+		r, _, err := hr.Client.ExchangeContext(ctx, &m, address)
 		if err != nil {
-			return nil, err
+			lookupErr = err
+			continue
 		}
-
-		if len(r.Answer) == 0 {
+		if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+			lookupErr = fmt.Errorf("lookup of %s failed: %s", host, dns.RcodeToString[r.Rcode])
 			continue
 		}
 
-		results := make([]HostAddress, 0, len(r.Answer))
 		for _, answer := range r.Answer {
-			if a, ok := answer.(*dns.A); ok {
+			switch rr := answer.(type) {
+			case *dns.A:
+				results = append(results, HostAddress{
+					Host: host,
+					IP:   rr.A,
+					TTL:  time.Duration(rr.Hdr.Ttl) * time.Second,
+				})
+			case *dns.AAAA:
 				results = append(results, HostAddress{
 					Host: host,
-					IP:   a.A,
-					TTL:  time.Duration(a.Hdr.Ttl) * time.Second,
+					IP:   rr.AAAA,
+					TTL:  time.Duration(rr.Hdr.Ttl) * time.Second,
 				})
 			}
 		}
-
+	}
+	if len(results) > 0 {
 		return results, nil
 	}
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+
+	return nil, hostNotFoundError(host)
+}
 
-	return nil, errors.New("no records found for host")
+// hostNotFoundError returns the error of the lookups of the hosts that do not exist, or have no addresses, which is not
+// a transient lookup error
+func hostNotFoundError(host string) error {
+	return &gonet.DNSError{Err: "no records found for host", Name: host, IsNotFound: true}
 }
 
 type SafeHostResolver struct {
@@ -0,0 +1,76 @@
+package dns
+
+import (
+	"context"
+	gonet "net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startDNSServer starts a DNS server answering the queries of the given types for any host with the given addresses,
+// or with no address when empty, and leaving the other queries unanswered, and returns its address
+func startDNSServer(t *testing.T, answers map[uint16]string) string {
+	conn, err := gonet.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		question := req.Question[0]
+		answer, ok := answers[question.Qtype]
+		if !ok {
+			return
+		}
+		m := &dns.Msg{}
+		m.SetReply(req)
+		if answer != "" {
+			rr, err := dns.NewRR(question.Name + " 60 IN " + dns.TypeToString[question.Qtype] + " " + answer)
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+				return
+			}
+			m.Answer = []dns.RR{rr}
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return conn.LocalAddr().String()
+}
+
+func TestDefaultHostResolverLookupServer(t *testing.T) {
+	resolver := &DefaultHostResolver{Client: dns.Client{Timeout: 100 * time.Millisecond}}
+
+	// The IPv4 addresses are returned when the IPv6 lookup fails
+	address := startDNSServer(t, map[uint16]string{dns.TypeA: "10.0.0.1"})
+	addresses, err := resolver.lookupServer(context.TODO(), address, "lb.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(addresses) != 1 || !addresses[0].IP.Equal(gonet.ParseIP("10.0.0.1")) || addresses[0].TTL != time.Minute {
+		t.Errorf("expected the IPv4 address but got %v", addresses)
+	}
+
+	// Both families are returned for dual-stack hosts
+	address = startDNSServer(t, map[uint16]string{dns.TypeA: "10.0.0.1", dns.TypeAAAA: "fd00::1"})
+	addresses, err = resolver.lookupServer(context.TODO(), address, "lb.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(addresses) != 2 {
+		t.Errorf("expected the IPv4 and IPv6 addresses but got %v", addresses)
+	}
+
+	// The error is returned when none of the lookups returned addresses
+	address = startDNSServer(t, nil)
+	if _, err := resolver.lookupServer(context.TODO(), address, "lb.example.com"); !IsTransientLookupError(err) {
+		t.Errorf("expected the transient lookup error to be returned but got %v", err)
+	}
+
+	// The host is not found when the server answers without addresses
+	address = startDNSServer(t, map[uint16]string{dns.TypeA: "", dns.TypeAAAA: ""})
+	if _, err := resolver.lookupServer(context.TODO(), address, "lb.example.com"); err == nil || IsTransientLookupError(err) {
+		t.Errorf("expected the host not to be found but got %v", err)
+	}
+}
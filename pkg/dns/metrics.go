@@ -0,0 +1,68 @@
+package dns
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kuadrant/kcp-glbc/pkg/metrics"
+)
+
+const (
+	providerLabel   = "provider"
+	modeLabel       = "mode"
+	controllerLabel = "controller"
+)
+
+var (
+	// dnsRecordDriftTotal is a prometheus counter metrics which holds the total
+	// number of published endpoints found missing from, or differing in, the
+	// live zones.
+	dnsRecordDriftTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "glbc_dns_record_drift_total",
+			Help: "GLBC total number of DNS record endpoints drifted from the live zones",
+		},
+		[]string{providerLabel, modeLabel},
+	)
+
+	// dnsOrphanedRecordsDeletedTotal is a prometheus counter metrics which
+	// holds the total number of owned record sets deleted by the garbage
+	// collector, as their DNSRecord no longer exists.
+	dnsOrphanedRecordsDeletedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "glbc_dns_orphaned_records_deleted_total",
+			Help: "GLBC total number of orphaned DNS record sets deleted by the garbage collector",
+		},
+		[]string{providerLabel},
+	)
+
+	// dnsLookupStaleHosts is a prometheus gauge metrics which holds the number
+	// of hosts whose last known good addresses are served, as their lookup
+	// fails, per controller resolving them.
+	dnsLookupStaleHosts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "glbc_dns_lookup_stale_hosts",
+			Help: "GLBC number of hosts served from their last known good addresses, as their lookup fails",
+		},
+		[]string{controllerLabel},
+	)
+
+	// dnsLookupFallbackTotal is a prometheus counter metrics which holds the
+	// total number of failed host lookups that fell back to the last known good
+	// addresses of the host.
+	dnsLookupFallbackTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "glbc_dns_lookup_fallback_total",
+			Help: "GLBC total number of failed host lookups served from the last known good addresses",
+		},
+	)
+)
+
+func init() {
+	// Register metrics with the global prometheus registry
+	metrics.Registry.MustRegister(
+		dnsRecordDriftTotal,
+		dnsOrphanedRecordsDeletedTotal,
+		dnsLookupStaleHosts,
+		dnsLookupFallbackTotal,
+	)
+}
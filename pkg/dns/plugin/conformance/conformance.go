@@ -0,0 +1,121 @@
+// Package conformance provides the tests DNS providers, and in particular
+// out-of-tree plugins served over the plugin protocol, must pass to be used
+// by GLBC.
+package conformance
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// Lookup returns the targets published to the DNS service for the given name.
+type Lookup func(dnsName string) ([]string, error)
+
+// Run runs the conformance tests against the provider. The records are
+// published to the zone, under the domain, and checked using the lookup.
+func Run(t *testing.T, provider dns.Provider, zone v1.DNSZone, domain string, lookup Lookup) {
+	targets := func(g gomega.Gomega, dnsName string) []string {
+		published, err := lookup(dnsName)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		sort.Strings(published)
+		return published
+	}
+
+	t.Run("Ensure publishes the endpoints", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		name := "conformance-ensure." + domain
+		record := newRecord(endpoint(name, "192.0.2.1", "120"), endpoint(name, "192.0.2.2", "120"))
+		t.Cleanup(func() { _ = provider.Delete(record, zone) })
+
+		g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+		g.Expect(targets(g, name)).To(gomega.Equal([]string{"192.0.2.1", "192.0.2.2"}))
+
+		// Ensure is idempotent
+		g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+		g.Expect(targets(g, name)).To(gomega.Equal([]string{"192.0.2.1", "192.0.2.2"}))
+	})
+
+	t.Run("Ensure removes the endpoints no longer expected", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		name := "conformance-update." + domain
+		record := newRecord(endpoint(name, "192.0.2.1", "120"), endpoint(name, "192.0.2.2", "120"))
+		t.Cleanup(func() { _ = provider.Delete(record, zone) })
+
+		g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+		record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+		record.Spec.Endpoints = []*v1.Endpoint{endpoint(name, "192.0.2.2", "120")}
+		g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+		g.Expect(targets(g, name)).To(gomega.Equal([]string{"192.0.2.2"}))
+	})
+
+	t.Run("Delete removes the endpoints", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		name := "conformance-delete." + domain
+		record := newRecord(endpoint(name, "192.0.2.1", "120"))
+
+		g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+		record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+		g.Expect(provider.Delete(record, zone)).To(gomega.Succeed())
+		g.Expect(targets(g, name)).To(gomega.BeEmpty())
+	})
+
+	t.Run("Ensure rejects invalid endpoints", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		name := "conformance-invalid." + domain
+		record := newRecord(&v1.Endpoint{DNSName: name, RecordType: string(v1.ARecordType), RecordTTL: 60})
+
+		g.Expect(provider.Ensure(record, zone)).NotTo(gomega.Succeed())
+		g.Expect(targets(g, name)).To(gomega.BeEmpty())
+	})
+
+	t.Run("Health checks can be reconciled and deleted", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		ctx := context.Background()
+		port := int64(80)
+		threshold := int64(3)
+		protocol := v1.HealthCheckProtocolHTTP
+		e := endpoint("conformance-health."+domain, "192.0.2.1", "120")
+		properties := e.ProviderSpecific.DeepCopy()
+
+		g.Expect(provider.ReconcileHealthCheck(ctx, v1.HealthCheck{
+			Id:               "conformance-health",
+			Name:             "conformance-health",
+			Port:             &port,
+			FailureThreshold: &threshold,
+			Path:             "/",
+			Protocol:         &protocol,
+		}, e)).To(gomega.Succeed())
+		// The health check is recorded on the endpoint, e.g. with its ID, so that it can be deleted
+		g.Expect(e.ProviderSpecific).To(gomega.ContainElements(properties))
+		g.Expect(len(e.ProviderSpecific)).To(gomega.BeNumerically(">", len(properties)))
+
+		g.Expect(provider.DeleteHealthCheck(ctx, e)).To(gomega.Succeed())
+		g.Expect(e.ProviderSpecific).To(gomega.ConsistOf(properties))
+	})
+}
+
+func newRecord(endpoints ...*v1.Endpoint) *v1.DNSRecord {
+	record := &v1.DNSRecord{Spec: v1.DNSRecordSpec{Endpoints: endpoints}}
+	record.Name = "conformance"
+	return record
+}
+
+func endpoint(name, ip, weight string) *v1.Endpoint {
+	return &v1.Endpoint{
+		DNSName:       name,
+		RecordType:    string(v1.ARecordType),
+		SetIdentifier: ip,
+		Targets:       v1.Targets{ip},
+		RecordTTL:     60,
+		ProviderSpecific: v1.ProviderSpecific{
+			{Name: aws.ProviderSpecificWeight, Value: weight},
+		},
+	}
+}
@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// Backend is implemented by plugins. It has the same methods as dns.Provider,
+// so that any provider can be served over the plugin protocol.
+type Backend interface {
+	Ensure(record *v1.DNSRecord, zone v1.DNSZone) error
+	Delete(record *v1.DNSRecord, zone v1.DNSZone) error
+	ReconcileHealthCheck(ctx context.Context, hc v1.HealthCheck, endpoint *v1.Endpoint) error
+	DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint) error
+}
+
+// NewHandler returns an HTTP handler serving the backend over the plugin protocol.
+func NewHandler(backend Backend) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(EnsurePath, recordHandler(backend.Ensure))
+	mux.Handle(DeletePath, recordHandler(backend.Delete))
+	mux.Handle(ReconcileHealthCheckPath, healthCheckHandler(func(r *http.Request, request *HealthCheckRequest) error {
+		if request.HealthCheck == nil {
+			return badRequest("healthCheck is required")
+		}
+		return backend.ReconcileHealthCheck(r.Context(), healthCheckFromWire(request.HealthCheck), request.Endpoint)
+	}))
+	mux.Handle(DeleteHealthCheckPath, healthCheckHandler(func(r *http.Request, request *HealthCheckRequest) error {
+		return backend.DeleteHealthCheck(r.Context(), request.Endpoint)
+	}))
+	return mux
+}
+
+// badRequest is returned for invalid requests, that are answered with a 400 status code.
+type badRequest string
+
+func (e badRequest) Error() string {
+	return string(e)
+}
+
+func recordHandler(operation func(*v1.DNSRecord, v1.DNSZone) error) http.Handler {
+	return handler(func(r *http.Request) (interface{}, error) {
+		request := &RecordRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			return nil, badRequest(fmt.Sprintf("invalid request body: %v", err))
+		}
+		if request.Record == nil {
+			return nil, badRequest("record is required")
+		}
+		return nil, operation(request.Record, request.Zone)
+	})
+}
+
+// healthCheckHandler answers with the provider specific properties of the
+// endpoint, as updated by the operation.
+func healthCheckHandler(operation func(*http.Request, *HealthCheckRequest) error) http.Handler {
+	return handler(func(r *http.Request) (interface{}, error) {
+		request := &HealthCheckRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			return nil, badRequest(fmt.Sprintf("invalid request body: %v", err))
+		}
+		if request.Endpoint == nil {
+			return nil, badRequest("endpoint is required")
+		}
+		if err := operation(r, request); err != nil {
+			return nil, err
+		}
+		return &HealthCheckResponse{ProviderSpecific: request.Endpoint.ProviderSpecific}, nil
+	})
+}
+
+// handler answers with the response of the operation, if any, or with no content.
+func handler(operation func(*http.Request) (interface{}, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+			return
+		}
+		response, err := operation(r)
+		if err == nil && response == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err == nil {
+			w.Header().Set(contentTypeHeader, contentTypeApplicationJSON)
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(response)
+			return
+		}
+		if _, ok := err.(badRequest); ok {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set(contentTypeHeader, contentTypeApplicationJSON)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&ErrorResponse{Message: err.Error()})
+}
@@ -0,0 +1,154 @@
+package plugin_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/plugin"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/plugin/conformance"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/plugin/reference"
+)
+
+const testZone = "test-zone"
+
+func newTestProvider(t *testing.T, handler http.Handler) *plugin.Provider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := plugin.NewProvider(plugin.Config{URL: server.URL + "/"})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	return provider
+}
+
+func TestReferencePluginConformance(t *testing.T) {
+	backend := reference.NewBackend()
+	provider := newTestProvider(t, plugin.NewHandler(backend))
+
+	conformance.Run(t, provider, v1.DNSZone{ID: testZone}, "example.com", func(dnsName string) ([]string, error) {
+		return backend.Targets(testZone, dnsName), nil
+	})
+}
+
+func TestProviderHealthCheckRoundTrip(t *testing.T) {
+	g := gomega.NewWithT(t)
+	backend := reference.NewBackend()
+	provider := newTestProvider(t, plugin.NewHandler(backend))
+
+	port := int64(443)
+	protocol := v1.HealthCheckProtocolHTTPS
+	hc := v1.HealthCheck{Id: "id", Name: "app", Port: &port, Path: "/healthz", Protocol: &protocol}
+	endpoint := &v1.Endpoint{DNSName: "app.example.com", SetIdentifier: "192.0.2.1"}
+
+	g.Expect(provider.ReconcileHealthCheck(context.Background(), hc, endpoint)).To(gomega.Succeed())
+	reconciled, ok := backend.HealthCheck(endpoint)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(reconciled).To(gomega.Equal(hc))
+	id, ok := endpoint.GetProviderSpecific(reference.ProviderSpecificHealthCheckID)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(id).To(gomega.Equal("id"))
+
+	g.Expect(provider.DeleteHealthCheck(context.Background(), endpoint)).To(gomega.Succeed())
+	_, ok = backend.HealthCheck(endpoint)
+	g.Expect(ok).To(gomega.BeFalse())
+	_, ok = endpoint.GetProviderSpecific(reference.ProviderSpecificHealthCheckID)
+	g.Expect(ok).To(gomega.BeFalse())
+}
+
+func TestProviderHealthCheckNoContent(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider := newTestProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	endpoint := &v1.Endpoint{
+		DNSName:          "app.example.com",
+		ProviderSpecific: v1.ProviderSpecific{{Name: "plugin/health-check-id", Value: "id"}},
+	}
+	g.Expect(provider.ReconcileHealthCheck(context.Background(), v1.HealthCheck{Id: "id"}, endpoint)).To(gomega.Succeed())
+	g.Expect(endpoint.ProviderSpecific).To(gomega.Equal(v1.ProviderSpecific{{Name: "plugin/health-check-id", Value: "id"}}))
+}
+
+func TestProviderErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected string
+	}{
+		{
+			name: "plugin error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"message":"zone is read-only"}`))
+			},
+			expected: "zone is read-only",
+		},
+		{
+			name: "unexpected error body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			expected: "502 Bad Gateway",
+		},
+		{
+			name:     "unsupported protocol version",
+			handler:  http.NotFound,
+			expected: "protocol version " + plugin.APIVersion,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := newTestProvider(t, tc.handler)
+			err := provider.Ensure(&v1.DNSRecord{}, v1.DNSZone{ID: testZone})
+			if err == nil {
+				t.Fatalf("expected an error but got none")
+			}
+			if !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("expected error to contain %q but got %q", tc.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestHandlerInvalidRequests(t *testing.T) {
+	server := httptest.NewServer(plugin.NewHandler(reference.NewBackend()))
+	t.Cleanup(server.Close)
+
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{name: "invalid method", method: http.MethodGet, path: plugin.EnsurePath, expected: http.StatusMethodNotAllowed},
+		{name: "invalid body", method: http.MethodPost, path: plugin.EnsurePath, body: "{", expected: http.StatusBadRequest},
+		{name: "missing record", method: http.MethodPost, path: plugin.DeletePath, body: "{}", expected: http.StatusBadRequest},
+		{name: "missing health check", method: http.MethodPost, path: plugin.ReconcileHealthCheckPath, body: `{"endpoint":{}}`, expected: http.StatusBadRequest},
+		{name: "unknown version", method: http.MethodPost, path: "/v0/records/ensure", body: "{}", expected: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.expected {
+				t.Errorf("expected status %d but got %d", tc.expected, resp.StatusCode)
+			}
+		})
+	}
+}
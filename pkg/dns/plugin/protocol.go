@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// APIVersion is the version of the plugin protocol. It prefixes the paths of
+// all the operations, so that a plugin can serve several versions of the
+// protocol side by side.
+//
+// Each operation is a POST request, with a JSON body, that is answered with
+// a 2xx status code on success. The health check operations answer with a
+// HealthCheckResponse body. Failures are answered with a 4xx or 5xx status
+// code, and an ErrorResponse body.
+const APIVersion = "v1alpha1"
+
+const (
+	EnsurePath                 = "/" + APIVersion + "/records/ensure"
+	DeletePath                 = "/" + APIVersion + "/records/delete"
+	ReconcileHealthCheckPath   = "/" + APIVersion + "/healthchecks/reconcile"
+	DeleteHealthCheckPath      = "/" + APIVersion + "/healthchecks/delete"
+	contentTypeHeader          = "Content-Type"
+	contentTypeApplicationJSON = "application/json"
+)
+
+// RecordRequest is the body of the ensure and delete requests.
+type RecordRequest struct {
+	// Record is the DNSRecord to publish to, or delete from, the zone.
+	// Its status holds the endpoints that were last published to the zone.
+	Record *v1.DNSRecord `json:"record"`
+	Zone   v1.DNSZone    `json:"zone"`
+}
+
+// HealthCheckRequest is the body of the health check reconcile and delete requests.
+type HealthCheckRequest struct {
+	// HealthCheck is only set for reconcile requests.
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+	Endpoint    *v1.Endpoint `json:"endpoint"`
+}
+
+// HealthCheckResponse is the body of the health check reconcile and delete
+// responses.
+type HealthCheckResponse struct {
+	// ProviderSpecific are the provider specific properties of the endpoint,
+	// once its health check has been reconciled or deleted, e.g. the ID of the
+	// health check needed to delete it. They replace those of the endpoint.
+	ProviderSpecific v1.ProviderSpecific `json:"providerSpecific"`
+}
+
+// HealthCheck is the wire representation of v1.HealthCheck.
+type HealthCheck struct {
+	ID               string                  `json:"id,omitempty"`
+	Name             string                  `json:"name"`
+	Port             *int64                  `json:"port,omitempty"`
+	FailureThreshold *int64                  `json:"failureThreshold,omitempty"`
+	Path             string                  `json:"path,omitempty"`
+	Protocol         *v1.HealthCheckProtocol `json:"protocol,omitempty"`
+}
+
+// ErrorResponse is the body of failed requests.
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
+
+func healthCheckToWire(hc v1.HealthCheck) *HealthCheck {
+	return &HealthCheck{
+		ID:               hc.Id,
+		Name:             hc.Name,
+		Port:             hc.Port,
+		FailureThreshold: hc.FailureThreshold,
+		Path:             hc.Path,
+		Protocol:         hc.Protocol,
+	}
+}
+
+func healthCheckFromWire(hc *HealthCheck) v1.HealthCheck {
+	return v1.HealthCheck{
+		Id:               hc.ID,
+		Name:             hc.Name,
+		Port:             hc.Port,
+		FailureThreshold: hc.FailureThreshold,
+		Path:             hc.Path,
+		Protocol:         hc.Protocol,
+	}
+}
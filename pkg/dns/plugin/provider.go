@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Provider forwards the DNS provider calls to an out-of-tree plugin, usually
+// running as a sidecar, using the plugin protocol.
+type Provider struct {
+	client *http.Client
+	config Config
+	logger logr.Logger
+}
+
+// Config is the necessary input to configure the manager.
+type Config struct {
+	// URL is the base URL of the plugin, e.g. http://localhost:8081.
+	URL string
+	// Timeout of the requests to the plugin, defaults to 30 seconds.
+	Timeout time.Duration
+	// Client is the HTTP client used to send the requests, defaults to a
+	// client with the configured timeout.
+	Client *http.Client
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("a plugin URL is required")
+	}
+	if _, err := url.ParseRequestURI(config.URL); err != nil {
+		return nil, fmt.Errorf("invalid plugin URL %s: %v", config.URL, err)
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+
+	return &Provider{
+		client: client,
+		config: config,
+		logger: log.Logger.WithName("dns-plugin").WithValues("url", config.URL),
+	}, nil
+}
+
+func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+	if err := p.call(ctx, EnsurePath, &RecordRequest{Record: record, Zone: zone}); err != nil {
+		return fmt.Errorf("couldn't ensure DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+	p.logger.Info("Upserted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+	if err := p.call(ctx, DeletePath, &RecordRequest{Record: record, Zone: zone}); err != nil {
+		return fmt.Errorf("couldn't delete DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+	p.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+// ReconcileHealthCheck reconciles the health check of the endpoint, and updates its provider specific properties, e.g.
+// the ID of the health check, with those returned by the plugin.
+func (p *Provider) ReconcileHealthCheck(ctx context.Context, hc v1.HealthCheck, endpoint *v1.Endpoint) error {
+	return p.callHealthCheck(ctx, ReconcileHealthCheckPath, &HealthCheckRequest{HealthCheck: healthCheckToWire(hc), Endpoint: endpoint})
+}
+
+// DeleteHealthCheck deletes the health check of the endpoint, and updates its provider specific properties with those
+// returned by the plugin.
+func (p *Provider) DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint) error {
+	return p.callHealthCheck(ctx, DeleteHealthCheckPath, &HealthCheckRequest{Endpoint: endpoint})
+}
+
+// callHealthCheck sends the health check request, and applies the provider specific properties of the response to the
+// endpoint. The endpoint is left untouched by the plugins answering with no content.
+func (p *Provider) callHealthCheck(ctx context.Context, path string, request *HealthCheckRequest) error {
+	response := &HealthCheckResponse{}
+	ok, err := p.callWithResponse(ctx, path, request, response)
+	if err != nil {
+		return err
+	}
+	if ok {
+		request.Endpoint.ProviderSpecific = response.ProviderSpecific
+	}
+	return nil
+}
+
+func (p *Provider) call(ctx context.Context, path string, request interface{}) error {
+	_, err := p.callWithResponse(ctx, path, request, nil)
+	return err
+}
+
+// callWithResponse sends the request, and decodes the body of a successful response into the given response, if any.
+// It returns whether a response body has been decoded.
+func (p *Provider) callWithResponse(ctx context.Context, path string, request, response interface{}) (bool, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set(contentTypeHeader, contentTypeApplicationJSON)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return false, err
+		}
+		if response == nil || resp.StatusCode == http.StatusNoContent || len(bytes.TrimSpace(data)) == 0 {
+			return false, nil
+		}
+		if err := json.Unmarshal(data, response); err != nil {
+			return false, fmt.Errorf("invalid plugin response body: %v", err)
+		}
+		return true, nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, fmt.Errorf("plugin does not implement %s, check that it supports protocol version %s", path, APIVersion)
+	}
+	errResp := &ErrorResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(errResp); err != nil || errResp.Message == "" {
+		return false, fmt.Errorf("plugin failed with status %s", resp.Status)
+	}
+	return false, fmt.Errorf("plugin failed with status %s: %s", resp.Status, errResp.Message)
+}
@@ -0,0 +1,158 @@
+package reference
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// ProviderSpecificHealthCheckID is the provider specific property of the endpoints holding the ID of their health check
+const ProviderSpecificHealthCheckID = "reference/health-check-id"
+
+// Backend is the reference implementation of a DNS provider plugin. It keeps
+// the published endpoints in memory, and is meant as a starting point for
+// plugins targeting actual DNS services.
+type Backend struct {
+	mu sync.RWMutex
+	// zones maps zone IDs to the published endpoints, indexed by endpointKey
+	zones        map[string]map[string]*v1.Endpoint
+	healthChecks map[string]v1.HealthCheck
+	logger       logr.Logger
+}
+
+func NewBackend() *Backend {
+	return &Backend{
+		zones:        map[string]map[string]*v1.Endpoint{},
+		healthChecks: map[string]v1.HealthCheck{},
+		logger:       log.Logger.WithName("dns-plugin-reference"),
+	}
+}
+
+// Ensure publishes the record endpoints to the zone, and removes the
+// endpoints that were last published, as recorded in the record zone status,
+// and are no longer expected.
+func (b *Backend) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	if err := validateEndpoints(record.Spec.Endpoints); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	endpoints := b.zone(zone.ID)
+	for _, endpoint := range endpointsFromZoneStatus(record, zone.ID) {
+		delete(endpoints, endpointKey(endpoint))
+	}
+	for _, endpoint := range record.Spec.Endpoints {
+		endpoints[endpointKey(endpoint)] = endpoint.DeepCopy()
+	}
+	b.logger.Info("Upserted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (b *Backend) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	endpoints := b.zone(zone.ID)
+	for _, endpoint := range append(endpointsFromZoneStatus(record, zone.ID), record.Spec.Endpoints...) {
+		delete(endpoints, endpointKey(endpoint))
+	}
+	b.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (b *Backend) ReconcileHealthCheck(_ context.Context, hc v1.HealthCheck, endpoint *v1.Endpoint) error {
+	if hc.Name == "" {
+		return fmt.Errorf("health check name is required")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.healthChecks[endpointKey(endpoint)] = hc
+	// The ID of the health check is recorded on the endpoint, the way actual DNS services identify their health checks
+	id := hc.Id
+	if id == "" {
+		id = endpointKey(endpoint)
+	}
+	endpoint.SetProviderSpecific(ProviderSpecificHealthCheckID, id)
+	return nil
+}
+
+func (b *Backend) DeleteHealthCheck(_ context.Context, endpoint *v1.Endpoint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.healthChecks, endpointKey(endpoint))
+	endpoint.DeleteProviderSpecific(ProviderSpecificHealthCheckID)
+	return nil
+}
+
+// Targets returns the sorted targets of the endpoints published to the zone
+// for the given name.
+func (b *Backend) Targets(zoneID, dnsName string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var targets []string
+	for _, endpoint := range b.zones[zoneID] {
+		if strings.EqualFold(strings.TrimSuffix(endpoint.DNSName, "."), strings.TrimSuffix(dnsName, ".")) {
+			targets = append(targets, endpoint.Targets...)
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// HealthCheck returns the health check reconciled for the endpoint, if any.
+func (b *Backend) HealthCheck(endpoint *v1.Endpoint) (v1.HealthCheck, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	hc, ok := b.healthChecks[endpointKey(endpoint)]
+	return hc, ok
+}
+
+func (b *Backend) zone(zoneID string) map[string]*v1.Endpoint {
+	endpoints, ok := b.zones[zoneID]
+	if !ok {
+		endpoints = map[string]*v1.Endpoint{}
+		b.zones[zoneID] = endpoints
+	}
+	return endpoints
+}
+
+func endpointKey(endpoint *v1.Endpoint) string {
+	return strings.ToLower(strings.TrimSuffix(endpoint.DNSName, ".")) + "/" + endpoint.RecordType + "/" + endpoint.SetIdentifier
+}
+
+func validateEndpoints(endpoints []*v1.Endpoint) error {
+	for _, endpoint := range endpoints {
+		if len(endpoint.DNSName) == 0 {
+			return fmt.Errorf("domain is required")
+		}
+		if len(endpoint.Targets) == 0 {
+			return fmt.Errorf("targets is required")
+		}
+		switch endpoint.RecordType {
+		case string(v1.ARecordType), string(v1.AAAARecordType), string(v1.CNAMERecordType):
+		default:
+			return fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+		}
+	}
+	return nil
+}
+
+func endpointsFromZoneStatus(record *v1.DNSRecord, zoneID string) []*v1.Endpoint {
+	for _, zoneStatus := range record.Status.Zones {
+		if zoneStatus.DNSZone.ID == zoneID {
+			return zoneStatus.Endpoints
+		}
+	}
+	return []*v1.Endpoint{}
+}
@@ -0,0 +1,137 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// changePropagationPollPeriod is the period the pending changes are checked at, until they are propagated
+const changePropagationPollPeriod = 10 * time.Second
+
+const (
+	changePendingReason    = "ChangePending"
+	changePropagatedReason = "ChangePropagated"
+)
+
+// ChangePropagationTracker is implemented by the providers that propagate the changes to their name servers
+// asynchronously, after they have been accepted.
+type ChangePropagationTracker interface {
+	// EnsureChange creates or updates the record, and returns the identifier of the change, or an empty string when
+	// no change was needed.
+	EnsureChange(record *v1.DNSRecord, zone v1.DNSZone) (string, error)
+	// ChangePropagated returns whether the change is served by all the name servers of the provider.
+	ChangePropagated(ctx context.Context, changeID string) (bool, error)
+}
+
+// ensure creates or updates the record in the zone, and returns the identifier of the change when the provider
+// propagates it asynchronously.
+func ensure(provider Provider, record *v1.DNSRecord, zone v1.DNSZone) (string, error) {
+	if tracker, ok := provider.(ChangePropagationTracker); ok {
+		return tracker.EnsureChange(record, zone)
+	}
+	return "", provider.Ensure(record, zone)
+}
+
+// propagatedCondition returns the propagated condition of a record successfully submitted to the provider
+func propagatedCondition(changeID string) v1.DNSZoneCondition {
+	if changeID == "" {
+		return v1.DNSZoneCondition{
+			Type:    v1.DNSRecordPropagatedConditionType,
+			Status:  string(ConditionTrue),
+			Reason:  changePropagatedReason,
+			Message: "The record is served by the DNS provider",
+		}
+	}
+	return v1.DNSZoneCondition{
+		Type:    v1.DNSRecordPropagatedConditionType,
+		Status:  string(ConditionFalse),
+		Reason:  changePendingReason,
+		Message: fmt.Sprintf("The change %s is being propagated to the name servers of the DNS provider", changeID),
+	}
+}
+
+// reconcileChangePropagation checks whether the pending changes of the record have been propagated, and sets the
+// propagated condition of the zones they have been propagated to.
+func (c *Controller) reconcileChangePropagation(ctx context.Context, dnsRecord *v1.DNSRecord) error {
+	for i := range dnsRecord.Status.Zones {
+		status := &dnsRecord.Status.Zones[i]
+		if !hasPendingChange(*status) {
+			continue
+		}
+		provider, _, ok := c.providerForZoneStatus(*status)
+		if !ok {
+			continue
+		}
+		tracker, ok := provider.(ChangePropagationTracker)
+		if !ok {
+			continue
+		}
+		propagated, err := tracker.ChangePropagated(ctx, status.ChangeID)
+		if err != nil {
+			return err
+		}
+		if !propagated {
+			continue
+		}
+		c.Logger.Info("DNS record change propagated", "record", dnsRecord.Name, "zone", status.DNSZone, "change", status.ChangeID)
+		status.Conditions = mergeConditions(status.Conditions, []v1.DNSZoneCondition{{
+			Type:    v1.DNSRecordPropagatedConditionType,
+			Status:  string(ConditionTrue),
+			Reason:  changePropagatedReason,
+			Message: fmt.Sprintf("The change %s is served by the name servers of the DNS provider", status.ChangeID),
+		}})
+	}
+	return nil
+}
+
+// hasPendingChange returns whether the last change of the record in the zone is still being propagated
+func hasPendingChange(status v1.DNSZoneStatus) bool {
+	if status.ChangeID == "" {
+		return false
+	}
+	condition, ok := zoneCondition(status, v1.DNSRecordPropagatedConditionType)
+	return ok && condition.Reason == changePendingReason
+}
+
+// hasPendingChanges returns whether any change of the record is still being propagated
+func hasPendingChanges(dnsRecord *v1.DNSRecord) bool {
+	for _, status := range dnsRecord.Status.Zones {
+		if hasPendingChange(status) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRecordPropagated returns whether the current endpoints of the record are served in all the zones. The zones the
+// record was published to before the propagation was tracked are considered propagated once the record is published,
+// and the records published to no zone, e.g. when no zone is managed for their host, are not waited for.
+func IsRecordPropagated(dnsRecord *v1.DNSRecord) bool {
+	if dnsRecord.Status.ObservedGeneration != dnsRecord.Generation {
+		return false
+	}
+	for _, status := range dnsRecord.Status.Zones {
+		if condition, ok := zoneCondition(status, v1.DNSRecordPropagatedConditionType); ok {
+			if condition.Status != string(ConditionTrue) {
+				return false
+			}
+			continue
+		}
+		if condition, ok := zoneCondition(status, v1.DNSRecordFailedConditionType); !ok || condition.Status != string(ConditionFalse) {
+			return false
+		}
+	}
+	return true
+}
+
+func zoneCondition(status v1.DNSZoneStatus, conditionType string) (v1.DNSZoneCondition, bool) {
+	for _, condition := range status.Conditions {
+		if condition.Type == conditionType {
+			return condition, true
+		}
+	}
+	return v1.DNSZoneCondition{}, false
+}
@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/reconciler"
+)
+
+type mockChangePropagationTracker struct {
+	mockProvider
+	changeID string
+	insync   map[string]bool
+}
+
+func (m *mockChangePropagationTracker) EnsureChange(_ *v1.DNSRecord, _ v1.DNSZone) (string, error) {
+	return m.changeID, nil
+}
+
+func (m *mockChangePropagationTracker) ChangePropagated(_ context.Context, changeID string) (bool, error) {
+	return m.insync[changeID], nil
+}
+
+func newPropagationTestController(provider Provider) *Controller {
+	return &Controller{
+		Controller:       &reconciler.Controller{Logger: logr.Discard()},
+		dnsProviderNames: []string{"aws"},
+		dnsProviders:     map[string]Provider{"aws": provider},
+		dnsZones:         []v1.DNSZone{{ID: "Z1"}},
+		dnsZoneDomains:   map[string]string{"Z1": "example.com"},
+		dnsZoneProviders: map[string]string{"Z1": "aws"},
+	}
+}
+
+func newPropagationTestRecord() *v1.DNSRecord {
+	return &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "record", Generation: 1},
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{{
+				DNSName:    "app.example.com",
+				RecordType: string(v1.ARecordType),
+				Targets:    v1.Targets{"192.0.2.1"},
+			}},
+		},
+	}
+}
+
+func TestChangePropagation(t *testing.T) {
+	tracker := &mockChangePropagationTracker{changeID: "C1", insync: map[string]bool{}}
+	c := newPropagationTestController(tracker)
+	record := newPropagationTestRecord()
+
+	record.Status.Zones = c.publishRecordToZones(c.dnsZones, record)
+	record.Status.ObservedGeneration = record.Generation
+	if len(record.Status.Zones) != 1 || record.Status.Zones[0].ChangeID != "C1" {
+		t.Fatalf("expected the change C1 to be tracked, got %v", record.Status.Zones)
+	}
+	if !hasPendingChanges(record) || IsRecordPropagated(record) {
+		t.Fatalf("expected the change to be pending")
+	}
+
+	// The change is still pending
+	if err := c.reconcileChangePropagation(context.TODO(), record); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !hasPendingChanges(record) {
+		t.Fatalf("expected the change to be pending")
+	}
+
+	tracker.insync["C1"] = true
+	if err := c.reconcileChangePropagation(context.TODO(), record); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if hasPendingChanges(record) || !IsRecordPropagated(record) {
+		t.Fatalf("expected the change to be propagated, got %v", record.Status.Zones[0].Conditions)
+	}
+
+	// A new generation of the record is not propagated until it is published
+	record.Generation = 2
+	if IsRecordPropagated(record) {
+		t.Fatalf("expected the new generation not to be propagated")
+	}
+}
+
+func TestChangePropagationUntracked(t *testing.T) {
+	c := newPropagationTestController(&mockProvider{})
+	record := newPropagationTestRecord()
+
+	record.Status.Zones = c.publishRecordToZones(c.dnsZones, record)
+	record.Status.ObservedGeneration = record.Generation
+	if hasPendingChanges(record) || !IsRecordPropagated(record) {
+		t.Fatalf("expected the record to be propagated, got %v", record.Status.Zones)
+	}
+}
+
+func TestIsRecordPropagated(t *testing.T) {
+	zone := func(conditions ...v1.DNSZoneCondition) v1.DNSZoneStatus {
+		return v1.DNSZoneStatus{DNSZone: v1.DNSZone{ID: "Z1"}, Conditions: conditions}
+	}
+	failed := func(status ConditionStatus) v1.DNSZoneCondition {
+		return v1.DNSZoneCondition{Type: v1.DNSRecordFailedConditionType, Status: string(status)}
+	}
+	propagated := func(status ConditionStatus) v1.DNSZoneCondition {
+		return v1.DNSZoneCondition{Type: v1.DNSRecordPropagatedConditionType, Status: string(status)}
+	}
+
+	cases := []struct {
+		name        string
+		zones       []v1.DNSZoneStatus
+		notObserved bool
+		expected    bool
+	}{
+		{name: "not reconciled", zones: []v1.DNSZoneStatus{zone(failed(ConditionFalse), propagated(ConditionTrue))}, notObserved: true, expected: false},
+		{name: "published to no zone", expected: true},
+		{name: "propagated", zones: []v1.DNSZoneStatus{zone(failed(ConditionFalse), propagated(ConditionTrue))}, expected: true},
+		{name: "pending", zones: []v1.DNSZoneStatus{zone(failed(ConditionFalse), propagated(ConditionFalse))}, expected: false},
+		{name: "pending in one of the zones", zones: []v1.DNSZoneStatus{zone(failed(ConditionFalse), propagated(ConditionTrue)), zone(failed(ConditionFalse), propagated(ConditionFalse))}, expected: false},
+		{name: "published before propagation was tracked", zones: []v1.DNSZoneStatus{zone(failed(ConditionFalse))}, expected: true},
+		{name: "failed", zones: []v1.DNSZoneStatus{zone(failed(ConditionTrue))}, expected: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := &v1.DNSRecord{Status: v1.DNSRecordStatus{Zones: tc.zones}}
+			if tc.notObserved {
+				record.Generation = 1
+			}
+			if propagated := IsRecordPropagated(record); propagated != tc.expected {
+				t.Errorf("expected propagated %t, got %t", tc.expected, propagated)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package rfc2136
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/miekg/dns"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// tsigFudge is the allowed clock skew, in seconds, between GLBC and the name server
+	tsigFudge = 300
+)
+
+// Provider publishes records to an authoritative name server using RFC 2136
+// dynamic updates, optionally authenticated with TSIG (RFC 2845).
+// The DNSZone ID is the name of the zone to update.
+//
+// Dynamic updates have no routing policies: the targets of all the endpoints
+// sharing a name are published in the same record set, and resolvers split the
+// traffic evenly between them. Endpoints with a weight of 0 are not published.
+type Provider struct {
+	client dns.Client
+	config Config
+	logger logr.Logger
+}
+
+// Config is the necessary input to configure the manager.
+type Config struct {
+	// Nameserver is the address of the name server accepting dynamic updates, as host:port.
+	Nameserver string
+	// Net is the transport used to send the updates, either udp (default) or tcp.
+	Net string
+	// TSIGKeyName is the name of the TSIG key. Updates are not signed when empty.
+	TSIGKeyName string
+	// TSIGSecret is the base64 encoded TSIG secret.
+	TSIGSecret string
+	// TSIGAlgorithm is the TSIG algorithm, defaults to hmac-sha256.
+	TSIGAlgorithm string
+	// Timeout of the update requests, defaults to 10 seconds.
+	Timeout time.Duration
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.Nameserver == "" {
+		return nil, fmt.Errorf("a name server is required")
+	}
+	if _, _, err := net.SplitHostPort(config.Nameserver); err != nil {
+		config.Nameserver = net.JoinHostPort(config.Nameserver, "53")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.TSIGAlgorithm == "" {
+		config.TSIGAlgorithm = dns.HmacSHA256
+	}
+	config.TSIGAlgorithm = dns.Fqdn(config.TSIGAlgorithm)
+
+	p := &Provider{
+		client: dns.Client{
+			Net:     config.Net,
+			Timeout: config.Timeout,
+		},
+		config: config,
+		logger: log.Logger.WithName("rfc2136").WithValues("nameserver", config.Nameserver),
+	}
+	if config.TSIGKeyName != "" {
+		if config.TSIGSecret == "" {
+			return nil, fmt.Errorf("a TSIG secret is required for key %s", config.TSIGKeyName)
+		}
+		p.client.TsigSecret = map[string]string{dns.Fqdn(config.TSIGKeyName): config.TSIGSecret}
+	}
+
+	return p, nil
+}
+
+func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	desired, err := resourceRecordsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+	lastPublished, err := resourceRecordsForEndpoints(endpointsFromZoneStatus(record, zone.ID))
+	if err != nil {
+		return err
+	}
+
+	// Remove any previously published records that are no longer present in
+	// record.Spec.Endpoints, and (re-)assert the expected ones.
+	var removals []dns.RR
+	for key, rr := range lastPublished {
+		if _, found := desired[key]; !found {
+			removals = append(removals, rr)
+		}
+	}
+
+	if err := p.update(zone.ID, sortedRecords(desired), removals); err != nil {
+		return fmt.Errorf("couldn't update DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+	p.logger.Info("Upserted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
+	desired, err := resourceRecordsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+	lastPublished, err := resourceRecordsForEndpoints(endpointsFromZoneStatus(record, zone.ID))
+	if err != nil {
+		return err
+	}
+	for key, rr := range lastPublished {
+		desired[key] = rr
+	}
+
+	if err := p.update(zone.ID, nil, sortedRecords(desired)); err != nil {
+		return fmt.Errorf("couldn't delete DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+	p.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+// ReconcileHealthCheck is a no-op, as dynamic updates have no notion of health checks.
+func (p *Provider) ReconcileHealthCheck(_ context.Context, hc v1.HealthCheck, endpoint *v1.Endpoint) error {
+	p.logger.V(3).Info("Health checks are not supported by RFC 2136 name servers, skipping", "name", hc.Name, "endpoint", endpoint.SetID())
+	return nil
+}
+
+func (p *Provider) DeleteHealthCheck(_ context.Context, _ *v1.Endpoint) error {
+	return nil
+}
+
+// update sends a single UPDATE message for the zone, so that the removals and
+// insertions are applied atomically by the name server.
+func (p *Provider) update(zone string, insertions, removals []dns.RR) error {
+	if len(insertions) == 0 && len(removals) == 0 {
+		return nil
+	}
+
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(zone))
+	if len(removals) > 0 {
+		m.Remove(removals)
+	}
+	if len(insertions) > 0 {
+		m.Insert(insertions)
+	}
+	if p.config.TSIGKeyName != "" {
+		m.SetTsig(dns.Fqdn(p.config.TSIGKeyName), p.config.TSIGAlgorithm, tsigFudge, time.Now().Unix())
+	}
+
+	resp, _, err := p.client.Exchange(m, p.config.Nameserver)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("name server refused the update: %s", dns.RcodeToString[resp.Rcode])
+	}
+	p.logger.V(3).Info("Applied dynamic update", "zone", zone, "insertions", len(insertions), "removals", len(removals))
+	return nil
+}
+
+// resourceRecordsForEndpoints returns the resource records for the endpoints,
+// indexed by their presentation format without TTL, which identifies a record
+// within an update.
+func resourceRecordsForEndpoints(endpoints []*v1.Endpoint) (map[string]dns.RR, error) {
+	records := map[string]dns.RR{}
+	// names counts the records for each name, as a CNAME record cannot coexist with other records
+	names := map[string]int{}
+	cnames := sets.NewString()
+	for _, endpoint := range endpoints {
+		if len(endpoint.DNSName) == 0 {
+			return nil, fmt.Errorf("domain is required")
+		}
+		if len(endpoint.Targets) == 0 {
+			return nil, fmt.Errorf("targets is required")
+		}
+		if prop, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight); ok {
+			weight, err := strconv.ParseInt(prop.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight %q for endpoint %s", prop.Value, endpoint.SetID())
+			}
+			if weight == 0 {
+				continue
+			}
+		}
+
+		header := dns.RR_Header{
+			Name:  dns.Fqdn(endpoint.DNSName),
+			Class: dns.ClassINET,
+			Ttl:   uint32(endpoint.RecordTTL),
+		}
+		for _, target := range endpoint.Targets {
+			var rr dns.RR
+			switch endpoint.RecordType {
+			case string(v1.ARecordType):
+				ip := net.ParseIP(target).To4()
+				if ip == nil {
+					return nil, fmt.Errorf("invalid IPv4 address %s for endpoint %s", target, endpoint.SetID())
+				}
+				header.Rrtype = dns.TypeA
+				rr = &dns.A{Hdr: header, A: ip}
+			case string(v1.AAAARecordType):
+				ip := net.ParseIP(target)
+				if ip == nil || ip.To4() != nil {
+					return nil, fmt.Errorf("invalid IPv6 address %s for endpoint %s", target, endpoint.SetID())
+				}
+				header.Rrtype = dns.TypeAAAA
+				rr = &dns.AAAA{Hdr: header, AAAA: ip}
+			case string(v1.CNAMERecordType):
+				header.Rrtype = dns.TypeCNAME
+				rr = &dns.CNAME{Hdr: header, Target: dns.Fqdn(target)}
+			default:
+				return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+			}
+			key := recordKey(rr)
+			if _, found := records[key]; found {
+				continue
+			}
+			records[key] = rr
+			names[header.Name]++
+			if header.Rrtype == dns.TypeCNAME {
+				cnames.Insert(header.Name)
+			}
+		}
+	}
+	for _, name := range cnames.List() {
+		if names[name] > 1 {
+			return nil, fmt.Errorf("a CNAME record cannot coexist with other records for %s", name)
+		}
+	}
+	return records, nil
+}
+
+func recordKey(rr dns.RR) string {
+	withoutTTL := dns.Copy(rr)
+	withoutTTL.Header().Ttl = 0
+	return withoutTTL.String()
+}
+
+func sortedRecords(records map[string]dns.RR) []dns.RR {
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]dns.RR, 0, len(records))
+	for _, key := range keys {
+		sorted = append(sorted, records[key])
+	}
+	return sorted
+}
+
+func endpointsFromZoneStatus(record *v1.DNSRecord, zoneID string) []*v1.Endpoint {
+	for _, zoneStatus := range record.Status.Zones {
+		if zoneStatus.DNSZone.ID == zoneID {
+			return zoneStatus.Endpoints
+		}
+	}
+	return []*v1.Endpoint{}
+}
@@ -0,0 +1,294 @@
+package rfc2136
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/onsi/gomega"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+const (
+	testZone       = "example.com"
+	testKeyName    = "glbc."
+	testTSIGSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0"
+)
+
+// fakeNameServer is a minimal in-memory authoritative name server, that
+// applies the RFC 2136 updates it receives.
+type fakeNameServer struct {
+	mu      sync.Mutex
+	records map[string]dns.RR
+	updates int
+}
+
+func (f *fakeNameServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	switch {
+	case r.Opcode != dns.OpcodeUpdate || len(r.Question) != 1 || r.Question[0].Name != dns.Fqdn(testZone):
+		m.Rcode = dns.RcodeRefused
+	case r.IsTsig() == nil || w.TsigStatus() != nil:
+		m.Rcode = dns.RcodeNotAuth
+	default:
+		f.updates++
+		for _, rr := range r.Ns {
+			header := rr.Header()
+			switch header.Class {
+			case dns.ClassNONE:
+				inserted := dns.Copy(rr)
+				inserted.Header().Class = dns.ClassINET
+				delete(f.records, recordKey(inserted))
+			case dns.ClassANY:
+				for key, existing := range f.records {
+					if existing.Header().Name == header.Name && (header.Rrtype == dns.TypeANY || existing.Header().Rrtype == header.Rrtype) {
+						delete(f.records, key)
+					}
+				}
+			default:
+				f.records[recordKey(rr)] = rr
+			}
+		}
+	}
+	if r.IsTsig() != nil {
+		m.SetTsig(testKeyName, dns.HmacSHA256, tsigFudge, time.Now().Unix())
+	}
+	_ = w.WriteMsg(m)
+}
+
+func (f *fakeNameServer) published() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var records []string
+	for _, rr := range f.records {
+		records = append(records, rr.String())
+	}
+	sort.Strings(records)
+	return records
+}
+
+func newTestProvider(t *testing.T, keyName, secret string) (*Provider, *fakeNameServer) {
+	fake := &fakeNameServer{records: map[string]dns.RR{}}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error listening: %v", err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        conn,
+		Handler:           fake,
+		TsigSecret:        map[string]string{testKeyName: testTSIGSecret},
+		NotifyStartedFunc: func() { close(started) },
+		// The default accept func only accepts queries and notifies
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+
+	provider, err := NewProvider(Config{
+		Nameserver:  conn.LocalAddr().String(),
+		TSIGKeyName: keyName,
+		TSIGSecret:  secret,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	return provider, fake
+}
+
+func endpoint(name, ip, weight string) *v1.Endpoint {
+	return &v1.Endpoint{
+		DNSName:       name,
+		RecordType:    string(v1.ARecordType),
+		SetIdentifier: ip,
+		Targets:       v1.Targets{ip},
+		RecordTTL:     60,
+		ProviderSpecific: v1.ProviderSpecific{
+			{Name: aws.ProviderSpecificWeight, Value: weight},
+		},
+	}
+}
+
+func TestProviderEnsure(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider, fake := newTestProvider(t, "glbc", testTSIGSecret)
+	zone := v1.DNSZone{ID: testZone}
+
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				endpoint("app.example.com", "192.168.0.2", "60"),
+				endpoint("app.example.com", "192.168.0.1", "120"),
+				endpoint("app.example.com", "192.168.0.3", "0"),
+			},
+		},
+	}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.published()).To(gomega.Equal([]string{
+		"app.example.com.\t60\tIN\tA\t192.168.0.1",
+		"app.example.com.\t60\tIN\tA\t192.168.0.2",
+	}))
+
+	// Endpoints that are no longer expected are removed, and the others are kept
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+	record.Spec.Endpoints = []*v1.Endpoint{
+		endpoint("app.example.com", "192.168.0.1", "120"),
+		{
+			DNSName:    "www.example.com",
+			RecordType: string(v1.CNAMERecordType),
+			Targets:    v1.Targets{"app.example.com"},
+			RecordTTL:  300,
+		},
+	}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.published()).To(gomega.Equal([]string{
+		"app.example.com.\t60\tIN\tA\t192.168.0.1",
+		"www.example.com.\t300\tIN\tCNAME\tapp.example.com.",
+	}))
+	g.Expect(fake.updates).To(gomega.Equal(2))
+}
+
+func TestProviderEnsureUnauthorized(t *testing.T) {
+	g := gomega.NewWithT(t)
+	zone := v1.DNSZone{ID: testZone}
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				endpoint("app.example.com", "192.168.0.1", "120"),
+			},
+		},
+	}
+
+	// Unsigned updates are refused
+	provider, fake := newTestProvider(t, "", "")
+	g.Expect(provider.Ensure(record, zone)).NotTo(gomega.Succeed())
+	g.Expect(fake.published()).To(gomega.BeEmpty())
+
+	// Updates to other zones are refused
+	provider, fake = newTestProvider(t, "glbc", testTSIGSecret)
+	g.Expect(provider.Ensure(record, v1.DNSZone{ID: "example.org"})).NotTo(gomega.Succeed())
+	g.Expect(fake.published()).To(gomega.BeEmpty())
+}
+
+func TestProviderDelete(t *testing.T) {
+	g := gomega.NewWithT(t)
+	provider, fake := newTestProvider(t, "glbc", testTSIGSecret)
+	zone := v1.DNSZone{ID: testZone}
+
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				endpoint("app.example.com", "192.168.0.1", "120"),
+				endpoint("app.example.com", "192.168.0.2", "120"),
+			},
+		},
+	}
+	g.Expect(provider.Ensure(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.published()).To(gomega.HaveLen(2))
+
+	// Records published by others for the same name are left untouched
+	other, err := dns.NewRR("app.example.com. 60 IN A 10.0.0.1")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	fake.mu.Lock()
+	fake.records[recordKey(other)] = other
+	fake.mu.Unlock()
+
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+	g.Expect(provider.Delete(record, zone)).To(gomega.Succeed())
+	g.Expect(fake.published()).To(gomega.Equal([]string{"app.example.com.\t60\tIN\tA\t10.0.0.1"}))
+}
+
+func TestResourceRecordsForEndpoints(t *testing.T) {
+	cases := []struct {
+		name     string
+		endpoint *v1.Endpoint
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "A record",
+			endpoint: endpoint("app.example.com", "192.168.0.1", "120"),
+			expected: []string{"app.example.com.\t60\tIN\tA\t192.168.0.1"},
+		},
+		{
+			name: "AAAA record",
+			endpoint: &v1.Endpoint{
+				DNSName:    "app.example.com",
+				RecordType: string(v1.AAAARecordType),
+				Targets:    v1.Targets{"2001:db8::1"},
+				RecordTTL:  60,
+			},
+			expected: []string{"app.example.com.\t60\tIN\tAAAA\t2001:db8::1"},
+		},
+		{
+			name: "IPv4 address in AAAA record",
+			endpoint: &v1.Endpoint{
+				DNSName:    "app.example.com",
+				RecordType: string(v1.AAAARecordType),
+				Targets:    v1.Targets{"192.168.0.1"},
+			},
+			err: true,
+		},
+		{
+			name:     "zero weight",
+			endpoint: endpoint("app.example.com", "192.168.0.1", "0"),
+		},
+		{
+			name:     "invalid weight",
+			endpoint: endpoint("app.example.com", "192.168.0.1", "heavy"),
+			err:      true,
+		},
+		{
+			name:     "invalid IP address",
+			endpoint: endpoint("app.example.com", "lb.example.com", "120"),
+			err:      true,
+		},
+		{
+			name:     "unsupported record type",
+			endpoint: &v1.Endpoint{DNSName: "app.example.com", RecordType: "TXT", Targets: v1.Targets{"text"}},
+			err:      true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := resourceRecordsForEndpoints([]*v1.Endpoint{tc.endpoint})
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, rr := range sortedRecords(records) {
+				got = append(got, rr.String())
+			}
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %v but got %v", tc.expected, got)
+			}
+			for i := range got {
+				if got[i] != tc.expected[i] {
+					t.Errorf("expected %s but got %s", tc.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package dns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// ZoneResolver is implemented by the providers that look up the domain name of their zones, and that discover their
+// zones by tags
+type ZoneResolver interface {
+	// ZoneDomains returns the domain name of the zones matching the zone, i.e. the zone with its ID, or the zones with
+	// all its tags, indexed by zone ID.
+	ZoneDomains(zone v1.DNSZone) (map[string]string, error)
+}
+
+// parseZoneIDs returns the zone IDs of the comma separated list.
+func parseZoneIDs(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// parseZoneTags returns the tags of the comma separated list of key=value pairs.
+func parseZoneTags(value string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid zone tag %q, expected key=value", pair)
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return tags, nil
+}
+
+// resolveDNSZones returns the zones with the given IDs, and the zones discovered with the given tags, along with their
+// domain name indexed by zone ID. The domain name of a zone is its ID when the provider does not resolve zones, as the
+// zones of these providers are identified by their domain name.
+func resolveDNSZones(provider Provider, ids []string, tags map[string]string) ([]v1.DNSZone, map[string]string, error) {
+	resolver, canResolve := provider.(ZoneResolver)
+	if len(tags) > 0 && !canResolve {
+		return nil, nil, fmt.Errorf("the DNS provider does not support zone discovery by tags")
+	}
+
+	var zones []v1.DNSZone
+	domains := map[string]string{}
+	for _, id := range ids {
+		domain := id
+		if canResolve {
+			resolved, err := resolver.ZoneDomains(v1.DNSZone{ID: id})
+			if err != nil {
+				return nil, nil, err
+			}
+			if d, ok := resolved[id]; ok {
+				domain = d
+			}
+		}
+		if _, ok := domains[id]; ok {
+			continue
+		}
+		zones = append(zones, v1.DNSZone{ID: id})
+		domains[id] = normalizeDomain(domain)
+	}
+
+	if len(tags) > 0 {
+		resolved, err := resolver.ZoneDomains(v1.DNSZone{Tags: tags})
+		if err != nil {
+			return nil, nil, err
+		}
+		tagged := make([]string, 0, len(resolved))
+		for id := range resolved {
+			tagged = append(tagged, id)
+		}
+		sort.Strings(tagged)
+		for _, id := range tagged {
+			if _, ok := domains[id]; ok {
+				continue
+			}
+			zones = append(zones, v1.DNSZone{ID: id})
+			domains[id] = normalizeDomain(resolved[id])
+		}
+	}
+	return zones, domains, nil
+}
+
+// zoneIDForName returns the ID of the zone whose domain name is the longest suffix of the given name. A single zone
+// matches all the names, as was the case before zones were matched by domain name.
+func zoneIDForName(zones []v1.DNSZone, domains map[string]string, name string) (string, bool) {
+	name = normalizeDomain(name)
+	id, longest := "", -1
+	for _, zone := range zones {
+		domain := domains[zone.ID]
+		if (name == domain || strings.HasSuffix(name, "."+domain)) && len(domain) > longest {
+			id, longest = zone.ID, len(domain)
+		}
+	}
+	if longest < 0 && len(zones) == 1 {
+		return zones[0].ID, true
+	}
+	return id, longest >= 0
+}
+
+// recordForZone returns a copy of the record with the endpoints that belong to the zone only, and the endpoints that
+// do not belong to any of the zones.
+func recordForZone(zones []v1.DNSZone, domains map[string]string, record *v1.DNSRecord, zone v1.DNSZone) (*v1.DNSRecord, []*v1.Endpoint) {
+	zoneRecord := record.DeepCopy()
+	zoneRecord.Spec.Endpoints = nil
+	var unmatched []*v1.Endpoint
+	for _, endpoint := range record.Spec.Endpoints {
+		id, ok := zoneIDForName(zones, domains, endpoint.DNSName)
+		if !ok {
+			unmatched = append(unmatched, endpoint)
+			continue
+		}
+		if id == zone.ID {
+			zoneRecord.Spec.Endpoints = append(zoneRecord.Spec.Endpoints, endpoint.DeepCopy())
+		}
+	}
+	return zoneRecord, unmatched
+}
+
+func normalizeDomain(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
@@ -0,0 +1,135 @@
+package dns
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+type mockProvider struct{}
+
+func (*mockProvider) Ensure(_ *v1.DNSRecord, _ v1.DNSZone) error { return nil }
+
+func (*mockProvider) Delete(_ *v1.DNSRecord, _ v1.DNSZone) error { return nil }
+
+func (*mockProvider) ReconcileHealthCheck(_ context.Context, _ v1.HealthCheck, _ *v1.Endpoint) error {
+	return nil
+}
+
+func (*mockProvider) DeleteHealthCheck(_ context.Context, _ *v1.Endpoint) error { return nil }
+
+type mockZoneResolver struct {
+	mockProvider
+	domains map[string]string
+	tags    map[string]map[string]string
+}
+
+func (m *mockZoneResolver) ZoneDomains(zone v1.DNSZone) (map[string]string, error) {
+	domains := map[string]string{}
+	if zone.ID != "" {
+		domains[zone.ID] = m.domains[zone.ID]
+		return domains, nil
+	}
+	for id, tags := range m.tags {
+		if reflect.DeepEqual(tags, zone.Tags) {
+			domains[id] = m.domains[id]
+		}
+	}
+	return domains, nil
+}
+
+func TestParseZoneTags(t *testing.T) {
+	tags, err := parseZoneTags("kuadrant.dev/managed=true, env=prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(tags, map[string]string{"kuadrant.dev/managed": "true", "env": "prod"}) {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	if _, err := parseZoneTags("managed"); err == nil {
+		t.Errorf("expected error for tag without value")
+	}
+}
+
+func TestResolveDNSZones(t *testing.T) {
+	resolver := &mockZoneResolver{
+		domains: map[string]string{
+			"Z1": "hcpapps.net.",
+			"Z2": "dev.hcpapps.net.",
+			"Z3": "example.com.",
+		},
+		tags: map[string]map[string]string{
+			"Z2": {"managed": "true"},
+			"Z3": {"managed": "true"},
+		},
+	}
+
+	zones, domains, err := resolveDNSZones(resolver, []string{"Z1", "Z2"}, map[string]string{"managed": "true"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedZones := []v1.DNSZone{{ID: "Z1"}, {ID: "Z2"}, {ID: "Z3"}}
+	if !reflect.DeepEqual(zones, expectedZones) {
+		t.Errorf("expected zones %v but got %v", expectedZones, zones)
+	}
+	expectedDomains := map[string]string{"Z1": "hcpapps.net", "Z2": "dev.hcpapps.net", "Z3": "example.com"}
+	if !reflect.DeepEqual(domains, expectedDomains) {
+		t.Errorf("expected domains %v but got %v", expectedDomains, domains)
+	}
+
+	// The zone IDs are the domain names of the providers that do not resolve zones
+	zones, domains, err = resolveDNSZones(&mockProvider{}, []string{"dev.hcpapps.net."}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(zones) != 1 || domains[zones[0].ID] != "dev.hcpapps.net" {
+		t.Errorf("unexpected zones %v with domains %v", zones, domains)
+	}
+	if _, _, err := resolveDNSZones(&mockProvider{}, nil, map[string]string{"managed": "true"}); err == nil {
+		t.Errorf("expected error for zone discovery with a provider that does not resolve zones")
+	}
+}
+
+func TestRecordForZone(t *testing.T) {
+	zones := []v1.DNSZone{{ID: "Z1"}, {ID: "Z2"}}
+	domains := map[string]string{"Z1": "hcpapps.net", "Z2": "dev.hcpapps.net"}
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				{DNSName: "xyz.dev.hcpapps.net", Targets: v1.Targets{"192.168.0.1"}},
+				{DNSName: "xyz.prod.hcpapps.net", Targets: v1.Targets{"192.168.0.2"}},
+				{DNSName: "xyz.devhcpapps.net", Targets: v1.Targets{"192.168.0.3"}},
+			},
+		},
+	}
+
+	cases := []struct {
+		zone      v1.DNSZone
+		expected  []string
+		unmatched int
+	}{
+		{zone: zones[0], expected: []string{"xyz.prod.hcpapps.net"}, unmatched: 1},
+		{zone: zones[1], expected: []string{"xyz.dev.hcpapps.net"}, unmatched: 1},
+	}
+	for _, tc := range cases {
+		zoneRecord, unmatched := recordForZone(zones, domains, record, tc.zone)
+		var names []string
+		for _, endpoint := range zoneRecord.Spec.Endpoints {
+			names = append(names, endpoint.DNSName)
+		}
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Errorf("expected endpoints %v in zone %s but got %v", tc.expected, tc.zone.ID, names)
+		}
+		if len(unmatched) != tc.unmatched {
+			t.Errorf("expected %d unmatched endpoints but got %d", tc.unmatched, len(unmatched))
+		}
+	}
+
+	// A single zone gets all the endpoints
+	zoneRecord, unmatched := recordForZone(zones[1:], domains, record, zones[1])
+	if len(zoneRecord.Spec.Endpoints) != 3 || len(unmatched) != 0 {
+		t.Errorf("expected all the endpoints in the single zone but got %d, with %d unmatched", len(zoneRecord.Spec.Endpoints), len(unmatched))
+	}
+}
@@ -15,8 +15,10 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/kcp-dev/logicalcluster/v2"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
 	"github.com/kuadrant/kcp-glbc/pkg/migration/workload"
 	"github.com/kuadrant/kcp-glbc/pkg/reconciler"
+	"github.com/kuadrant/kcp-glbc/pkg/traffic"
 )
 
 const defaultControllerName = "kcp-glbc-deployment"
@@ -31,7 +33,9 @@ func NewController(config *ControllerConfig) (*Controller, error) {
 		sharedInformerFactory: config.SharedInformerFactory,
 	}
 	c.Process = c.process
-	c.migrationHandler = workload.Migrate
+	c.migrationHandler = func(obj metav1.Object, queue workqueue.RateLimitingInterface, logger logr.Logger) {
+		workload.MigrateWithRollout(obj, config.DNSRecordLister, config.DNSTTLPolicy, queue, logger)
+	}
 
 	c.sharedInformerFactory.Apps().V1().Deployments().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    func(obj interface{}) { c.Enqueue(obj) },
@@ -50,6 +54,11 @@ type ControllerConfig struct {
 	*reconciler.ControllerConfig
 	DeploymentClient      kubernetes.ClusterInterface
 	SharedInformerFactory informers.SharedInformerFactory
+	// DNSTTLPolicy is the policy of the TTL of the DNS records, which the deletion of the migrated objects is delayed by
+	DNSTTLPolicy *traffic.TTLPolicy
+	// DNSRecordLister lists the DNS records of the traffic objects, whose TTL the deletion of the migrated objects
+	// of their namespace is delayed by
+	DNSRecordLister kuadrantv1lister.DNSRecordLister
 }
 
 type Controller struct {
@@ -16,9 +16,11 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/kcp-dev/logicalcluster/v2"
 
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
 	"github.com/kuadrant/kcp-glbc/pkg/migration/workload"
 	"github.com/kuadrant/kcp-glbc/pkg/reconciler"
 	basereconciler "github.com/kuadrant/kcp-glbc/pkg/reconciler"
+	"github.com/kuadrant/kcp-glbc/pkg/traffic"
 )
 
 const defaultControllerName = "kcp-glbc-secret"
@@ -33,7 +35,9 @@ func NewController(config *ControllerConfig) (*Controller, error) {
 		sharedInformerFactory: config.SharedInformerFactory,
 	}
 	c.Process = c.process
-	c.migrationHandler = workload.Migrate
+	c.migrationHandler = func(obj metav1.Object, queue workqueue.RateLimitingInterface, logger logr.Logger) {
+		workload.MigrateWithRollout(obj, config.DNSRecordLister, config.DNSTTLPolicy, queue, logger)
+	}
 
 	c.sharedInformerFactory.Core().V1().Secrets().Informer().AddEventHandler(cache.FilteringResourceEventHandler{
 		FilterFunc: func(obj interface{}) bool {
@@ -62,6 +66,11 @@ type ControllerConfig struct {
 	*reconciler.ControllerConfig
 	SecretsClient         kubernetes.ClusterInterface
 	SharedInformerFactory informers.SharedInformerFactory
+	// DNSTTLPolicy is the policy of the TTL of the DNS records, which the deletion of the migrated objects is delayed by
+	DNSTTLPolicy *traffic.TTLPolicy
+	// DNSRecordLister lists the DNS records of the traffic objects, whose TTL the deletion of the migrated objects
+	// of their namespace is delayed by
+	DNSRecordLister kuadrantv1lister.DNSRecordLister
 }
 
 type Controller struct {
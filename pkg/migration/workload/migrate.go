@@ -9,6 +9,8 @@ import (
 	"github.com/go-logr/logr"
 	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
 	"github.com/kuadrant/kcp-glbc/pkg/_internal/metadata"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/traffic"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
@@ -22,16 +24,29 @@ const (
 	WorkloadDeletingAnnotation   = workload.InternalClusterDeletionTimestampAnnotationPrefix
 	SoftFinalizer                = "kuadrant.dev/glbc-migration"
 	DeleteAtAnnotation           = "kuadrant.dev/glbc-delete-at"
-	TTL                          = 60
 )
 
-// Process this is a temporary solution for advanced scheduling. It will add soft finalizer annotations to a set of objects to delay their deletion.
-// These are only paid attention to if advanced scheduling is on for a synctarget
-func Migrate(obj metav1.Object, queue workqueue.RateLimitingInterface, logger logr.Logger) {
+// MigrateWithRollout migrates a workload, e.g. a Deployment, whose deletion from the sync targets is delayed by the TTL
+// of the DNS records of the traffic objects of its namespace, and held while their traffic is progressively shifted
+// away from the sync targets being deleted, as the workloads are not linked to the traffic objects exposing them.
+func MigrateWithRollout(obj metav1.Object, dnsRecordLister kuadrantv1lister.DNSRecordLister, ttlPolicy *traffic.TTLPolicy, queue workqueue.RateLimitingInterface, logger logr.Logger) {
+	dnsRecords, err := traffic.WorkloadDNSRecords(obj, dnsRecordLister)
+	if err != nil {
+		logger.Error(err, "cannot list the DNS records of the namespace, delaying the deletion by the maximum TTL")
+	}
+	MigrateWithHold(obj, queue, logger, ttlPolicy.WorkloadDuration(obj, dnsRecords), traffic.WorkloadRolloutHoldUntil(obj, dnsRecords, time.Now()))
+}
+
+// MigrateWithHold this is a temporary solution for advanced scheduling. It will add soft finalizer annotations to a set of objects to delay their deletion.
+// These are only paid attention to if advanced scheduling is on for a synctarget. The deletion of the objects from the
+// sync targets is delayed by twice the TTL of their DNS records, so that the clients have stopped resolving their
+// addresses, from the time it is held until, e.g. while the traffic is progressively shifted away from them, that can
+// be extended while the deletion is pending.
+func MigrateWithHold(obj metav1.Object, queue workqueue.RateLimitingInterface, logger logr.Logger, ttl time.Duration, holdUntil time.Time) {
 
 	ensureSoftFinalizers(obj, logger)
 
-	gracefulRemoveSoftFinalizers(obj, queue, logger)
+	gracefulRemoveSoftFinalizers(obj, queue, logger, ttl, holdUntil)
 }
 
 // ensureSoftFinalizers ensure all active workload clusters have a soft finalizer set
@@ -55,9 +70,12 @@ func ensureSoftFinalizers(obj metav1.Object, logger logr.Logger) {
 }
 
 // gracefulRemoveSoftFinalizers any soft finalizers with no active workload cluster should trigger a delayed delete
-func gracefulRemoveSoftFinalizers(obj metav1.Object, queue workqueue.RateLimitingInterface, logger logr.Logger) {
+func gracefulRemoveSoftFinalizers(obj metav1.Object, queue workqueue.RateLimitingInterface, logger logr.Logger, ttl time.Duration, holdUntil time.Time) {
 	at := time.Now()
-	at = at.Add((TTL * time.Second) * 2)
+	if holdUntil.After(at) {
+		at = holdUntil
+	}
+	at = at.Add(ttl * 2)
 	_, annotations := metadata.HasAnnotationsContaining(obj, WorkloadClusterSoftFinalizer)
 	for annotation := range annotations {
 		finalizerParts := strings.Split(annotation, "/")
@@ -79,13 +97,18 @@ func gracefulRemoveSoftFinalizers(obj metav1.Object, queue workqueue.RateLimitin
 			if err != nil {
 				return
 			}
-			queue.AddAfter(key, TTL*2*time.Second)
+			queue.AddAfter(key, time.Until(at))
 		} else {
 			deleteAt, err := strconv.Atoi(obj.GetAnnotations()[clusterDeleteAtAnnotation])
 			if err != nil {
 				//badly formed deleteAt annotation, remove it, so it will be regenerated
 				metadata.RemoveAnnotation(obj, clusterDeleteAtAnnotation)
 			}
+			//postpone the delete while it is held
+			if heldUntil := holdUntil.Add(ttl * 2); !holdUntil.IsZero() && heldUntil.Unix() > int64(deleteAt) {
+				deleteAt = int(heldUntil.Unix())
+				metadata.AddAnnotation(obj, clusterDeleteAtAnnotation, strconv.Itoa(deleteAt))
+			}
 
 			if int64(deleteAt) <= time.Now().Unix() {
 				metadata.RemoveAnnotation(obj, WorkloadClusterSoftFinalizer+"/"+clusterName)
@@ -22,6 +22,8 @@ import (
 	"github.com/kuadrant/kcp-glbc/pkg/dns"
 	"github.com/kuadrant/kcp-glbc/pkg/traffic"
 
+	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
+	workloadlisters "github.com/kcp-dev/kcp/pkg/client/listers/workload/v1alpha1"
 	"github.com/kcp-dev/logicalcluster/v2"
 
 	certman "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
@@ -49,7 +51,8 @@ func NewController(config *ControllerConfig) *Controller {
 		impl.Client = config.KubeClient
 	}
 
-	hostResolver = dns.NewSafeHostResolver(hostResolver)
+	// The last known good addresses of the hosts are kept, to be used when their lookup fails transiently
+	cachedHostResolver := dns.NewCachedHostResolver(dns.NewSafeHostResolver(hostResolver), dns.DefaultHostCacheMaxAge, controllerName)
 
 	base := basereconciler.NewController(controllerName, queue)
 	c := &Controller{
@@ -61,8 +64,13 @@ func NewController(config *ControllerConfig) *Controller {
 		glbcInformerFactory:     config.GlbcInformerFactory,
 		kuadrantClient:          config.DnsRecordClient,
 		domain:                  config.Domain,
-		hostResolver:            hostResolver,
-		hostsWatcher:            dns.NewHostsWatcher(&base.Logger, hostResolver, dns.DefaultInterval),
+		hostResolver:            cachedHostResolver,
+		hostsWatcher:            dns.NewHostsWatcher(&base.Logger, cachedHostResolver, dns.DefaultInterval),
+		syncTargetLister:        config.SyncTargetLister,
+		dnsRoutingPolicy:        config.DNSRoutingPolicy,
+		dnsTTLPolicy:            config.DNSTTLPolicy,
+		dnsEndpointSafeguard:    config.DNSEndpointSafeguard,
+		dnsRoutingCapabilities:  config.DNSRoutingCapabilities,
 		certInformerFactory:     config.CertificateInformer,
 		KuadrantInformerFactory: config.KuadrantInformer,
 	}
@@ -225,6 +233,12 @@ func NewController(config *ControllerConfig) *Controller {
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
+			// when a dns record is propagated we requeue the ingress, as its load balancer status waits for the propagation
+			if ingressKey, changed := traffic.DNSRecordPropagationChanged(oldObj, newObj); changed {
+				c.Logger.V(3).Info("reqeuing ingress dns record propagation changed", "ingresskey", ingressKey)
+				c.enqueueIngressByKey(ingressKey)
+				return
+			}
 			newdns := newObj.(*kuadrantv1.DNSRecord)
 			olddns := oldObj.(*kuadrantv1.DNSRecord)
 			if olddns.ResourceVersion != newdns.ResourceVersion {
@@ -235,6 +249,18 @@ func NewController(config *ControllerConfig) *Controller {
 		},
 	})
 
+	// Watch the SyncTargets drained from DNS, or whose readiness changes, when they are watched
+	if config.SyncTargetInformer != nil {
+		config.SyncTargetInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				if key, changed := traffic.SyncTargetChanged(oldObj, newObj); changed {
+					c.Logger.V(3).Info("requeueing ingresses sync target changed", "syncTarget", key)
+					c.enqueueIngresses(c.ingressesFromSyncTarget)(newObj)
+				}
+			},
+		})
+	}
+
 	return c
 }
 
@@ -251,6 +277,18 @@ type ControllerConfig struct {
 	CertProvider             tls.Provider
 	HostResolver             dns.HostResolver
 	GLBCWorkspace            logicalcluster.Name
+	// SyncTargetLister lists the SyncTargets the ingresses are scheduled to, it is nil when they are not watched
+	SyncTargetLister workloadlisters.SyncTargetLister
+	// SyncTargetInformer notifies the changes of the SyncTargets, it is nil when they are not watched
+	SyncTargetInformer cache.SharedIndexInformer
+	// DNSRoutingPolicy is the default routing policy of the DNS records of the ingresses
+	DNSRoutingPolicy string
+	// DNSTTLPolicy is the policy of the TTL of the DNS records of the ingresses
+	DNSTTLPolicy *traffic.TTLPolicy
+	// DNSEndpointSafeguard refuses the updates removing too many targets of the DNS records of the ingresses
+	DNSEndpointSafeguard *traffic.EndpointSafeguard
+	// DNSRoutingCapabilities are the routing features of the DNS records supported by the DNS providers
+	DNSRoutingCapabilities dns.RoutingCapabilities
 }
 
 type Controller struct {
@@ -264,8 +302,13 @@ type Controller struct {
 	certificateLister       certmanlister.CertificateLister
 	certProvider            tls.Provider
 	domain                  string
-	hostResolver            dns.HostResolver
+	hostResolver            *dns.CachedHostResolver
 	hostsWatcher            *dns.HostsWatcher
+	syncTargetLister        workloadlisters.SyncTargetLister
+	dnsRoutingPolicy        string
+	dnsTTLPolicy            *traffic.TTLPolicy
+	dnsEndpointSafeguard    *traffic.EndpointSafeguard
+	dnsRoutingCapabilities  dns.RoutingCapabilities
 	certInformerFactory     certmaninformer.SharedInformerFactory
 	glbcInformerFactory     informers.SharedInformerFactory
 	KuadrantInformerFactory kuadrantInformer.SharedInformerFactory
@@ -391,6 +434,16 @@ func (c *Controller) ingressesFromDomainVerification(obj interface{}) ([]*networ
 	return ingressesToEnqueue, nil
 }
 
+// ingressesFromSyncTarget returns the ingresses scheduled to the SyncTarget
+func (c *Controller) ingressesFromSyncTarget(obj interface{}) ([]*networkingv1.Ingress, error) {
+	syncTarget := obj.(*workload.SyncTarget)
+	selector, err := traffic.SyncTargetSelector(syncTarget.Labels[workload.InternalSyncTargetKeyLabel])
+	if err != nil {
+		return nil, err
+	}
+	return c.ingressLister.List(selector)
+}
+
 func (c *Controller) getDomainVerifications(ctx context.Context, accessor traffic.Interface) (*kuadrantv1.DomainVerificationList, error) {
 	return c.kuadrantClient.Cluster(accessor.GetLogicalCluster()).KuadrantV1().DomainVerifications().List(ctx, metav1.ListOptions{})
 }
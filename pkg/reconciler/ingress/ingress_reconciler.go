@@ -4,6 +4,7 @@ import (
 	"context"
 	"strconv"
 	"strings"
+	"time"
 
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -21,21 +22,30 @@ func (c *Controller) reconcile(ctx context.Context, ingress traffic.Interface) e
 	if ingress.GetDeletionTimestamp() == nil {
 		metadata.AddFinalizer(ingress, traffic.FINALIZER_CASCADE_CLEANUP)
 	}
-	workload.Migrate(ingress, c.Queue, c.Logger)
+	// The deletion is held while the traffic is progressively shifted away from the sync targets being deleted
+	workload.MigrateWithHold(ingress, c.Queue, c.Logger, c.dnsTTLPolicy.Duration(ingress), traffic.RolloutHoldUntil(ingress, time.Now()))
 
 	reconcilers := []traffic.Reconciler{
 		// DnsReconciler is first as it will set generatedHost field on the traffic object based on the DNSRecord it creates for each ingress
 		&traffic.DnsReconciler{
-			DeleteDNS:        c.deleteDNS,
-			GetDNS:           c.getDNS,
-			CreateDNS:        c.createDNS,
-			UpdateDNS:        c.updateDNS,
-			WatchHost:        c.hostsWatcher.StartWatching,
-			ForgetHost:       c.hostsWatcher.StopWatching,
-			ListHostWatchers: c.hostsWatcher.ListHostRecordWatchers,
-			ManagedDomain:    c.domain,
-			Log:              c.Logger,
-			DNSLookup:        c.hostResolver.LookupIPAddr,
+			DeleteDNS:          c.deleteDNS,
+			GetDNS:             c.getDNS,
+			CreateDNS:          c.createDNS,
+			UpdateDNS:          c.updateDNS,
+			WatchHost:          c.hostsWatcher.StartWatching,
+			ForgetHost:         c.hostsWatcher.StopWatching,
+			ListHostWatchers:   c.hostsWatcher.ListHostRecordWatchers,
+			ManagedDomain:      c.domain,
+			Log:                c.Logger,
+			DNSLookup:          c.hostResolver.LookupIPAddr,
+			LastKnownAddresses: c.hostResolver.LastKnownAddresses,
+			KeepPublished:      c.hostResolver.KeepPublishedAddresses,
+			GetSyncTarget:      traffic.NewSyncTargetGetter(c.syncTargetLister),
+			RoutingPolicy:      c.dnsRoutingPolicy,
+			TTLPolicy:          c.dnsTTLPolicy,
+			EndpointSafeguard:  c.dnsEndpointSafeguard,
+			Capabilities:       c.dnsRoutingCapabilities,
+			EnqueueAfter:       c.EnqueueAfter,
 		},
 		&traffic.HostReconciler{
 			Log:                    c.Logger,
@@ -7,6 +7,8 @@ import (
 
 	certman "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
 	tenancyv1alpha1 "github.com/kcp-dev/kcp/pkg/apis/tenancy/v1alpha1"
+	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
+	workloadlisters "github.com/kcp-dev/kcp/pkg/client/listers/workload/v1alpha1"
 	"github.com/kcp-dev/logicalcluster/v2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -51,7 +53,8 @@ func NewController(config *ControllerConfig) *Controller {
 	case *dns.ConfigMapHostResolver:
 		impl.Client = config.KCPKubeClient.Cluster(tenancyv1alpha1.RootCluster)
 	}
-	hostResolver = dns.NewSafeHostResolver(hostResolver)
+	// The last known good addresses of the hosts are kept, to be used when their lookup fails transiently
+	cachedHostResolver := dns.NewCachedHostResolver(dns.NewSafeHostResolver(hostResolver), dns.DefaultHostCacheMaxAge, controllerName)
 
 	base := basereconciler.NewController(controllerName, queue)
 	c := &Controller{
@@ -66,8 +69,14 @@ func NewController(config *ControllerConfig) *Controller {
 		kuadrantClient:               config.DnsRecordClient,
 		domain:                       config.Domain,
 		glbcWorkspace:                config.GLBCWorkspace,
-		hostResolver:                 hostResolver,
-		hostsWatcher:                 dns.NewHostsWatcher(&base.Logger, hostResolver, dns.DefaultInterval),
+		hostResolver:                 cachedHostResolver,
+		hostsWatcher:                 dns.NewHostsWatcher(&base.Logger, cachedHostResolver, dns.DefaultInterval),
+		syncTargetLister:             config.SyncTargetLister,
+		syncTargetInformer:           config.SyncTargetInformer,
+		dnsRoutingPolicy:             config.DNSRoutingPolicy,
+		dnsTTLPolicy:                 config.DNSTTLPolicy,
+		dnsEndpointSafeguard:         config.DNSEndpointSafeguard,
+		dnsRoutingCapabilities:       config.DNSRoutingCapabilities,
 		certInformerFactory:          config.CertificateInformer,
 		KCPInformerFactory:           config.KCPInformer,
 	}
@@ -119,6 +128,18 @@ func (c *Controller) startWatches() {
 		},
 	})
 
+	// Watch the SyncTargets drained from DNS, or whose readiness changes, when they are watched
+	if c.syncTargetInformer != nil {
+		c.syncTargetInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				if key, changed := traffic.SyncTargetChanged(oldObj, newObj); changed {
+					c.Logger.V(3).Info("requeueing routes sync target changed", "syncTarget", key)
+					c.enqueueRoutes(c.routesFromSyncTarget)(newObj)
+				}
+			},
+		})
+	}
+
 	// Watch DomainVerifications in the GLBC Virtual Workspace
 	c.KCPInformerFactory.Kuadrant().V1().DomainVerifications().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    c.enqueueRoutes(c.routesFromDomainVerification),
@@ -245,6 +266,12 @@ func (c *Controller) startWatches() {
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
+			// when a dns record is propagated we requeue the route, as its load balancer status waits for the propagation
+			if trafficKey, changed := traffic.DNSRecordPropagationChanged(oldObj, newObj); changed {
+				c.Logger.V(3).Info("reqeueuing route dns record propagation changed", "traffic key", trafficKey)
+				c.enqueueRouteByKey(trafficKey)
+				return
+			}
 			newdns := newObj.(*kuadrantv1.DNSRecord)
 			olddns := oldObj.(*kuadrantv1.DNSRecord)
 			if olddns.ResourceVersion != newdns.ResourceVersion {
@@ -272,6 +299,18 @@ type ControllerConfig struct {
 	CertProvider                    tls.Provider
 	HostResolver                    dns.HostResolver
 	GLBCWorkspace                   logicalcluster.Name
+	// SyncTargetLister lists the SyncTargets the routes are scheduled to, it is nil when they are not watched
+	SyncTargetLister workloadlisters.SyncTargetLister
+	// SyncTargetInformer notifies the changes of the SyncTargets, it is nil when they are not watched
+	SyncTargetInformer cache.SharedIndexInformer
+	// DNSRoutingPolicy is the default routing policy of the DNS records of the routes
+	DNSRoutingPolicy string
+	// DNSTTLPolicy is the policy of the TTL of the DNS records of the routes
+	DNSTTLPolicy *traffic.TTLPolicy
+	// DNSEndpointSafeguard refuses the updates removing too many targets of the DNS records of the routes
+	DNSEndpointSafeguard *traffic.EndpointSafeguard
+	// DNSRoutingCapabilities are the routing features of the DNS records supported by the DNS providers
+	DNSRoutingCapabilities dns.RoutingCapabilities
 }
 
 type Controller struct {
@@ -286,8 +325,14 @@ type Controller struct {
 	routeLister                  cache.GenericLister
 	certProvider                 tls.Provider
 	domain                       string
-	hostResolver                 dns.HostResolver
+	hostResolver                 *dns.CachedHostResolver
 	hostsWatcher                 *dns.HostsWatcher
+	syncTargetLister             workloadlisters.SyncTargetLister
+	syncTargetInformer           cache.SharedIndexInformer
+	dnsRoutingPolicy             string
+	dnsTTLPolicy                 *traffic.TTLPolicy
+	dnsEndpointSafeguard         *traffic.EndpointSafeguard
+	dnsRoutingCapabilities       dns.RoutingCapabilities
 	certInformerFactory          certmaninformer.SharedInformerFactory
 	glbcInformerFactory          informers.SharedInformerFactory
 	KCPInformerFactory           kuadrantInformer.SharedInformerFactory
@@ -405,6 +450,26 @@ func (c *Controller) routesFromDomainVerification(obj interface{}) ([]*routeapiv
 	return routesToEnqueue, nil
 }
 
+// routesFromSyncTarget returns the routes scheduled to the SyncTarget
+func (c *Controller) routesFromSyncTarget(obj interface{}) ([]*routeapiv1.Route, error) {
+	syncTarget := obj.(*workload.SyncTarget)
+	selector, err := traffic.SyncTargetSelector(syncTarget.Labels[workload.InternalSyncTargetKeyLabel])
+	if err != nil {
+		return nil, err
+	}
+	objects, err := c.routeLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]*routeapiv1.Route, 0, len(objects))
+	for _, object := range objects {
+		route := &routeapiv1.Route{}
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(object.(*unstructured.Unstructured).Object, route)
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
 func (c *Controller) getRouteByKey(key string) (*routeapiv1.Route, error) {
 	object, exists, err := c.indexer.GetByKey(key)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"k8s.io/client-go/tools/cache"
 
@@ -21,21 +22,30 @@ func (c *Controller) reconcile(ctx context.Context, route *traffic.Route) error
 		metadata.AddFinalizer(route, traffic.FINALIZER_CASCADE_CLEANUP)
 	}
 	// TODO evaluate where this actually belongs
-	workload.Migrate(route, c.Queue, c.Logger)
+	// The deletion is held while the traffic is progressively shifted away from the sync targets being deleted
+	workload.MigrateWithHold(route, c.Queue, c.Logger, c.dnsTTLPolicy.Duration(route), traffic.RolloutHoldUntil(route, time.Now()))
 
 	reconcilers := []traffic.Reconciler{
 		// DnsReconciler is first as it will set generatedHost field on the traffic object based on the DNSRecord it creates for each route
 		&traffic.DnsReconciler{
-			DeleteDNS:        c.deleteDNS,
-			GetDNS:           c.getDNS,
-			CreateDNS:        c.createDNS,
-			UpdateDNS:        c.updateDNS,
-			WatchHost:        c.hostsWatcher.StartWatching,
-			ForgetHost:       c.hostsWatcher.StopWatching,
-			ListHostWatchers: c.hostsWatcher.ListHostRecordWatchers,
-			ManagedDomain:    c.domain,
-			Log:              c.Logger,
-			DNSLookup:        c.hostResolver.LookupIPAddr,
+			DeleteDNS:          c.deleteDNS,
+			GetDNS:             c.getDNS,
+			CreateDNS:          c.createDNS,
+			UpdateDNS:          c.updateDNS,
+			WatchHost:          c.hostsWatcher.StartWatching,
+			ForgetHost:         c.hostsWatcher.StopWatching,
+			ListHostWatchers:   c.hostsWatcher.ListHostRecordWatchers,
+			ManagedDomain:      c.domain,
+			Log:                c.Logger,
+			DNSLookup:          c.hostResolver.LookupIPAddr,
+			LastKnownAddresses: c.hostResolver.LastKnownAddresses,
+			KeepPublished:      c.hostResolver.KeepPublishedAddresses,
+			GetSyncTarget:      traffic.NewSyncTargetGetter(c.syncTargetLister),
+			RoutingPolicy:      c.dnsRoutingPolicy,
+			TTLPolicy:          c.dnsTTLPolicy,
+			EndpointSafeguard:  c.dnsEndpointSafeguard,
+			Capabilities:       c.dnsRoutingCapabilities,
+			EnqueueAfter:       c.EnqueueAfter,
 		},
 		&traffic.HostReconciler{
 			Log:                    c.Logger,
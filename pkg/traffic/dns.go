@@ -3,9 +3,11 @@ package traffic
 import (
 	"context"
 	"fmt"
+	"net"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -14,6 +16,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/utils/pointer"
 
@@ -39,6 +43,25 @@ type DnsReconciler struct {
 	Log              logr.Logger
 	ManagedDomain    string
 	DNSLookup        func(ctx context.Context, host string) ([]dns.HostAddress, error)
+	GetSyncTarget    func(key string) (*workload.SyncTarget, error)
+	// LastKnownAddresses returns the last known good addresses of a host, and their age, used when its lookup fails
+	// transiently
+	LastKnownAddresses func(host string) ([]dns.HostAddress, time.Duration, bool)
+	// KeepPublished returns whether the addresses a host is published with can be kept, and for how long its lookup
+	// has been failing, when its lookup fails transiently without last known good addresses
+	KeepPublished func(host string) (time.Duration, bool)
+	// RoutingPolicy is the default routing policy of the DNS records, one of geo, latency or weighted
+	RoutingPolicy string
+	// TTLPolicy is the policy of the TTL of the DNS records, the default TTL is used when it is nil
+	TTLPolicy *TTLPolicy
+	// EnqueueAfter requeues the traffic object, so that the TTL of its DNS record is stepped back up once its targets
+	// are stable
+	EnqueueAfter func(obj interface{}, duration time.Duration)
+	// EndpointSafeguard refuses the updates removing all the targets of the DNS records, or too many of them at once,
+	// any update is allowed when it is nil
+	EndpointSafeguard *EndpointSafeguard
+	// Capabilities are the routing features of the DNS records supported by the DNS providers
+	Capabilities dns.RoutingCapabilities
 }
 
 func (r *DnsReconciler) GetName() string {
@@ -76,8 +99,8 @@ func (r *DnsReconciler) Reconcile(ctx context.Context, accessor Interface) (Reco
 		return ReconcileStatusContinue, nil
 	}
 	// If it does exist, update it
-	activeDNSTargetIPs := map[string][]string{}
-	deletingTargetIPs := map[string][]string{}
+	deletingDNSTargets := map[string][]string{}
+	var activeTargets, drainedTargets, unreadyTargets []dns.Target
 	managedHost := metadata.GetAnnotation(existing, ANNOTATION_HCG_HOST)
 	if managedHost == "" {
 		// This covers upgrade scenario: checking traffic object for the generated host label and updating DNS record with it
@@ -94,52 +117,170 @@ func (r *DnsReconciler) Reconcile(ctx context.Context, accessor Interface) (Reco
 	if err != nil {
 		return ReconcileStatusContinue, err
 	}
-	var activeLBHosts []string
+	// Host targets are published as CNAME records, unless they are mixed with IP targets, as a CNAME record cannot
+	// coexist with other records for the same name, or unless there are several of them and the DNS providers do not
+	// support weighted CNAME records. In that case, the hosts are resolved and watched, so that the A records are kept
+	// up to date with their IPs.
+	recordType := dnsRecordTypeForTargets(targets, r.Capabilities.WeightedCNAMEs)
+	// Active/passive failover takes precedence over the routing policy. The hosts are then resolved, as the failover
+	// relies on the health checks, that probe IP addresses.
+	roles := r.failoverRoles(accessor, targets)
+	if roles != nil {
+		recordType = v1.ARecordType
+	}
+	// The geo and latency routing policies group the targets by the location of their sync target
+	policy := r.routingPolicy(accessor)
+	var groups map[string]string
+	if roles == nil {
+		groups, err = r.targetGroups(targets, policy)
+		if err != nil {
+			return ReconcileStatusContinue, err
+		}
+	}
+	// The traffic is split between the sync targets with the weights of the weights annotation, evenly otherwise
+	weights, err := r.targetWeights(accessor, targets)
+	if err != nil {
+		r.Log.Info("invalid DNS weights, splitting the traffic evenly", "error", err.Error())
+		metadata.AddAnnotation(accessor, ANNOTATION_DNS_WEIGHTS_ERROR, err.Error())
+	} else {
+		metadata.RemoveAnnotation(accessor, ANNOTATION_DNS_WEIGHTS_ERROR)
+	}
+	// The traffic is shifted progressively from the sync targets being deleted to the new ones, when the rollout
+	// annotation is set, in which case the sync targets being deleted keep receiving traffic until the rollout completes
+	var rolloutTargetWeights map[string]int
+	if roles == nil {
+		var nextStep time.Duration
+		rolloutTargetWeights, nextStep, err = rolloutWeights(accessor, targets, time.Now())
+		if err != nil {
+			r.Log.Info("invalid DNS rollout, switching the traffic at once", "error", err.Error())
+			metadata.AddAnnotation(accessor, ANNOTATION_DNS_ROLLOUT_ERROR, err.Error())
+		} else {
+			metadata.RemoveAnnotation(accessor, ANNOTATION_DNS_ROLLOUT_ERROR)
+		}
+		if rolloutTargetWeights != nil {
+			weights = rolloutTargetWeights
+		}
+		if nextStep > 0 && r.EnqueueAfter != nil {
+			r.EnqueueAfter(accessor, nextStep)
+		}
+	}
+	// The weight of a sync target is split between its hosts
+	var hostWeights map[string]int
+	clusterHosts := map[string]int{}
+	if weights != nil {
+		hostWeights = map[string]int{}
+		for _, target := range targets {
+			clusterHosts[target.Cluster]++
+		}
+	}
+	// The drained sync targets are removed from the DNS records, while the workload keeps running on them
+	drained := r.drainedTargets(accessor, targets)
+	// The sync targets that are not ready, e.g. that have missed their heartbeat, are removed from the DNS records
+	unready := r.unreadyTargets(targets)
+	hostGroups := map[string]string{}
+	hostRoles := map[string]string{}
 	for _, target := range targets {
 		host := target.Value
-		deleteAnnotation := workload.InternalClusterDeletionTimestampAnnotationPrefix + target.Cluster
-		if metadata.HasAnnotation(accessor, deleteAnnotation) {
-			deletingTargetIPs[host] = append(deletingTargetIPs[host], host)
-			continue
+		hostGroups[host] = groups[target.Cluster]
+		hostRoles[host] = roles[target.Cluster]
+		if weights != nil {
+			hostWeights[host] = splitWeight(weights[target.Cluster], clusterHosts[target.Cluster])
 		}
-		if target.TargetType == dns.TargetTypeIP {
-			activeDNSTargetIPs[host] = append(activeDNSTargetIPs[host], host)
+		if drained.Has(target.Cluster) {
+			drainedTargets = append(drainedTargets, target)
 			continue
 		}
-		// for a non ip value look up the DNS
-		addr, err := r.DNSLookup(ctx, host)
-		if err != nil {
-			return ReconcileStatusContinue, fmt.Errorf("DNSLookup failed for host %s : %s", host, err)
+		if unready.Has(target.Cluster) {
+			unreadyTargets = append(unreadyTargets, target)
+			continue
 		}
-		for _, add := range addr {
-			activeDNSTargetIPs[host] = append(activeDNSTargetIPs[host], add.IP.String())
+		deleteAnnotation := workload.InternalClusterDeletionTimestampAnnotationPrefix + target.Cluster
+		if metadata.HasAnnotation(accessor, deleteAnnotation) {
+			deletingDNSTargets[host] = append(deletingDNSTargets[host], host)
+			if rolloutTargetWeights == nil {
+				continue
+			}
 		}
-		//add the host to host watcher to keep our DNS upto date
-		// If it is not an IP we add it to the host watcher that triggers an update when it gets IPS
-		r.WatchHost(ctx, key, host)
-		activeLBHosts = append(activeLBHosts, host)
+		activeTargets = append(activeTargets, target)
 	}
+	activeDNSTargets, activeLBHosts, lookupErrs := r.resolveTargets(ctx, key, existing, activeTargets, recordType)
 
-	// clean up any watchers no longer needed TODO(cbrookes) we may want to put this in a defer or a different routine so it always cleans up
-	hostRecordWatchers := r.ListHostWatchers(key)
-	for _, watcher := range hostRecordWatchers {
-		if !slice.ContainsString(activeLBHosts, watcher.Host) {
-			r.ForgetHost(key, watcher.Host)
-		}
+	// the record is not updated when none of the targets is left because of lookup failures
+	if len(activeDNSTargets) == 0 && len(lookupErrs) > 0 {
+		r.forgetHosts(key, activeLBHosts)
+		return ReconcileStatusContinue, utilerrors.NewAggregate(lookupErrs)
 	}
 
 	// no non-deleting hosts have an IP yet, so continue using IPs of "losing" clusters
-	if len(activeDNSTargetIPs) == 0 && len(deletingTargetIPs) > 0 {
+	if len(activeDNSTargets) == 0 && len(deletingDNSTargets) > 0 {
 		r.Log.V(3).Info("setting the dns Target to the deleting Target as no new dns targets set yet")
-		activeDNSTargetIPs = deletingTargetIPs
+		activeDNSTargets = deletingDNSTargets
+	}
+	// none of the sync targets is ready, so keep the last targets rather than removing the host from DNS. The hosts are
+	// resolved like the active ones, as they may be published with address records.
+	if len(activeDNSTargets) == 0 && len(unreadyTargets) > 0 {
+		r.Log.Info("none of the sync targets is ready, keeping them in the DNS record", "unready", unready.List())
+		activeDNSTargets, activeLBHosts, lookupErrs = r.resolveTargets(ctx, key, existing, unreadyTargets, recordType)
+		unready = sets.NewString()
+	}
+	// all the sync targets are drained, so keep them rather than removing the host from DNS
+	if len(activeDNSTargets) == 0 && len(drainedTargets) > 0 {
+		r.Log.Info("all the sync targets are drained, keeping them in the DNS record", "drained", drained.List())
+		activeDNSTargets, activeLBHosts, lookupErrs = r.resolveTargets(ctx, key, existing, drainedTargets, recordType)
+		drained = sets.NewString()
+	}
+	r.forgetHosts(key, activeLBHosts)
+	// the record is not updated when none of the kept targets could be resolved either
+	if len(activeDNSTargets) == 0 && len(lookupErrs) > 0 {
+		return ReconcileStatusContinue, utilerrors.NewAggregate(lookupErrs)
+	}
+	// The sync targets drained from the DNS records are reported on the traffic object
+	if drained.Len() > 0 {
+		metadata.AddAnnotation(accessor, ANNOTATION_DNS_DRAINED, strings.Join(drained.List(), ","))
+	} else {
+		metadata.RemoveAnnotation(accessor, ANNOTATION_DNS_DRAINED)
+	}
+	if unready.Len() > 0 {
+		metadata.AddAnnotation(accessor, ANNOTATION_DNS_UNREADY, strings.Join(unready.List(), ","))
+	} else {
+		metadata.RemoveAnnotation(accessor, ANNOTATION_DNS_UNREADY)
+	}
+	ttl, err := r.TTLPolicy.RecordTTL(accessor)
+	if err != nil {
+		r.Log.Info("invalid DNS record TTL, using default", "error", err.Error(), "default", ttl)
 	}
 	copyDNS := existing.DeepCopy()
-	r.setEndpointFromTargets(managedHost, activeDNSTargetIPs, copyDNS)
+	// The effective TTL is recorded, so that the deletion of the migrated workloads is delayed by it, rather than by the
+	// migration TTL the records are lowered to
+	metadata.AddAnnotation(copyDNS, ANNOTATION_DNS_RECORD_EFFECTIVE_TTL, strconv.FormatInt(int64(ttl), 10))
+	ttl, stableAfter := r.TTLPolicy.AdaptRecordTTL(copyDNS, ttl, targets, len(deletingDNSTargets) > 0, time.Now())
+	if stableAfter > 0 && r.EnqueueAfter != nil {
+		r.EnqueueAfter(accessor, stableAfter)
+	}
+	switch {
+	case roles != nil:
+		r.setFailoverEndpointsFromTargets(managedHost, activeDNSTargets, hostRoles, ttl, copyDNS)
+	case groups != nil:
+		r.setGroupedEndpointsFromTargets(managedHost, activeDNSTargets, hostGroups, hostWeights, recordType, policy, ttl, copyDNS)
+	default:
+		r.setEndpointFromTargets(managedHost, activeDNSTargets, hostWeights, recordType, ttl, copyDNS)
+	}
 	objMeta, err := meta.Accessor(accessor)
 	if err != nil {
 		return ReconcileStatusContinue, err
 	}
 	copyHealthAnnotations(copyDNS, objMeta)
+	copyRolloutAnnotations(copyDNS, objMeta)
+	// The update is refused when it would remove all the targets, or too many of them at once, unless explicitly
+	// allowed on the traffic object, and reported on the traffic object until the targets are back. The DNS record is
+	// deleted along with the traffic object regardless.
+	if err := r.EndpointSafeguard.Check(existing.Spec.Endpoints, copyDNS.Spec.Endpoints); err != nil && metadata.GetAnnotation(accessor, ANNOTATION_DNS_ALLOW_ENDPOINT_DROP) != "true" {
+		r.Log.Info("refusing to update DNSRecord", "record", copyDNS.Name, "error", err.Error())
+		metadata.AddAnnotation(accessor, ANNOTATION_DNS_UPDATE_BLOCKED, err.Error())
+		DNSRecordUpdateBlockedTotal.Inc()
+		return ReconcileStatusContinue, utilerrors.NewAggregate(lookupErrs)
+	}
+	metadata.RemoveAnnotation(accessor, ANNOTATION_DNS_UPDATE_BLOCKED)
 	if !equality.Semantic.DeepEqual(copyDNS, existing) {
 		if existing.Spec.Endpoints == nil && copyDNS.Spec.Endpoints != nil {
 			// metric to observe the accessor admission time
@@ -150,12 +291,15 @@ func (r *DnsReconciler) Reconcile(ctx context.Context, accessor Interface) (Reco
 			return ReconcileStatusStop, err
 		}
 	}
-	// Once we know the DNS is creatd up and TMC is enabled for this ingress (IE status is stored in annotations) set the DNS load balancer in the ingress status.
-	if accessor.TMCEnabed() {
+	// Once we know the DNS is served and TMC is enabled for this ingress (IE status is stored in annotations) set the DNS load balancer in the ingress status.
+	// The status is only set once the record has been propagated to the name servers of the DNS providers, and is kept
+	// while later changes of the record are propagated.
+	if accessor.TMCEnabed() && dns.IsRecordPropagated(existing) {
 		accessor.SetDNSLBHost(managedHost)
 	}
 
-	return ReconcileStatusContinue, nil
+	// The skipped hosts are retried
+	return ReconcileStatusContinue, utilerrors.NewAggregate(lookupErrs)
 }
 
 func newDNSRecordForObject(obj runtime.Object) (*v1.DNSRecord, error) {
@@ -206,22 +350,469 @@ func copyHealthAnnotations(dnsRecord *v1.DNSRecord, objectMeta metav1.Object) {
 	}))
 }
 
-func (r *DnsReconciler) setEndpointFromTargets(dnsName string, dnsTargets map[string][]string, dnsRecord *v1.DNSRecord) {
+// dnsRecordTypeForTargets returns CNAME when all the targets are hosts, as long as there is a single host or weighted
+// CNAME records are supported, and A otherwise, in which case the address records are A or AAAA records depending on
+// the IP family of each target.
+func dnsRecordTypeForTargets(targets []dns.Target, weightedCNAMEs bool) v1.DNSRecordType {
+	hosts := sets.NewString()
+	for _, target := range targets {
+		if target.TargetType != dns.TargetTypeHost {
+			return v1.ARecordType
+		}
+		hosts.Insert(target.Value)
+	}
+	if hosts.Len() > 1 && !weightedCNAMEs {
+		return v1.ARecordType
+	}
+	return v1.CNAMERecordType
+}
+
+// endpointRecordType returns the type of the record for the given target, i.e. AAAA for IPv6 addresses when the
+// targets are published as address records.
+func endpointRecordType(target string, recordType v1.DNSRecordType) v1.DNSRecordType {
+	if recordType == v1.CNAMERecordType {
+		return recordType
+	}
+	if ip := net.ParseIP(target); ip != nil && ip.To4() == nil {
+		return v1.AAAARecordType
+	}
+	return v1.ARecordType
+}
+
+// setEndpointFromTargets sets the weighted endpoints of the targets, the traffic being split between the hosts with the
+// given weights, or evenly when the weights are nil.
+func (r *DnsReconciler) setEndpointFromTargets(dnsName string, dnsTargets map[string][]string, hostWeights map[string]int, recordType v1.DNSRecordType, ttl v1.TTL, dnsRecord *v1.DNSRecord) {
+	newEndpoints := endpointsForTargets(dnsName, dnsTargets, hostWeights, recordType, ttl, currentEndpointsByAddress(dnsRecord))
+
+	sortEndpoints(newEndpoints)
+	dnsRecord.Spec.Endpoints = newEndpoints
+}
+
+// setGroupedEndpointsFromTargets sets the endpoints of the two-tier DNS record structure of the geo-aware and latency
+// routing policies, i.e. the weighted records of the targets of each group (continent or region), named after a group
+// specific host, and the CNAME records of the host, with the routing policy of the groups, that point to the group
+// specific hosts.
+func (r *DnsReconciler) setGroupedEndpointsFromTargets(dnsName string, dnsTargets map[string][]string, hostGroups map[string]string, hostWeights map[string]int, recordType v1.DNSRecordType, policy string, ttl v1.TTL, dnsRecord *v1.DNSRecord) {
+	currentEndpoints := currentEndpointsByAddress(dnsRecord)
+	groupTargets := map[string]map[string][]string{}
+	for host, targets := range dnsTargets {
+		group := hostGroups[host]
+		if groupTargets[group] == nil {
+			groupTargets[group] = map[string][]string{}
+		}
+		groupTargets[group][host] = targets
+	}
+
+	var newEndpoints []*v1.Endpoint
+	defaultGroup := ""
+	for group, targets := range groupTargets {
+		groupHost := groupDNSName(dnsName, group)
+		newEndpoints = append(newEndpoints, endpointsForTargets(groupHost, targets, hostWeights, recordType, ttl, currentEndpoints)...)
+		newEndpoints = append(newEndpoints, groupEndpoint(dnsName, policy, group, groupHost, ttl))
+		// The group with the most targets, or the first one in alphabetical order, is the default
+		if defaultGroup == "" || len(targets) > len(groupTargets[defaultGroup]) ||
+			(len(targets) == len(groupTargets[defaultGroup]) && group < defaultGroup) {
+			defaultGroup = group
+		}
+	}
+	// Latency records answer the queries from all the locations, whereas geolocation records need a default record
+	if policy == RoutingPolicyGeo && defaultGroup != "" {
+		newEndpoints = append(newEndpoints, groupEndpoint(dnsName, policy, "*", groupDNSName(dnsName, defaultGroup), ttl))
+	}
+
+	sortEndpoints(newEndpoints)
+	dnsRecord.Spec.Endpoints = newEndpoints
+}
+
+// routingPolicy returns the routing policy of the traffic object, set with the routing policy annotation, or the
+// default routing policy of the reconciler.
+func (r *DnsReconciler) routingPolicy(accessor Interface) string {
+	policy := metadata.GetAnnotation(accessor, ANNOTATION_DNS_ROUTING_POLICY)
+	if policy == "" {
+		policy = r.RoutingPolicy
+	}
+	switch policy {
+	case RoutingPolicyGeo, RoutingPolicyLatency, RoutingPolicyWeighted:
+		return policy
+	case "":
+		return RoutingPolicyGeo
+	default:
+		r.Log.Info("unsupported DNS routing policy, using default", "policy", policy, "default", RoutingPolicyGeo)
+		return RoutingPolicyGeo
+	}
+}
+
+// targetGroups returns the group of the sync target of each target, i.e. its continent for the geo routing policy,
+// or its region for the latency routing policy. It returns nil when the SyncTargets are not watched, when the DNS
+// providers do not support the routing policy, or when any of the sync targets has no group, in which case the DNS
+// records are weighted only.
+func (r *DnsReconciler) targetGroups(targets []dns.Target, policy string) (map[string]string, error) {
+	if r.GetSyncTarget == nil || len(targets) == 0 || policy == RoutingPolicyWeighted {
+		return nil, nil
+	}
+	if (policy == RoutingPolicyGeo && !r.Capabilities.GeolocationRouting) || (policy == RoutingPolicyLatency && !r.Capabilities.LatencyRouting) {
+		r.Log.V(3).Info("routing policy not supported by the DNS providers, using weighted DNS records", "policy", policy)
+		return nil, nil
+	}
+	groupOf := syncTargetContinent
+	if policy == RoutingPolicyLatency {
+		groupOf = syncTargetRegion
+	}
+	groups := map[string]string{}
+	for _, target := range targets {
+		if _, ok := groups[target.Cluster]; ok {
+			continue
+		}
+		syncTarget, err := r.GetSyncTarget(target.Cluster)
+		if k8errors.IsNotFound(err) {
+			r.Log.V(3).Info("sync target not found, using weighted DNS records", "syncTarget", target.Cluster, "policy", policy)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		group := groupOf(syncTarget)
+		if group == "" {
+			r.Log.V(3).Info("sync target has no location, using weighted DNS records", "syncTarget", syncTarget.Name, "policy", policy)
+			return nil, nil
+		}
+		groups[target.Cluster] = group
+	}
+	return groups, nil
+}
+
+// groupDNSName returns the host of the given group, by inserting the group after the first label of the host, e.g.
+// xyz.na.dev.hcpapps.net for xyz.dev.hcpapps.net and the NA continent.
+func groupDNSName(dnsName, group string) string {
+	labels := strings.SplitN(dnsName, ".", 2)
+	if len(labels) < 2 {
+		return strings.ToLower(group) + "." + dnsName
+	}
+	return labels[0] + "." + strings.ToLower(group) + "." + labels[1]
+}
+
+// groupEndpoint returns the CNAME endpoint that points the queries routed to the given group to the group host.
+func groupEndpoint(dnsName, policy, group, groupHost string, ttl v1.TTL) *v1.Endpoint {
+	setIdentifier := group
+	if group == "*" {
+		setIdentifier = "default"
+	}
+	endpoint := &v1.Endpoint{
+		DNSName:       dnsName,
+		RecordType:    string(v1.CNAMERecordType),
+		SetIdentifier: setIdentifier,
+		Targets:       []string{groupHost},
+		RecordTTL:     ttl,
+		Labels:        map[string]string{"id": setIdentifier},
+	}
+	if policy == RoutingPolicyLatency {
+		endpoint.SetProviderSpecific(aws.ProviderSpecificRegion, group)
+	} else {
+		endpoint.SetProviderSpecific(aws.ProviderSpecificGeolocationContinentCode, group)
+	}
+	return endpoint
+}
+
+// setFailoverEndpointsFromTargets sets the endpoints of the active/passive failover DNS record structure, i.e. a
+// PRIMARY and a SECONDARY record set per address family, holding the addresses of the targets of each role, as Route53
+// only allows a single record set of each role for a name and type. The PRIMARY record sets answer the queries while
+// their health check is healthy.
+func (r *DnsReconciler) setFailoverEndpointsFromTargets(dnsName string, dnsTargets map[string][]string, hostRoles map[string]string, ttl v1.TTL, dnsRecord *v1.DNSRecord) {
+	type roleRecordType struct {
+		role       string
+		recordType v1.DNSRecordType
+	}
+	currentEndpoints := map[roleRecordType]*v1.Endpoint{}
+	for _, endpoint := range dnsRecord.Spec.Endpoints {
+		if role, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificFailover); ok {
+			currentEndpoints[roleRecordType{role, v1.DNSRecordType(endpoint.RecordType)}] = endpoint
+		}
+	}
+	roleAddresses := map[roleRecordType][]string{}
+	for host, targets := range dnsTargets {
+		for _, target := range targets {
+			key := roleRecordType{hostRoles[host], endpointRecordType(target, v1.ARecordType)}
+			roleAddresses[key] = append(roleAddresses[key], target)
+		}
+	}
+
+	var newEndpoints []*v1.Endpoint
+	for key, addresses := range roleAddresses {
+		sort.Strings(addresses)
+		// The current endpoint is updated, so that it keeps the identifier of its health check
+		endpoint, ok := currentEndpoints[key]
+		if !ok {
+			endpoint = &v1.Endpoint{
+				SetIdentifier: strings.ToLower(key.role),
+			}
+		}
+		endpoint.DNSName = dnsName
+		endpoint.RecordType = string(key.recordType)
+		endpoint.Targets = addresses
+		endpoint.RecordTTL = ttl
+		endpoint.SetProviderSpecific(aws.ProviderSpecificFailover, key.role)
+		newEndpoints = append(newEndpoints, endpoint)
+	}
+
+	sortEndpoints(newEndpoints)
+	dnsRecord.Spec.Endpoints = newEndpoints
+}
+
+// failoverRoles returns the failover role of the sync target of each target, i.e. PRIMARY for the sync targets listed,
+// by name or key, in the failover annotation, and SECONDARY for the others. It returns nil when the annotation is not
+// set, when none of the sync targets is primary, or when the DNS providers do not support failover routing, in which
+// case the DNS records follow the routing policy.
+func (r *DnsReconciler) failoverRoles(accessor Interface, targets []dns.Target) map[string]string {
+	value := metadata.GetAnnotation(accessor, ANNOTATION_DNS_FAILOVER_PRIMARY)
+	if value == "" || len(targets) == 0 {
+		return nil
+	}
+	if !r.Capabilities.FailoverRouting {
+		r.Log.Info("failover routing not supported by the DNS providers, failover disabled", "primary", value)
+		return nil
+	}
+	primaries := sets.NewString()
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			primaries.Insert(name)
+		}
+	}
+	roles := map[string]string{}
+	hasPrimary := false
+	for _, target := range targets {
+		role := aws.FailoverSecondary
+		if primaries.Has(target.Cluster) || primaries.Has(r.syncTargetName(target.Cluster)) {
+			role = aws.FailoverPrimary
+			hasPrimary = true
+		}
+		roles[target.Cluster] = role
+	}
+	if !hasPrimary {
+		r.Log.Info("none of the sync targets is primary, failover disabled", "primary", value)
+		return nil
+	}
+	return roles
+}
+
+// drainedTargets returns the sync targets of the targets that are drained, i.e. listed, by name or key, in the drain
+// annotation of the traffic object, or labelled with the drain label.
+func (r *DnsReconciler) drainedTargets(accessor Interface, targets []dns.Target) sets.String {
+	annotated := sets.NewString()
+	for _, name := range strings.Split(metadata.GetAnnotation(accessor, ANNOTATION_DNS_DRAIN), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			annotated.Insert(name)
+		}
+	}
+	drained := sets.NewString()
+	for _, target := range targets {
+		if drained.Has(target.Cluster) {
+			continue
+		}
+		if annotated.Has(target.Cluster) {
+			drained.Insert(target.Cluster)
+			continue
+		}
+		if r.GetSyncTarget == nil {
+			continue
+		}
+		syncTarget, err := r.GetSyncTarget(target.Cluster)
+		if err != nil {
+			continue
+		}
+		if annotated.Has(syncTarget.Name) || syncTargetDrained(syncTarget) {
+			drained.Insert(target.Cluster)
+		}
+	}
+	return drained
+}
+
+// unreadyTargets returns the sync targets of the targets whose SyncTarget is not ready. It is empty when the
+// SyncTargets are not watched.
+func (r *DnsReconciler) unreadyTargets(targets []dns.Target) sets.String {
+	unready := sets.NewString()
+	if r.GetSyncTarget == nil {
+		return unready
+	}
+	for _, target := range targets {
+		syncTarget, err := r.GetSyncTarget(target.Cluster)
+		if err != nil {
+			continue
+		}
+		if !syncTargetReady(syncTarget) {
+			unready.Insert(target.Cluster)
+		}
+	}
+	return unready
+}
+
+// resolveTargets returns the values the targets are published with, indexed by host, along with the hosts that are
+// looked up, and the errors of the lookups that failed. The IP targets, and the hosts published as CNAME records, are
+// published as is. The other hosts are resolved and watched, so that the address records are kept up to date with
+// their IPs, and published with their last known good addresses, or the addresses they have in the existing DNS record,
+// when their lookup fails transiently, as long as they have been resolved within the max age of the last known good
+// addresses.
+func (r *DnsReconciler) resolveTargets(ctx context.Context, key interface{}, existing *v1.DNSRecord, targets []dns.Target, recordType v1.DNSRecordType) (map[string][]string, []string, []error) {
+	dnsTargets := map[string][]string{}
+	var lookedUpHosts []string
+	var lookupErrs []error
+	for _, target := range targets {
+		host := target.Value
+		if target.TargetType == dns.TargetTypeIP || recordType == v1.CNAMERecordType {
+			dnsTargets[host] = append(dnsTargets[host], host)
+			continue
+		}
+		// for a non ip value look up the DNS
+		addr, err := r.DNSLookup(ctx, host)
+		if err != nil {
+			// The last known good addresses are used when the lookup fails transiently, or else the addresses the host
+			// is currently published with. The host is skipped otherwise, e.g. when it no longer exists, so that the
+			// DNS record is still updated with the other targets.
+			if last, age, ok := r.lastKnownAddresses(host, err); ok {
+				r.Log.Info("DNS lookup failed, using the last known good addresses", "host", host, "age", age.String(), "error", err.Error())
+				for _, add := range last {
+					dnsTargets[host] = append(dnsTargets[host], add.IP.String())
+				}
+			} else if published, age, ok := r.publishedHostAddresses(existing, host, err); ok {
+				r.Log.Info("DNS lookup failed, keeping the published addresses", "host", host, "age", age.String(), "error", err.Error())
+				dnsTargets[host] = published
+			} else {
+				r.Log.Info("DNS lookup failed, skipping host", "host", host, "error", err.Error())
+				lookupErrs = append(lookupErrs, fmt.Errorf("DNSLookup failed for host %s : %s", host, err))
+			}
+		}
+		for _, add := range addr {
+			dnsTargets[host] = append(dnsTargets[host], add.IP.String())
+		}
+		//add the host to host watcher to keep our DNS upto date
+		// If it is not an IP we add it to the host watcher that triggers an update when it gets IPS
+		r.WatchHost(ctx, key, host)
+		lookedUpHosts = append(lookedUpHosts, host)
+	}
+	return dnsTargets, lookedUpHosts, lookupErrs
+}
+
+// forgetHosts stops watching the hosts of the traffic object other than the given ones
+func (r *DnsReconciler) forgetHosts(key interface{}, hosts []string) {
+	// clean up any watchers no longer needed TODO(cbrookes) we may want to put this in a defer or a different routine so it always cleans up
+	for _, watcher := range r.ListHostWatchers(key) {
+		if !slice.ContainsString(hosts, watcher.Host) {
+			r.ForgetHost(key, watcher.Host)
+		}
+	}
+}
+
+// lastKnownAddresses returns the last known good addresses of the host, and their age, when its lookup has failed
+// transiently.
+func (r *DnsReconciler) lastKnownAddresses(host string, err error) ([]dns.HostAddress, time.Duration, bool) {
+	if r.LastKnownAddresses == nil || !dns.IsTransientLookupError(err) {
+		return nil, 0, false
+	}
+	return r.LastKnownAddresses(host)
+}
+
+// publishedHostAddresses returns the addresses the host is published with in the DNS record, and for how long its
+// lookup has been failing, when its lookup has failed transiently for no longer than the max age of the last known
+// good addresses.
+func (r *DnsReconciler) publishedHostAddresses(dnsRecord *v1.DNSRecord, host string, err error) ([]string, time.Duration, bool) {
+	if r.KeepPublished == nil || !dns.IsTransientLookupError(err) {
+		return nil, 0, false
+	}
+	published := hostAddresses(dnsRecord, host)
+	if len(published) == 0 {
+		return nil, 0, false
+	}
+	age, ok := r.KeepPublished(host)
+	if !ok {
+		return nil, 0, false
+	}
+	return published, age, true
+}
+
+// syncTargetName returns the name of the SyncTarget with the given key, or an empty string when it cannot be found,
+// e.g. when the SyncTargets are not watched.
+func (r *DnsReconciler) syncTargetName(key string) string {
+	if r.GetSyncTarget == nil {
+		return ""
+	}
+	syncTarget, err := r.GetSyncTarget(key)
+	if err != nil {
+		return ""
+	}
+	return syncTarget.Name
+}
+
+func currentEndpointsByAddress(dnsRecord *v1.DNSRecord) map[string]*v1.Endpoint {
 	currentEndpoints := make(map[string]*v1.Endpoint, len(dnsRecord.Spec.Endpoints))
 	for _, endpoint := range dnsRecord.Spec.Endpoints {
+		// The failover endpoints hold the addresses of all the targets of a role, they are not reused for a single target
+		if _, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificFailover); ok {
+			continue
+		}
 		address, ok := endpoint.GetAddress()
 		if !ok {
 			continue
 		}
 		currentEndpoints[address] = endpoint
 	}
+	return currentEndpoints
+}
+
+// endpointHostLabel is the label of the endpoints holding an address of a load balancer host, so that the host can
+// keep its published addresses when it can not be resolved
+const endpointHostLabel = "host"
+
+// setEndpointHost labels the endpoint with the load balancer host its target has been resolved from, if any
+func setEndpointHost(endpoint *v1.Endpoint, host, target string) {
+	if host == target {
+		delete(endpoint.Labels, endpointHostLabel)
+		return
+	}
+	if endpoint.Labels == nil {
+		endpoint.Labels = v1.Labels{}
+	}
+	endpoint.Labels[endpointHostLabel] = host
+}
+
+// hostAddresses returns the addresses the load balancer host is published with in the DNS record
+func hostAddresses(dnsRecord *v1.DNSRecord, host string) []string {
+	var addresses []string
+	for _, endpoint := range dnsRecord.Spec.Endpoints {
+		if endpoint.Labels[endpointHostLabel] == host {
+			addresses = append(addresses, endpoint.Targets...)
+		}
+	}
+	return addresses
+}
+
+func sortEndpoints(endpoints []*v1.Endpoint) {
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Targets[0] != endpoints[j].Targets[0] {
+			return endpoints[i].Targets[0] < endpoints[j].Targets[0]
+		}
+		return endpoints[i].SetIdentifier < endpoints[j].SetIdentifier
+	})
+}
+
+// endpointsForTargets returns the weighted endpoints named dnsName for the given targets, updating the current
+// endpoints for the same targets if any.
+func endpointsForTargets(dnsName string, dnsTargets map[string][]string, hostWeights map[string]int, recordType v1.DNSRecordType, ttl v1.TTL, currentEndpoints map[string]*v1.Endpoint) []*v1.Endpoint {
 	var (
 		newEndpoints []*v1.Endpoint
 		endpoint     *v1.Endpoint
 	)
 	ok := false
-	for _, targets := range dnsTargets {
+	for host, targets := range dnsTargets {
+		weight := maxHostWeight
+		if hostWeights != nil {
+			weight = hostWeights[host]
+		}
+		// The traffic is split evenly between the IPs of each family, as A and AAAA records are distinct record sets
+		families := map[v1.DNSRecordType]int{}
+		for _, target := range targets {
+			families[endpointRecordType(target, recordType)]++
+		}
 		for _, target := range targets {
+			targetRecordType := endpointRecordType(target, recordType)
 			// If the endpoint for this target does not exist, add a new one
 			if endpoint, ok = currentEndpoints[target]; !ok {
 				endpoint = &v1.Endpoint{
@@ -230,36 +821,124 @@ func (r *DnsReconciler) setEndpointFromTargets(dnsName string, dnsTargets map[st
 			}
 			// Update the endpoint fields
 			endpoint.DNSName = dnsName
-			endpoint.RecordType = "A"
+			endpoint.RecordType = string(targetRecordType)
 			endpoint.Targets = []string{target}
-			endpoint.RecordTTL = 60
-			endpoint.SetProviderSpecific(aws.ProviderSpecificWeight, awsEndpointWeight(len(targets)))
+			endpoint.RecordTTL = ttl
+			setEndpointHost(endpoint, host, target)
+			endpoint.SetProviderSpecific(aws.ProviderSpecificWeight, awsSplitWeight(weight, families[targetRecordType]))
 			newEndpoints = append(newEndpoints, endpoint)
 		}
 	}
-
-	sort.Slice(newEndpoints, func(i, j int) bool {
-		return newEndpoints[i].Targets[0] < newEndpoints[j].Targets[0]
-	})
-
-	dnsRecord.Spec.Endpoints = newEndpoints
+	return newEndpoints
 }
 
+const (
+	// maxHostWeight is the weight of each cluster/ingress when the traffic is split evenly
+	maxHostWeight = 120
+	// maxWeight is the maximum weight of a record set supported by Route53
+	maxWeight = 255
+)
+
 // awsEndpointWeight returns the weight Value for a single AWS record in a set of records where the traffic is split
 // evenly between a number of clusters/ingresses, each splitting traffic evenly to a number of IPs (numIPs)
 //
 // Divides the number of IPs by a known weight allowance for a cluster/ingress, note that this means:
-// * Will always return 1 after a certain number of ips is reached, 60 in the current case (maxWeight / 2)
-// * Will return values that don't add up to the total maxWeight when the number of ingresses is not divisible by numIPs
+// * Will always return 1 after a certain number of ips is reached, 60 in the current case (maxHostWeight / 2)
+// * Will return values that don't add up to the total maxHostWeight when the number of ingresses is not divisible by numIPs
 //
 // The aws weight value must be an integer between 0 and 255.
 // https://docs.aws.amazon.com/Route53/latest/DeveloperGuide/resource-record-sets-values-weighted.html#rrsets-values-weighted-weight
 func awsEndpointWeight(numIPs int) string {
-	maxWeight := 120
-	if numIPs > maxWeight {
-		numIPs = maxWeight
+	return awsSplitWeight(maxHostWeight, numIPs)
+}
+
+// awsSplitWeight returns the weight Value for a single AWS record in a set of records where the given weight of a
+// cluster/ingress is split evenly to a number of IPs (numIPs). The records of a cluster/ingress with a positive weight
+// always have a positive weight, so that they keep receiving traffic.
+func awsSplitWeight(weight, numIPs int) string {
+	return strconv.Itoa(splitWeight(weight, numIPs))
+}
+
+// splitWeight returns the share of the weight of each of n parts, that is at least 1 when the weight is positive.
+func splitWeight(weight, n int) int {
+	if weight <= 0 {
+		return 0
+	}
+	if n > weight {
+		n = weight
+	}
+	return weight / n
+}
+
+// targetWeights returns the weight of the sync target of each target, set by name or key in the weights annotation,
+// the sync targets not listed having a zero weight. It returns nil when the annotation is not set, or along with an
+// error when it is invalid, in which case the traffic is split evenly between the sync targets.
+func (r *DnsReconciler) targetWeights(accessor Interface, targets []dns.Target) (map[string]int, error) {
+	value := metadata.GetAnnotation(accessor, ANNOTATION_DNS_WEIGHTS)
+	if value == "" {
+		return nil, nil
+	}
+	syncTargetWeights, err := parseWeights(value)
+	if err != nil {
+		return nil, err
+	}
+	weights := map[string]int{}
+	for _, target := range targets {
+		weight, ok := syncTargetWeights[target.Cluster]
+		if !ok {
+			weight = syncTargetWeights[r.syncTargetName(target.Cluster)]
+		}
+		weights[target.Cluster] = weight
+	}
+	return weights, nil
+}
+
+// parseWeights returns the weights of the comma separated list of syncTarget=weight pairs, indexed by sync target.
+func parseWeights(value string) (map[string]int, error) {
+	weights := map[string]int{}
+	total := 0
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid weight %q in %s annotation, expected syncTarget=weight", pair, ANNOTATION_DNS_WEIGHTS)
+		}
+		name := strings.TrimSpace(pair[:i])
+		weight, err := strconv.Atoi(strings.TrimSpace(pair[i+1:]))
+		if err != nil || weight < 0 || weight > maxWeight {
+			return nil, fmt.Errorf("invalid weight %q in %s annotation, the weight must be an integer between 0 and %d", pair, ANNOTATION_DNS_WEIGHTS, maxWeight)
+		}
+		if _, ok := weights[name]; ok {
+			return nil, fmt.Errorf("duplicate weight for sync target %q in %s annotation", name, ANNOTATION_DNS_WEIGHTS)
+		}
+		weights[name] = weight
+		total += weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("invalid %s annotation %q, at least one sync target must have a positive weight", ANNOTATION_DNS_WEIGHTS, value)
+	}
+	return weights, nil
+}
+
+// DNSRecordPropagationChanged returns the key of the traffic object of the DNSRecord, and whether the record has been
+// propagated, or is no longer propagated, by the update, so that the load balancer status of the traffic object, that
+// is only published once the record is propagated, is updated.
+func DNSRecordPropagationChanged(oldObj, newObj interface{}) (string, bool) {
+	oldRecord, ok := oldObj.(*v1.DNSRecord)
+	if !ok {
+		return "", false
+	}
+	newRecord, ok := newObj.(*v1.DNSRecord)
+	if !ok {
+		return "", false
+	}
+	key, ok := newRecord.Annotations[ANNOTATION_TRAFFIC_KEY]
+	if !ok {
+		return "", false
 	}
-	return strconv.Itoa(maxWeight / numIPs)
+	return key, dns.IsRecordPropagated(oldRecord) != dns.IsRecordPropagated(newRecord)
 }
 
 // AddHostAnnotations adds generated host annotation to a provided DNS Record CR
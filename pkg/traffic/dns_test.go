@@ -5,18 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	conditionsv1alpha1 "github.com/kcp-dev/kcp/pkg/apis/third_party/conditions/apis/conditions/v1alpha1"
 	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
 	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
 	"github.com/kuadrant/kcp-glbc/pkg/_internal/slice"
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 	"github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
 )
 
 type validatedDNSClient struct {
@@ -89,25 +94,23 @@ func TestDNSReconciler(t *testing.T) {
 		return []dns.RecordWatcher{}
 	}
 
-	commonDNSValidate := func(expectedIPs []string) func(dns *v1.DNSRecord) error {
+	commonDNSValidate := func(expectedTargets []string, expectedRecordType v1.DNSRecordType) func(dns *v1.DNSRecord) error {
 		return func(dns *v1.DNSRecord) error {
 			if dns == nil {
 				return fmt.Errorf("did not expect a nil dns record")
 			}
-			if len(expectedIPs) != len(dns.Spec.Endpoints) {
-				return fmt.Errorf("expected %d endpoints but got %d ", len(expectedIPs), len(dns.Spec.Endpoints))
+			if len(expectedTargets) != len(dns.Spec.Endpoints) {
+				return fmt.Errorf("expected %d endpoints but got %d ", len(expectedTargets), len(dns.Spec.Endpoints))
 			}
 			for _, ep := range dns.Spec.Endpoints {
-				if len(ep.Targets) != len(expectedIPs) {
+				if len(ep.Targets) != 1 {
 					return fmt.Errorf("expected only 1 dns Target but got %d", len(ep.Targets))
 				}
-				if ep.RecordType != "A" {
-					return fmt.Errorf("expected an A record but got %s", ep.RecordType)
+				if ep.RecordType != string(expectedRecordType) {
+					return fmt.Errorf("expected an %s record but got %s", expectedRecordType, ep.RecordType)
 				}
-				for _, ip := range expectedIPs {
-					if !slice.ContainsString(ep.Targets, ip) {
-						return fmt.Errorf("ip %s not in targets ", ip)
-					}
+				if !slice.ContainsString(expectedTargets, ep.Targets[0]) {
+					return fmt.Errorf("target %s not in expected targets %v", ep.Targets[0], expectedTargets)
 				}
 			}
 			return nil
@@ -115,12 +118,14 @@ func TestDNSReconciler(t *testing.T) {
 	}
 
 	cases := []struct {
-		Name           string
-		getDNS         func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error)
-		validateResult func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error
-		ingressStatus  networkingv1.IngressStatus
-		expectedIPs    []string
-		DNSLookup      func(ctx context.Context, host string) ([]dns.HostAddress, error)
+		Name               string
+		getDNS             func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error)
+		validateResult     func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error
+		ingressStatus      networkingv1.IngressStatus
+		expectedTargets    []string
+		expectedRecordType v1.DNSRecordType
+		DNSLookup          func(ctx context.Context, host string) ([]dns.HostAddress, error)
+		capabilities       dns.RoutingCapabilities
 	}{
 		{
 			Name: "test DNSRecord is created when it doesn't exist with no endpoints",
@@ -138,7 +143,8 @@ func TestDNSReconciler(t *testing.T) {
 			DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
 				return nil, fmt.Errorf("DNSLookup should not have been called")
 			},
-			expectedIPs: []string{},
+			expectedTargets:    []string{},
+			expectedRecordType: v1.ARecordType,
 			validateResult: func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error {
 				if status != ReconcileStatusContinue || err != nil {
 					return fmt.Errorf("expected Reconcile status to be %v got %v. Expected err to be nil got %v", ReconcileStatusContinue, status, err)
@@ -190,10 +196,11 @@ func TestDNSReconciler(t *testing.T) {
 			DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
 				return nil, fmt.Errorf("DNSLookup should not have been called")
 			},
-			expectedIPs: []string{"192.168.33.3"},
+			expectedTargets:    []string{"192.168.33.3"},
+			expectedRecordType: v1.ARecordType,
 		},
 		{
-			Name: "test DNSRecord is created with correct values when it a host is returned",
+			Name: "test DNSRecord is created with a CNAME record when a host is returned",
 			getDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
 				return &v1.DNSRecord{
 					ObjectMeta: metav1.ObjectMeta{
@@ -214,11 +221,10 @@ func TestDNSReconciler(t *testing.T) {
 				},
 			},
 			DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
-				return []dns.HostAddress{{
-					IP: net.ParseIP("192.168.33.2"),
-				}}, nil
+				return nil, fmt.Errorf("DNSLookup should not have been called")
 			},
-			expectedIPs: []string{"192.168.33.2"},
+			expectedTargets:    []string{"test.example.com"},
+			expectedRecordType: v1.CNAMERecordType,
 			validateResult: func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error {
 				if status != ReconcileStatusContinue || err != nil {
 					return fmt.Errorf("expected Reconcile status to be %v got %v. Expected err to be nil got %v", ReconcileStatusContinue, status, err)
@@ -232,6 +238,157 @@ func TestDNSReconciler(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			Name: "test DNSRecord is created with AAAA records when an IPv6 address is returned",
+			getDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+				return &v1.DNSRecord{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							ANNOTATION_HCG_HOST: managedHost},
+					},
+					Spec: v1.DNSRecordSpec{
+						Endpoints: []*v1.Endpoint{},
+					},
+				}, nil
+			},
+			ingressStatus: networkingv1.IngressStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{{
+						IP: "2001:db8::1",
+					},
+					},
+				},
+			},
+			DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
+				return nil, fmt.Errorf("DNSLookup should not have been called")
+			},
+			expectedTargets:    []string{"2001:db8::1"},
+			expectedRecordType: v1.AAAARecordType,
+			validateResult: func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error {
+				if status != ReconcileStatusContinue || err != nil {
+					return fmt.Errorf("expected Reconcile status to be %v got %v. Expected err to be nil got %v", ReconcileStatusContinue, status, err)
+				}
+				if dnsClient.updateCalled != 1 {
+					return fmt.Errorf("expected update dns to be called 1 time but was called %d", dnsClient.updateCalled)
+				}
+				return nil
+			},
+		},
+		{
+			Name: "test DNSRecord is created with A records when a host and an IP are returned",
+			getDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+				return &v1.DNSRecord{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							ANNOTATION_HCG_HOST: managedHost},
+					},
+					Spec: v1.DNSRecordSpec{
+						Endpoints: []*v1.Endpoint{},
+					},
+				}, nil
+			},
+			ingressStatus: networkingv1.IngressStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{
+						{Hostname: "test.example.com"},
+						{IP: "192.168.33.3"},
+					},
+				},
+			},
+			DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
+				return []dns.HostAddress{{
+					IP: net.ParseIP("192.168.33.2"),
+				}}, nil
+			},
+			expectedTargets:    []string{"192.168.33.2", "192.168.33.3"},
+			expectedRecordType: v1.ARecordType,
+			validateResult: func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error {
+				if status != ReconcileStatusContinue || err != nil {
+					return fmt.Errorf("expected Reconcile status to be %v got %v. Expected err to be nil got %v", ReconcileStatusContinue, status, err)
+				}
+				if dnsClient.updateCalled != 1 {
+					return fmt.Errorf("expected update dns to be called 1 time but was called %d", dnsClient.updateCalled)
+				}
+				return nil
+			},
+		},
+		{
+			Name: "test DNSRecord is created with CNAME records when several hosts are returned and the providers support weighted CNAME records",
+			getDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+				return &v1.DNSRecord{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							ANNOTATION_HCG_HOST: managedHost},
+					},
+					Spec: v1.DNSRecordSpec{
+						Endpoints: []*v1.Endpoint{},
+					},
+				}, nil
+			},
+			ingressStatus: networkingv1.IngressStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{
+						{Hostname: "test1.example.com"},
+						{Hostname: "test2.example.com"},
+					},
+				},
+			},
+			DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
+				return nil, fmt.Errorf("DNSLookup should not have been called")
+			},
+			capabilities:       dns.RoutingCapabilities{WeightedCNAMEs: true},
+			expectedTargets:    []string{"test1.example.com", "test2.example.com"},
+			expectedRecordType: v1.CNAMERecordType,
+			validateResult: func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error {
+				if status != ReconcileStatusContinue || err != nil {
+					return fmt.Errorf("expected Reconcile status to be %v got %v. Expected err to be nil got %v", ReconcileStatusContinue, status, err)
+				}
+				if dnsClient.updateCalled != 1 {
+					return fmt.Errorf("expected update dns to be called 1 time but was called %d", dnsClient.updateCalled)
+				}
+				return nil
+			},
+		},
+		{
+			Name: "test DNSRecord is created with A records when several hosts are returned and the providers do not support weighted CNAME records",
+			getDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+				return &v1.DNSRecord{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							ANNOTATION_HCG_HOST: managedHost},
+					},
+					Spec: v1.DNSRecordSpec{
+						Endpoints: []*v1.Endpoint{},
+					},
+				}, nil
+			},
+			ingressStatus: networkingv1.IngressStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{
+						{Hostname: "test1.example.com"},
+						{Hostname: "test2.example.com"},
+					},
+				},
+			},
+			DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
+				if host == "test1.example.com" {
+					return []dns.HostAddress{{IP: net.ParseIP("192.168.33.1")}}, nil
+				}
+				return []dns.HostAddress{{IP: net.ParseIP("192.168.33.2")}}, nil
+			},
+			capabilities:       dns.RoutingCapabilities{WeightedCNAMEs: false},
+			expectedTargets:    []string{"192.168.33.1", "192.168.33.2"},
+			expectedRecordType: v1.ARecordType,
+			validateResult: func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error {
+				if status != ReconcileStatusContinue || err != nil {
+					return fmt.Errorf("expected Reconcile status to be %v got %v. Expected err to be nil got %v", ReconcileStatusContinue, status, err)
+				}
+				if dnsClient.updateCalled != 1 {
+					return fmt.Errorf("expected update dns to be called 1 time but was called %d", dnsClient.updateCalled)
+				}
+				return nil
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -240,14 +397,15 @@ func TestDNSReconciler(t *testing.T) {
 				fake := &validatedDNSClient{}
 				rec := &DnsReconciler{
 					GetDNS:           fake.get(tc.getDNS),
-					CreateDNS:        fake.create(commonDNSValidate(tc.expectedIPs)),
-					UpdateDNS:        fake.update(commonDNSValidate(tc.expectedIPs)),
+					CreateDNS:        fake.create(commonDNSValidate(tc.expectedTargets, tc.expectedRecordType)),
+					UpdateDNS:        fake.update(commonDNSValidate(tc.expectedTargets, tc.expectedRecordType)),
 					ListHostWatchers: fakewatcher,
 					Log:              log.New(),
 					DNSLookup:        tc.DNSLookup,
 					WatchHost: func(ctx context.Context, key interface{}, host string) bool {
 						return true
 					},
+					Capabilities: tc.capabilities,
 				}
 				result, err := rec.Reconcile(context.TODO(), acc)
 
@@ -260,6 +418,983 @@ func TestDNSReconciler(t *testing.T) {
 
 }
 
+// lbAddresses are the addresses the load balancer hostnames of the tests resolve to
+var lbAddresses = map[string]string{
+	"lb-2.example.com": "192.168.1.1",
+	"lb-3.example.com": "192.168.2.1",
+}
+
+// dnsReconcilerFixture is an ingress whose DNS record is reconciled, and kept in memory, by a DNS reconciler
+type dnsReconcilerFixture struct {
+	ingress *networkingv1.Ingress
+	// existing is the DNS record returned to the reconciler, updated the last update of the record, if any
+	existing, updated *v1.DNSRecord
+	rec               *DnsReconciler
+}
+
+// newDNSReconcilerFixture returns the fixture of an ingress with the given load balancer addresses per sync target,
+// the hostnames being resolved with lbAddresses
+func newDNSReconcilerFixture(addresses map[string][]string) *dnsReconcilerFixture {
+	f := &dnsReconcilerFixture{
+		ingress: &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "ingress",
+				Annotations: map[string]string{},
+			},
+		},
+		existing: &v1.DNSRecord{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{ANNOTATION_HCG_HOST: "xyz.dev.hcpapps.net"},
+			},
+		},
+	}
+	f.setAddresses(addresses)
+	f.rec = &DnsReconciler{
+		GetDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+			return f.existing.DeepCopy(), nil
+		},
+		UpdateDNS: func(ctx context.Context, dns *v1.DNSRecord) (*v1.DNSRecord, error) {
+			f.existing, f.updated = dns, dns
+			return dns, nil
+		},
+		DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
+			return []dns.HostAddress{{Host: host, IP: net.ParseIP(lbAddresses[host])}}, nil
+		},
+		WatchHost:        func(ctx context.Context, key interface{}, host string) bool { return true },
+		ForgetHost:       func(key interface{}, host string) {},
+		ListHostWatchers: func(key interface{}) []dns.RecordWatcher { return nil },
+		Log:              log.New(),
+	}
+	return f
+}
+
+// setAddresses replaces the load balancer addresses, IPs or hostnames, of the ingress on the sync targets
+func (f *dnsReconcilerFixture) setAddresses(addresses map[string][]string) {
+	for annotation := range f.ingress.Annotations {
+		if strings.HasPrefix(annotation, workload.InternalClusterStatusAnnotationPrefix) {
+			delete(f.ingress.Annotations, annotation)
+		}
+	}
+	for cluster, clusterAddresses := range addresses {
+		status := networkingv1.IngressStatus{}
+		for _, address := range clusterAddresses {
+			if net.ParseIP(address) != nil {
+				status.LoadBalancer.Ingress = append(status.LoadBalancer.Ingress, corev1.LoadBalancerIngress{IP: address})
+			} else {
+				status.LoadBalancer.Ingress = append(status.LoadBalancer.Ingress, corev1.LoadBalancerIngress{Hostname: address})
+			}
+		}
+		raw, _ := json.Marshal(status)
+		f.ingress.Annotations[workload.InternalClusterStatusAnnotationPrefix+cluster] = string(raw)
+	}
+}
+
+// reconcile reconciles the DNS record of the ingress
+func (f *dnsReconcilerFixture) reconcile() error {
+	_, err := f.rec.Reconcile(context.TODO(), NewIngress(f.ingress))
+	return err
+}
+
+// targets returns the targets of the DNS record
+func (f *dnsReconcilerFixture) targets() []string {
+	return endpointTargets(f.existing.Spec.Endpoints).List()
+}
+
+// weights returns the weight of each target of the DNS record
+func (f *dnsReconcilerFixture) weights() map[string]string {
+	weights := map[string]string{}
+	for _, endpoint := range f.existing.Spec.Endpoints {
+		weights[endpoint.Targets[0]], _ = endpoint.GetProviderSpecific(aws.ProviderSpecificWeight)
+	}
+	return weights
+}
+
+func Test_setEndpointFromTargets(t *testing.T) {
+	r := &DnsReconciler{Log: log.New()}
+	record := &v1.DNSRecord{}
+	r.setEndpointFromTargets("app.example.com", map[string][]string{
+		"lb.example.com": {"192.168.0.1", "2001:db8::1", "2001:db8::2"},
+	}, nil, v1.ARecordType, 300, record)
+
+	expected := map[string]struct {
+		recordType v1.DNSRecordType
+		weight     string
+	}{
+		"192.168.0.1": {recordType: v1.ARecordType, weight: "120"},
+		"2001:db8::1": {recordType: v1.AAAARecordType, weight: "60"},
+		"2001:db8::2": {recordType: v1.AAAARecordType, weight: "60"},
+	}
+	if len(record.Spec.Endpoints) != len(expected) {
+		t.Fatalf("expected %d endpoints but got %d", len(expected), len(record.Spec.Endpoints))
+	}
+	for _, endpoint := range record.Spec.Endpoints {
+		want := expected[endpoint.Targets[0]]
+		if endpoint.RecordType != string(want.recordType) {
+			t.Errorf("expected %s record for %s but got %s", want.recordType, endpoint.Targets[0], endpoint.RecordType)
+		}
+		if weight, _ := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight); weight.Value != want.weight {
+			t.Errorf("expected weight %s for %s but got %s", want.weight, endpoint.Targets[0], weight.Value)
+		}
+		if endpoint.RecordTTL != 300 {
+			t.Errorf("expected TTL 300 for %s but got %d", endpoint.Targets[0], endpoint.RecordTTL)
+		}
+	}
+}
+
+func TestDNSReconcilerGeoAware(t *testing.T) {
+	managedHost := "xyz.dev.hcpapps.net"
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ingress",
+			Annotations: map[string]string{},
+		},
+	}
+	statuses := map[string]string{
+		"cluster-eu-1": "192.168.0.1",
+		"cluster-eu-2": "192.168.0.2",
+		"cluster-na":   "192.168.1.1",
+	}
+	for cluster, ip := range statuses {
+		status, _ := json.Marshal(networkingv1.IngressStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: ip}}},
+		})
+		ingress.Annotations[workload.InternalClusterStatusAnnotationPrefix+cluster] = string(status)
+	}
+	continents := map[string]string{
+		"cluster-eu-1": "eu",
+		"cluster-eu-2": "EU",
+		"cluster-na":   "NA",
+	}
+
+	var updated *v1.DNSRecord
+	rec := &DnsReconciler{
+		GetDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+			return &v1.DNSRecord{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ANNOTATION_HCG_HOST: managedHost},
+				},
+			}, nil
+		},
+		UpdateDNS: func(ctx context.Context, dns *v1.DNSRecord) (*v1.DNSRecord, error) {
+			updated = dns
+			return dns, nil
+		},
+		ListHostWatchers: func(key interface{}) []dns.RecordWatcher { return nil },
+		Log:              log.New(),
+		GetSyncTarget: func(key string) (*workload.SyncTarget, error) {
+			return &workload.SyncTarget{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   key,
+					Labels: map[string]string{LABEL_CONTINENT_CODE: continents[key]},
+				},
+			}, nil
+		},
+		Capabilities: dns.RoutingCapabilities{WeightedCNAMEs: true, GeolocationRouting: true},
+	}
+	if _, err := rec.Reconcile(context.TODO(), NewIngress(ingress)); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if updated == nil {
+		t.Fatalf("expected the DNSRecord to be updated")
+	}
+
+	type expectedEndpoint struct {
+		dnsName    string
+		recordType string
+		target     string
+		property   string
+		value      string
+	}
+	expected := []expectedEndpoint{
+		{dnsName: "xyz.eu.dev.hcpapps.net", recordType: "A", target: "192.168.0.1", property: aws.ProviderSpecificWeight, value: "120"},
+		{dnsName: "xyz.eu.dev.hcpapps.net", recordType: "A", target: "192.168.0.2", property: aws.ProviderSpecificWeight, value: "120"},
+		{dnsName: "xyz.na.dev.hcpapps.net", recordType: "A", target: "192.168.1.1", property: aws.ProviderSpecificWeight, value: "120"},
+		{dnsName: managedHost, recordType: "CNAME", target: "xyz.eu.dev.hcpapps.net", property: aws.ProviderSpecificGeolocationContinentCode, value: "EU"},
+		{dnsName: managedHost, recordType: "CNAME", target: "xyz.eu.dev.hcpapps.net", property: aws.ProviderSpecificGeolocationContinentCode, value: "*"},
+		{dnsName: managedHost, recordType: "CNAME", target: "xyz.na.dev.hcpapps.net", property: aws.ProviderSpecificGeolocationContinentCode, value: "NA"},
+	}
+	if len(updated.Spec.Endpoints) != len(expected) {
+		t.Fatalf("expected %d endpoints but got %d: %v", len(expected), len(updated.Spec.Endpoints), updated.Spec.Endpoints)
+	}
+	for i, want := range expected {
+		got := updated.Spec.Endpoints[i]
+		if got.DNSName != want.dnsName || got.RecordType != want.recordType || got.Targets[0] != want.target {
+			t.Errorf("expected %s %s %s but got %s %s %s", want.dnsName, want.recordType, want.target, got.DNSName, got.RecordType, got.Targets[0])
+		}
+		if prop, ok := got.GetProviderSpecificProperty(want.property); !ok || prop.Value != want.value {
+			t.Errorf("expected %s to be %s for %s but got %s", want.property, want.value, got.Targets[0], prop.Value)
+		}
+	}
+
+	// Geo-aware DNS is disabled when a sync target has no continent
+	continents["cluster-na"] = ""
+	if _, err := rec.Reconcile(context.TODO(), NewIngress(ingress)); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for _, endpoint := range updated.Spec.Endpoints {
+		if endpoint.DNSName != managedHost || endpoint.RecordType != "A" {
+			t.Errorf("expected A record for %s but got %s record for %s", managedHost, endpoint.RecordType, endpoint.DNSName)
+		}
+	}
+
+	// Geo-aware DNS is disabled when the DNS providers do not support geolocation routing
+	continents["cluster-na"] = "NA"
+	rec.Capabilities = dns.RoutingCapabilities{}
+	updated = nil
+	if _, err := rec.Reconcile(context.TODO(), NewIngress(ingress)); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if updated == nil || len(updated.Spec.Endpoints) != len(statuses) {
+		t.Fatalf("expected the DNSRecord to be updated with %d endpoints but got %v", len(statuses), updated)
+	}
+	for _, endpoint := range updated.Spec.Endpoints {
+		if endpoint.DNSName != managedHost || endpoint.RecordType != "A" {
+			t.Errorf("expected A record for %s but got %s record for %s", managedHost, endpoint.RecordType, endpoint.DNSName)
+		}
+		if _, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificGeolocationContinentCode); ok {
+			t.Errorf("expected no geolocation for %s", endpoint.Targets[0])
+		}
+	}
+}
+
+func TestDNSReconcilerLatency(t *testing.T) {
+	managedHost := "xyz.dev.hcpapps.net"
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ingress",
+			Annotations: map[string]string{ANNOTATION_DNS_ROUTING_POLICY: RoutingPolicyLatency},
+		},
+	}
+	statuses := map[string]string{
+		"cluster-us": "192.168.0.1",
+		"cluster-eu": "192.168.1.1",
+	}
+	for cluster, ip := range statuses {
+		status, _ := json.Marshal(networkingv1.IngressStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: ip}}},
+		})
+		ingress.Annotations[workload.InternalClusterStatusAnnotationPrefix+cluster] = string(status)
+	}
+	regions := map[string]string{
+		"cluster-us": "us-east-1",
+		"cluster-eu": "EU-West-1",
+	}
+
+	var updated *v1.DNSRecord
+	rec := &DnsReconciler{
+		GetDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+			return &v1.DNSRecord{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ANNOTATION_HCG_HOST: managedHost},
+				},
+			}, nil
+		},
+		UpdateDNS: func(ctx context.Context, dns *v1.DNSRecord) (*v1.DNSRecord, error) {
+			updated = dns
+			return dns, nil
+		},
+		ListHostWatchers: func(key interface{}) []dns.RecordWatcher { return nil },
+		Log:              log.New(),
+		RoutingPolicy:    RoutingPolicyGeo,
+		GetSyncTarget: func(key string) (*workload.SyncTarget, error) {
+			return &workload.SyncTarget{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   key,
+					Labels: map[string]string{LABEL_REGION: regions[key]},
+				},
+			}, nil
+		},
+		Capabilities: dns.RoutingCapabilities{WeightedCNAMEs: true, LatencyRouting: true},
+	}
+	if _, err := rec.Reconcile(context.TODO(), NewIngress(ingress)); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if updated == nil {
+		t.Fatalf("expected the DNSRecord to be updated")
+	}
+
+	type expectedEndpoint struct {
+		dnsName    string
+		recordType string
+		target     string
+		property   string
+		value      string
+	}
+	// The latency records have no default record
+	expected := []expectedEndpoint{
+		{dnsName: "xyz.us-east-1.dev.hcpapps.net", recordType: "A", target: "192.168.0.1", property: aws.ProviderSpecificWeight, value: "120"},
+		{dnsName: "xyz.eu-west-1.dev.hcpapps.net", recordType: "A", target: "192.168.1.1", property: aws.ProviderSpecificWeight, value: "120"},
+		{dnsName: managedHost, recordType: "CNAME", target: "xyz.eu-west-1.dev.hcpapps.net", property: aws.ProviderSpecificRegion, value: "eu-west-1"},
+		{dnsName: managedHost, recordType: "CNAME", target: "xyz.us-east-1.dev.hcpapps.net", property: aws.ProviderSpecificRegion, value: "us-east-1"},
+	}
+	if len(updated.Spec.Endpoints) != len(expected) {
+		t.Fatalf("expected %d endpoints but got %d: %v", len(expected), len(updated.Spec.Endpoints), updated.Spec.Endpoints)
+	}
+	for i, want := range expected {
+		got := updated.Spec.Endpoints[i]
+		if got.DNSName != want.dnsName || got.RecordType != want.recordType || got.Targets[0] != want.target {
+			t.Errorf("expected %s %s %s but got %s %s %s", want.dnsName, want.recordType, want.target, got.DNSName, got.RecordType, got.Targets[0])
+		}
+		if prop, ok := got.GetProviderSpecificProperty(want.property); !ok || prop.Value != want.value {
+			t.Errorf("expected %s to be %s for %s but got %s", want.property, want.value, got.Targets[0], prop.Value)
+		}
+	}
+
+	// The targets are published with weighted records only, when a region is not supported by latency routing, when
+	// the DNS providers do not support latency routing, or with the weighted routing policy
+	weighted := map[string]func(){
+		"unsupported region": func() {
+			regions["cluster-eu"] = "europe"
+		},
+		"unsupported by the DNS providers": func() {
+			rec.Capabilities = dns.RoutingCapabilities{}
+		},
+		"weighted routing policy": func() {
+			ingress.Annotations[ANNOTATION_DNS_ROUTING_POLICY] = RoutingPolicyWeighted
+		},
+	}
+	for name, configure := range weighted {
+		regions["cluster-eu"] = "eu-west-1"
+		rec.Capabilities = dns.RoutingCapabilities{WeightedCNAMEs: true, LatencyRouting: true}
+		ingress.Annotations[ANNOTATION_DNS_ROUTING_POLICY] = RoutingPolicyLatency
+		configure()
+		updated = nil
+		if _, err := rec.Reconcile(context.TODO(), NewIngress(ingress)); err != nil {
+			t.Fatalf("%s: unexpected error %s", name, err)
+		}
+		if updated == nil || len(updated.Spec.Endpoints) != 2 {
+			t.Fatalf("%s: expected the DNSRecord to be updated with 2 endpoints but got %v", name, updated)
+		}
+		for _, endpoint := range updated.Spec.Endpoints {
+			if endpoint.DNSName != managedHost || endpoint.RecordType != "A" {
+				t.Errorf("%s: expected A record for %s but got %s record for %s", name, managedHost, endpoint.RecordType, endpoint.DNSName)
+			}
+		}
+	}
+}
+
+func TestDNSReconcilerFailover(t *testing.T) {
+	managedHost := "xyz.dev.hcpapps.net"
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ingress",
+			Annotations: map[string]string{ANNOTATION_DNS_FAILOVER_PRIMARY: "cluster-1"},
+		},
+	}
+	statuses := map[string]string{
+		"cluster-1": "192.168.0.1",
+		"cluster-2": "192.168.1.2",
+		"cluster-3": "192.168.1.1",
+	}
+	for cluster, ip := range statuses {
+		status, _ := json.Marshal(networkingv1.IngressStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: ip}}},
+		})
+		ingress.Annotations[workload.InternalClusterStatusAnnotationPrefix+cluster] = string(status)
+	}
+
+	var updated *v1.DNSRecord
+	rec := &DnsReconciler{
+		GetDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+			if updated != nil {
+				return updated.DeepCopy(), nil
+			}
+			return &v1.DNSRecord{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ANNOTATION_HCG_HOST: managedHost},
+				},
+			}, nil
+		},
+		UpdateDNS: func(ctx context.Context, dns *v1.DNSRecord) (*v1.DNSRecord, error) {
+			updated = dns
+			return dns, nil
+		},
+		ListHostWatchers: func(key interface{}) []dns.RecordWatcher { return nil },
+		Log:              log.New(),
+		Capabilities:     dns.RoutingCapabilities{WeightedCNAMEs: true, FailoverRouting: true},
+	}
+	if _, err := rec.Reconcile(context.TODO(), NewIngress(ingress)); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if updated == nil {
+		t.Fatalf("expected the DNSRecord to be updated")
+	}
+
+	expected := []struct {
+		setIdentifier string
+		targets       []string
+		role          string
+	}{
+		{setIdentifier: "primary", targets: []string{"192.168.0.1"}, role: aws.FailoverPrimary},
+		{setIdentifier: "secondary", targets: []string{"192.168.1.1", "192.168.1.2"}, role: aws.FailoverSecondary},
+	}
+	if len(updated.Spec.Endpoints) != len(expected) {
+		t.Fatalf("expected %d endpoints but got %d: %v", len(expected), len(updated.Spec.Endpoints), updated.Spec.Endpoints)
+	}
+	for i, want := range expected {
+		got := updated.Spec.Endpoints[i]
+		if got.DNSName != managedHost || got.RecordType != "A" || got.SetIdentifier != want.setIdentifier || !reflect.DeepEqual([]string(got.Targets), want.targets) {
+			t.Errorf("expected %s A %s %v but got %s %s %s %v", managedHost, want.setIdentifier, want.targets, got.DNSName, got.RecordType, got.SetIdentifier, got.Targets)
+		}
+		if role, _ := got.GetProviderSpecific(aws.ProviderSpecificFailover); role != want.role {
+			t.Errorf("expected %s failover role but got %s", want.role, role)
+		}
+		if _, ok := got.GetProviderSpecific(aws.ProviderSpecificWeight); ok {
+			t.Errorf("expected no weight for failover endpoint %s", got.SetIdentifier)
+		}
+	}
+
+	// The failover endpoints keep their health check when the targets change
+	updated.Spec.Endpoints[0].SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "health-check")
+	ingress.Annotations[workload.InternalClusterStatusAnnotationPrefix+"cluster-1"] = `{"loadBalancer":{"ingress":[{"ip":"192.168.0.2"}]}}`
+	if _, err := rec.Reconcile(context.TODO(), NewIngress(ingress)); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	primary := updated.Spec.Endpoints[0]
+	if primary.SetIdentifier != "primary" || primary.Targets[0] != "192.168.0.2" {
+		t.Errorf("expected primary endpoint for 192.168.0.2 but got %s %v", primary.SetIdentifier, primary.Targets)
+	}
+	if id, _ := primary.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); id != "health-check" {
+		t.Errorf("expected the primary endpoint to keep its health check but got %q", id)
+	}
+
+	// The failover is disabled when none of the sync targets is primary, or when the DNS providers do not support it
+	for name, change := range map[string]func(){
+		"no primary sync target": func() { ingress.Annotations[ANNOTATION_DNS_FAILOVER_PRIMARY] = "cluster-4" },
+		"unsupported failover":   func() { rec.Capabilities.FailoverRouting = false },
+	} {
+		ingress.Annotations[ANNOTATION_DNS_FAILOVER_PRIMARY] = "cluster-1"
+		rec.Capabilities.FailoverRouting = true
+		change()
+		if _, err := rec.Reconcile(context.TODO(), NewIngress(ingress)); err != nil {
+			t.Fatalf("%s: unexpected error %s", name, err)
+		}
+		if len(updated.Spec.Endpoints) != 3 {
+			t.Fatalf("%s: expected 3 endpoints but got %d: %v", name, len(updated.Spec.Endpoints), updated.Spec.Endpoints)
+		}
+		for _, endpoint := range updated.Spec.Endpoints {
+			if _, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificFailover); ok || endpoint.SetIdentifier != endpoint.Targets[0] {
+				t.Errorf("%s: expected weighted endpoint but got %s %v", name, endpoint.SetIdentifier, endpoint.ProviderSpecific)
+			}
+		}
+	}
+}
+
+func TestDNSReconcilerWeights(t *testing.T) {
+	f := newDNSReconcilerFixture(map[string][]string{
+		"cluster-1": {"192.168.0.1", "192.168.0.2"},
+		"cluster-2": {"192.168.1.1"},
+		"cluster-3": {"192.168.2.1"},
+	})
+
+	steps := []struct {
+		name        string
+		weights     string
+		wantWeights map[string]string
+		wantError   bool
+	}{
+		{
+			name:        "the sync targets not listed in the annotation receive no traffic",
+			weights:     "cluster-1=90, cluster-2=10",
+			wantWeights: map[string]string{"192.168.0.1": "45", "192.168.0.2": "45", "192.168.1.1": "10", "192.168.2.1": "0"},
+		},
+		{
+			name:        "the traffic is split evenly, and the error reported on the object, when the annotation is invalid",
+			weights:     "cluster-1=90%,cluster-2=10%",
+			wantWeights: map[string]string{"192.168.0.1": "120", "192.168.0.2": "120", "192.168.1.1": "120", "192.168.2.1": "120"},
+			wantError:   true,
+		},
+		{
+			name:        "the error is cleared once the annotation is fixed",
+			weights:     "cluster-1=90,cluster-2=10,cluster-3=0",
+			wantWeights: map[string]string{"192.168.0.1": "45", "192.168.0.2": "45", "192.168.1.1": "10", "192.168.2.1": "0"},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			f.ingress.Annotations[ANNOTATION_DNS_WEIGHTS] = step.weights
+			if err := f.reconcile(); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if got := f.weights(); !reflect.DeepEqual(got, step.wantWeights) {
+				t.Errorf("expected weights %v but got %v", step.wantWeights, got)
+			}
+			if weightsErr, ok := f.ingress.Annotations[ANNOTATION_DNS_WEIGHTS_ERROR]; ok != step.wantError {
+				t.Errorf("expected weights error %t but got %q", step.wantError, weightsErr)
+			}
+		})
+	}
+}
+
+func TestDNSReconcilerRollout(t *testing.T) {
+	cases := []struct {
+		name        string
+		steps       string
+		wantWeights map[string]string
+		// wantStep is the interval of the steps, spread over the default window, the ingress is requeued within
+		wantStep time.Duration
+	}{
+		{
+			name:        "the sync target being deleted keeps most of the traffic during the first step",
+			steps:       "10,50,100",
+			wantWeights: map[string]string{"192.168.0.1": "108", "192.168.1.1": "12"},
+			wantStep:    20 * time.Minute,
+		},
+		{
+			name:        "the traffic is shifted by the first step to the new sync target",
+			steps:       "25,100",
+			wantWeights: map[string]string{"192.168.0.1": "90", "192.168.1.1": "30"},
+			wantStep:    30 * time.Minute,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newDNSReconcilerFixture(map[string][]string{"old": {"192.168.0.1"}, "new": {"192.168.1.1"}})
+			f.ingress.Annotations[ANNOTATION_DNS_ROLLOUT_STEPS] = tc.steps
+			f.ingress.Annotations[workload.InternalClusterDeletionTimestampAnnotationPrefix+"old"] = time.Now().Format(time.RFC3339)
+			var requeuedAfter time.Duration
+			f.rec.EnqueueAfter = func(obj interface{}, duration time.Duration) { requeuedAfter = duration }
+			if err := f.reconcile(); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if got := f.weights(); !reflect.DeepEqual(got, tc.wantWeights) {
+				t.Errorf("expected weights %v but got %v", tc.wantWeights, got)
+			}
+			if requeuedAfter <= 0 || requeuedAfter > tc.wantStep {
+				t.Errorf("expected the ingress to be requeued for the next step but got %s", requeuedAfter)
+			}
+			if f.ingress.Annotations[ANNOTATION_DNS_ROLLOUT_STATUS] == "" {
+				t.Errorf("expected the rollout status to be stored on the ingress")
+			}
+		})
+	}
+}
+
+func TestDNSReconcilerDrain(t *testing.T) {
+	f := newDNSReconcilerFixture(map[string][]string{
+		"key-1": {"192.168.0.1"},
+		"key-2": {"192.168.1.1"},
+		"key-3": {"lb-3.example.com"},
+	})
+	syncTargets := map[string]*workload.SyncTarget{
+		"key-1": {ObjectMeta: metav1.ObjectMeta{Name: "cluster-1"}},
+		"key-2": {ObjectMeta: metav1.ObjectMeta{Name: "cluster-2"}},
+		"key-3": {ObjectMeta: metav1.ObjectMeta{Name: "cluster-3"}},
+	}
+	f.rec.GetSyncTarget = func(key string) (*workload.SyncTarget, error) {
+		return syncTargets[key], nil
+	}
+
+	steps := []struct {
+		name string
+		// drain is the drain annotation of the ingress, and labelled the sync target with the drain label
+		drain       string
+		labelled    string
+		wantTargets []string
+		wantDrained string
+	}{
+		{
+			name:        "the sync targets drained with the label, or by name or key with the annotation, are removed",
+			drain:       "cluster-3",
+			labelled:    "key-2",
+			wantTargets: []string{"192.168.0.1"},
+			wantDrained: "key-2,key-3",
+		},
+		{
+			name:        "the drained sync targets are kept, their hosts being resolved, when all the sync targets are drained",
+			drain:       "key-1, cluster-3",
+			labelled:    "key-2",
+			wantTargets: []string{"192.168.0.1", "192.168.1.1", "192.168.2.1"},
+		},
+		{
+			name:        "the sync targets are added back once they are no longer drained",
+			wantTargets: []string{"192.168.0.1", "192.168.1.1", "192.168.2.1"},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			delete(f.ingress.Annotations, ANNOTATION_DNS_DRAIN)
+			if step.drain != "" {
+				f.ingress.Annotations[ANNOTATION_DNS_DRAIN] = step.drain
+			}
+			for key, syncTarget := range syncTargets {
+				syncTarget.Labels = nil
+				if key == step.labelled {
+					syncTarget.Labels = map[string]string{LABEL_DRAIN: "true"}
+				}
+			}
+			if err := f.reconcile(); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if got := f.targets(); !reflect.DeepEqual(got, step.wantTargets) {
+				t.Errorf("expected targets %v but got %v", step.wantTargets, got)
+			}
+			if drained := f.ingress.Annotations[ANNOTATION_DNS_DRAINED]; drained != step.wantDrained {
+				t.Errorf("expected drained sync targets %q but got %q", step.wantDrained, drained)
+			}
+		})
+	}
+}
+
+func TestDNSReconcilerUnready(t *testing.T) {
+	f := newDNSReconcilerFixture(map[string][]string{
+		"key-1": {"192.168.0.1"},
+		"key-2": {"lb-2.example.com"},
+	})
+	syncTargets := map[string]*workload.SyncTarget{
+		"key-1": {ObjectMeta: metav1.ObjectMeta{Name: "cluster-1"}},
+		"key-2": {ObjectMeta: metav1.ObjectMeta{Name: "cluster-2"}},
+	}
+	f.rec.GetSyncTarget = func(key string) (*workload.SyncTarget, error) {
+		return syncTargets[key], nil
+	}
+
+	steps := []struct {
+		name        string
+		notReady    []string
+		wantTargets []string
+		wantUnready string
+	}{
+		{
+			name:        "the targets of the sync targets that are not ready are removed",
+			notReady:    []string{"key-2"},
+			wantTargets: []string{"192.168.0.1"},
+			wantUnready: "key-2",
+		},
+		{
+			name:        "the targets are kept, their hosts being resolved, when none of the sync targets is ready",
+			notReady:    []string{"key-1", "key-2"},
+			wantTargets: []string{"192.168.0.1", "192.168.1.1"},
+		},
+		{
+			name:        "the targets are added back once their sync targets are ready",
+			wantTargets: []string{"192.168.0.1", "192.168.1.1"},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			for key, syncTarget := range syncTargets {
+				syncTarget.Status.Conditions = nil
+				if slice.ContainsString(step.notReady, key) {
+					syncTarget.Status.Conditions = conditionsv1alpha1.Conditions{{Type: conditionsv1alpha1.ReadyCondition, Status: corev1.ConditionFalse}}
+				}
+			}
+			if err := f.reconcile(); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if got := f.targets(); !reflect.DeepEqual(got, step.wantTargets) {
+				t.Errorf("expected targets %v but got %v", step.wantTargets, got)
+			}
+			if unready := f.ingress.Annotations[ANNOTATION_DNS_UNREADY]; unready != step.wantUnready {
+				t.Errorf("expected unready sync targets %q but got %q", step.wantUnready, unready)
+			}
+		})
+	}
+}
+
+func TestDNSReconcilerSafeguard(t *testing.T) {
+	f := newDNSReconcilerFixture(nil)
+	f.existing.Spec.Endpoints = []*v1.Endpoint{{
+		DNSName:    "xyz.dev.hcpapps.net",
+		Targets:    v1.Targets{"192.168.0.1", "192.168.1.1", "192.168.2.1"},
+		RecordType: string(v1.ARecordType),
+	}}
+	f.rec.EndpointSafeguard = &EndpointSafeguard{}
+
+	steps := []struct {
+		name           string
+		addresses      map[string][]string
+		maxDropPercent int
+		allowDrop      bool
+		wantUpdated    bool
+		wantTargets    []string
+	}{
+		{
+			name:           "dropping two of the three targets at once is refused",
+			addresses:      map[string][]string{"cluster-1": {"192.168.0.1"}},
+			maxDropPercent: 50,
+			wantTargets:    []string{"192.168.0.1", "192.168.1.1", "192.168.2.1"},
+		},
+		{
+			name:           "removing all the targets is refused regardless of the percentage",
+			addresses:      map[string][]string{"cluster-2": {}},
+			maxDropPercent: 100,
+			wantTargets:    []string{"192.168.0.1", "192.168.1.1", "192.168.2.1"},
+		},
+		{
+			name:           "the drop is allowed with the annotation",
+			addresses:      map[string][]string{"cluster-2": {}},
+			maxDropPercent: 100,
+			allowDrop:      true,
+			wantUpdated:    true,
+			wantTargets:    []string{},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			f.setAddresses(step.addresses)
+			f.rec.EndpointSafeguard.MaxDropPercent = step.maxDropPercent
+			if step.allowDrop {
+				f.ingress.Annotations[ANNOTATION_DNS_ALLOW_ENDPOINT_DROP] = "true"
+			}
+			f.updated = nil
+			if err := f.reconcile(); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if updated := f.updated != nil; updated != step.wantUpdated {
+				t.Errorf("expected the DNS record to be updated %t but got %t", step.wantUpdated, updated)
+			}
+			if got := f.targets(); !reflect.DeepEqual(got, step.wantTargets) {
+				t.Errorf("expected targets %v but got %v", step.wantTargets, got)
+			}
+			// The refused updates are reported on the ingress
+			if _, blocked := f.ingress.Annotations[ANNOTATION_DNS_UPDATE_BLOCKED]; blocked == step.wantUpdated {
+				t.Errorf("expected the refused update to be reported %t but got %t", !step.wantUpdated, blocked)
+			}
+		})
+	}
+}
+
+func TestDNSReconcilerLookupFallback(t *testing.T) {
+	f := newDNSReconcilerFixture(map[string][]string{
+		"cluster-1": {"192.168.0.1"},
+		"cluster-2": {"lb-2.example.com"},
+	})
+	timeout := &net.DNSError{Err: "i/o timeout", Name: "lb-2.example.com", IsTimeout: true}
+	notFound := &net.DNSError{Err: "no such host", Name: "lb-2.example.com", IsNotFound: true}
+	lastKnownAddresses := func(host string) ([]dns.HostAddress, time.Duration, bool) {
+		return []dns.HostAddress{{Host: host, IP: net.ParseIP("10.0.0.1")}}, time.Minute, true
+	}
+
+	steps := []struct {
+		name      string
+		lookupErr error
+		lastKnown bool
+		// keepPublished is whether the published addresses can be kept, i.e. the host has not been resolved for less
+		// than the max age of the last known good addresses
+		keepPublished bool
+		// deleting is the sync target being deleted
+		deleting    string
+		wantErr     bool
+		wantUpdated bool
+		wantTargets []string
+	}{
+		{
+			name:        "the last known good addresses are used when the lookup fails transiently",
+			lookupErr:   timeout,
+			lastKnown:   true,
+			wantUpdated: true,
+			wantTargets: []string{"10.0.0.1", "192.168.0.1"},
+		},
+		{
+			name:          "the published addresses of the host are kept without last known good addresses",
+			lookupErr:     timeout,
+			keepPublished: true,
+			wantTargets:   []string{"10.0.0.1", "192.168.0.1"},
+		},
+		{
+			name:          "the host is skipped once it no longer exists, the record being updated with the other targets",
+			lookupErr:     notFound,
+			keepPublished: true,
+			wantErr:       true,
+			wantUpdated:   true,
+			wantTargets:   []string{"192.168.0.1"},
+		},
+		{
+			name:        "the last known good addresses are published again",
+			lookupErr:   timeout,
+			lastKnown:   true,
+			wantUpdated: true,
+			wantTargets: []string{"10.0.0.1", "192.168.0.1"},
+		},
+		{
+			name:        "the published addresses are no longer kept once the host has not been resolved for the max age",
+			lookupErr:   timeout,
+			wantErr:     true,
+			wantUpdated: true,
+			wantTargets: []string{"192.168.0.1"},
+		},
+		{
+			name:        "the host is skipped when it has neither last known good nor published addresses",
+			lookupErr:   timeout,
+			wantErr:     true,
+			wantTargets: []string{"192.168.0.1"},
+		},
+		{
+			name:        "the record is not updated when no target is left",
+			lookupErr:   timeout,
+			deleting:    "cluster-1",
+			wantErr:     true,
+			wantTargets: []string{"192.168.0.1"},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			f.rec.DNSLookup = func(ctx context.Context, host string) ([]dns.HostAddress, error) {
+				return nil, step.lookupErr
+			}
+			f.rec.LastKnownAddresses = nil
+			if step.lastKnown {
+				f.rec.LastKnownAddresses = lastKnownAddresses
+			}
+			f.rec.KeepPublished = func(host string) (time.Duration, bool) {
+				return time.Minute, step.keepPublished
+			}
+			if step.deleting != "" {
+				f.ingress.Annotations[workload.InternalClusterDeletionTimestampAnnotationPrefix+step.deleting] = time.Now().Format(time.RFC3339)
+			}
+			f.updated = nil
+			if err := f.reconcile(); (err != nil) != step.wantErr {
+				t.Errorf("expected error %t but got %v", step.wantErr, err)
+			}
+			if updated := f.updated != nil; updated != step.wantUpdated {
+				t.Errorf("expected the DNS record to be updated %t but got %t", step.wantUpdated, updated)
+			}
+			if got := f.targets(); !reflect.DeepEqual(got, step.wantTargets) {
+				t.Errorf("expected targets %v but got %v", step.wantTargets, got)
+			}
+		})
+	}
+}
+
+func Test_parseWeights(t *testing.T) {
+	weights, err := parseWeights(" cluster-1=90, ,canary = 10,drained=0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]int{"cluster-1": 90, "canary": 10, "drained": 0}
+	if !reflect.DeepEqual(weights, expected) {
+		t.Errorf("expected weights %v but got %v", expected, weights)
+	}
+	for _, value := range []string{"cluster-1", "=10", "cluster-1=ten", "cluster-1=-1", "cluster-1=256", "cluster-1=10,cluster-1=20", "cluster-1=0"} {
+		if _, err := parseWeights(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func Test_awsSplitWeight(t *testing.T) {
+	tests := []struct {
+		weight, numIPs int
+		want           string
+	}{
+		{weight: 90, numIPs: 1, want: "90"},
+		{weight: 90, numIPs: 2, want: "45"},
+		{weight: 10, numIPs: 3, want: "3"},
+		{weight: 10, numIPs: 20, want: "1"},
+		{weight: 0, numIPs: 2, want: "0"},
+	}
+	for _, tt := range tests {
+		if got := awsSplitWeight(tt.weight, tt.numIPs); got != tt.want {
+			t.Errorf("awsSplitWeight(%d, %d) = %v, want %v", tt.weight, tt.numIPs, got, tt.want)
+		}
+	}
+}
+
+func TestDNSReconcilerStatusGatedOnPropagation(t *testing.T) {
+	managedHost := "xyz.dev.hcpapps.net"
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "ingress",
+			Annotations: map[string]string{
+				workload.InternalClusterStatusAnnotationPrefix + "cluster-1": `{"loadBalancer":{"ingress":[{"ip":"192.168.0.1"}]}}`,
+			},
+		},
+	}
+	record := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{ANNOTATION_HCG_HOST: managedHost},
+		},
+		Status: v1.DNSRecordStatus{
+			Zones: []v1.DNSZoneStatus{{
+				DNSZone:  v1.DNSZone{ID: "Z1"},
+				ChangeID: "C1",
+				Conditions: []v1.DNSZoneCondition{
+					{Type: v1.DNSRecordFailedConditionType, Status: "False"},
+					{Type: v1.DNSRecordPropagatedConditionType, Status: "False"},
+				},
+			}},
+		},
+	}
+	rec := &DnsReconciler{
+		GetDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+			return record.DeepCopy(), nil
+		},
+		UpdateDNS: func(ctx context.Context, dns *v1.DNSRecord) (*v1.DNSRecord, error) {
+			return dns, nil
+		},
+		ListHostWatchers: func(key interface{}) []dns.RecordWatcher { return nil },
+		Log:              log.New(),
+	}
+
+	accessor := NewIngress(ingress)
+	if _, err := rec.Reconcile(context.TODO(), accessor); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(accessor.Status.LoadBalancer.Ingress) != 0 {
+		t.Fatalf("expected no load balancer status until the DNS record is propagated, got %v", accessor.Status.LoadBalancer)
+	}
+
+	record.Status.Zones[0].Conditions[1].Status = "True"
+	if _, err := rec.Reconcile(context.TODO(), accessor); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(accessor.Status.LoadBalancer.Ingress) != 1 || accessor.Status.LoadBalancer.Ingress[0].Hostname != managedHost {
+		t.Fatalf("expected the %s load balancer status once the DNS record is propagated, got %v", managedHost, accessor.Status.LoadBalancer)
+	}
+}
+
+func TestDNSRecordPropagationChanged(t *testing.T) {
+	pending := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{ANNOTATION_TRAFFIC_KEY: "root:default|default/ingress"},
+		},
+		Status: v1.DNSRecordStatus{
+			Zones: []v1.DNSZoneStatus{{
+				DNSZone:  v1.DNSZone{ID: "Z1"},
+				ChangeID: "C1",
+				Conditions: []v1.DNSZoneCondition{
+					{Type: v1.DNSRecordFailedConditionType, Status: "False"},
+					{Type: v1.DNSRecordPropagatedConditionType, Status: "False"},
+				},
+			}},
+		},
+	}
+	propagated := pending.DeepCopy()
+	propagated.Status.Zones[0].Conditions[1].Status = "True"
+	untracked := propagated.DeepCopy()
+	delete(untracked.Annotations, ANNOTATION_TRAFFIC_KEY)
+
+	cases := []struct {
+		name     string
+		old, new interface{}
+		changed  bool
+	}{
+		{name: "propagated", old: pending, new: propagated, changed: true},
+		{name: "no longer propagated", old: propagated, new: pending, changed: true},
+		{name: "still pending", old: pending, new: pending.DeepCopy(), changed: false},
+		{name: "no traffic key", old: pending, new: untracked, changed: false},
+		{name: "not a DNSRecord", old: pending, new: &networkingv1.Ingress{}, changed: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, changed := DNSRecordPropagationChanged(tc.old, tc.new)
+			if changed != tc.changed {
+				t.Fatalf("expected changed %t but got %t", tc.changed, changed)
+			}
+			if changed && key != "root:default|default/ingress" {
+				t.Errorf("expected the traffic key of the record but got %q", key)
+			}
+		})
+	}
+}
+
+func Test_groupDNSName(t *testing.T) {
+	if got := groupDNSName("xyz.dev.hcpapps.net", "NA"); got != "xyz.na.dev.hcpapps.net" {
+		t.Errorf("groupDNSName() = %v, want xyz.na.dev.hcpapps.net", got)
+	}
+}
+
 func Test_awsEndpointWeight(t *testing.T) {
 	type args struct {
 		numIPs int
@@ -0,0 +1,30 @@
+package traffic
+
+import (
+	"github.com/kcp-dev/logicalcluster/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+)
+
+// WorkloadDNSRecords returns the DNS records of the traffic objects in the namespace and workspace of the workload,
+// e.g. a Deployment, which the migration of the workload follows, as the workloads are not linked to the traffic
+// objects exposing them.
+func WorkloadDNSRecords(obj metav1.Object, lister kuadrantv1lister.DNSRecordLister) ([]*v1.DNSRecord, error) {
+	if lister == nil {
+		return nil, nil
+	}
+	dnsRecords, err := lister.DNSRecords(obj.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var workloadRecords []*v1.DNSRecord
+	for _, dnsRecord := range dnsRecords {
+		if logicalcluster.From(dnsRecord) == logicalcluster.From(obj) {
+			workloadRecords = append(workloadRecords, dnsRecord)
+		}
+	}
+	return workloadRecords, nil
+}
@@ -0,0 +1,273 @@
+package traffic
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/metadata"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+)
+
+// DefaultRolloutWindow is the default time window the traffic is shifted over, when the rollout steps are set without
+// window
+const DefaultRolloutWindow = time.Hour
+
+const (
+	// RolloutControlPause pauses the rollout at its current step
+	RolloutControlPause = "pause"
+	// RolloutControlAbort aborts the rollout, the traffic being sent back to the sync targets being deleted
+	RolloutControlAbort = "abort"
+)
+
+const (
+	// RolloutPhaseProgressing is the phase of the rollout shifting the traffic to the new sync targets step by step
+	RolloutPhaseProgressing = "Progressing"
+	// RolloutPhasePaused is the phase of the rollout paused at its current step, until the pause control is removed
+	RolloutPhasePaused = "Paused"
+	// RolloutPhaseAborted is the phase of the rollout whose traffic has been sent back to the sync targets being deleted,
+	// that is not resumed
+	RolloutPhaseAborted = "Aborted"
+	// RolloutPhaseCompleted is the phase of the rollout whose traffic is all sent to the new sync targets
+	RolloutPhaseCompleted = "Completed"
+)
+
+// Rollout shifts the traffic progressively from the sync targets being deleted to the new sync targets, in steps, the
+// percentages of the traffic sent to the new sync targets, evenly spread over the time window.
+type Rollout struct {
+	Steps  []int
+	Window time.Duration
+}
+
+// RolloutStatus is the progress of the rollout of a traffic object, stored in its rollout status annotation.
+type RolloutStatus struct {
+	// From are the sync targets the traffic is shifted away from
+	From []string `json:"from"`
+	// To are the sync targets the traffic is shifted to
+	To        []string     `json:"to"`
+	Phase     string       `json:"phase"`
+	StartedAt metav1.Time  `json:"startedAt"`
+	PausedAt  *metav1.Time `json:"pausedAt,omitempty"`
+	// AbortedAt is the time the rollout was aborted, the deletion of the sync targets being deleted being held for the
+	// rollout window from then once the abort control is removed
+	AbortedAt *metav1.Time `json:"abortedAt,omitempty"`
+	// Step is the index of the current step, and Weight the percentage of the traffic sent to the new sync targets
+	Step   int `json:"step"`
+	Weight int `json:"weight"`
+}
+
+// rolloutForObject returns the rollout of the object, set with the rollout annotations, or nil when the rollout steps
+// annotation is not set.
+func rolloutForObject(obj metav1.Object) (*Rollout, error) {
+	value := metadata.GetAnnotation(obj, ANNOTATION_DNS_ROLLOUT_STEPS)
+	if value == "" {
+		return nil, nil
+	}
+	steps, err := parseRolloutSteps(value)
+	if err != nil {
+		return nil, err
+	}
+	rollout := &Rollout{Steps: steps, Window: DefaultRolloutWindow}
+	if value := metadata.GetAnnotation(obj, ANNOTATION_DNS_ROLLOUT_WINDOW); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid %s annotation %q, the window must be a positive duration, e.g. 1h", ANNOTATION_DNS_ROLLOUT_WINDOW, value)
+		}
+		rollout.Window = window
+	}
+	return rollout, nil
+}
+
+// parseRolloutSteps returns the steps of the comma separated list of increasing percentages, e.g. 10,50,100.
+func parseRolloutSteps(value string) ([]int, error) {
+	var steps []int
+	for _, step := range strings.Split(value, ",") {
+		if step = strings.TrimSpace(step); step == "" {
+			continue
+		}
+		percentage, err := strconv.Atoi(strings.TrimSuffix(step, "%"))
+		if err != nil || percentage <= 0 || percentage > 100 {
+			return nil, fmt.Errorf("invalid step %q in %s annotation, the steps must be percentages between 1 and 100", step, ANNOTATION_DNS_ROLLOUT_STEPS)
+		}
+		if len(steps) > 0 && percentage <= steps[len(steps)-1] {
+			return nil, fmt.Errorf("invalid %s annotation %q, the steps must be increasing", ANNOTATION_DNS_ROLLOUT_STEPS, value)
+		}
+		steps = append(steps, percentage)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid %s annotation %q, expected a comma separated list of percentages", ANNOTATION_DNS_ROLLOUT_STEPS, value)
+	}
+	return steps, nil
+}
+
+// rolloutStatusForObject returns the rollout status stored in the annotation of the object, nil when it is not set or
+// cannot be read.
+func rolloutStatusForObject(obj metav1.Object) *RolloutStatus {
+	value := metadata.GetAnnotation(obj, ANNOTATION_DNS_ROLLOUT_STATUS)
+	if value == "" {
+		return nil
+	}
+	status := &RolloutStatus{}
+	if err := json.Unmarshal([]byte(value), status); err != nil {
+		return nil
+	}
+	return status
+}
+
+// progress updates the status of the rollout at the given time, and returns the period after which it moves to its
+// next step, zero when it is not progressing.
+func (r *Rollout) progress(status *RolloutStatus, control string, now time.Time) time.Duration {
+	switch {
+	case status.Phase == RolloutPhaseAborted || status.Phase == RolloutPhaseCompleted:
+		return 0
+	case control == RolloutControlAbort:
+		status.Phase, status.PausedAt, status.Weight = RolloutPhaseAborted, nil, 0
+		status.AbortedAt = &metav1.Time{Time: now}
+		return 0
+	case control == RolloutControlPause:
+		if status.PausedAt == nil {
+			status.PausedAt = &metav1.Time{Time: now}
+		}
+		status.Phase = RolloutPhasePaused
+		return 0
+	case status.PausedAt != nil:
+		// The time the rollout has been paused for is not part of the window
+		status.StartedAt = metav1.Time{Time: status.StartedAt.Add(now.Sub(status.PausedAt.Time))}
+		status.PausedAt = nil
+	}
+
+	elapsed := now.Sub(status.StartedAt.Time)
+	if elapsed >= r.Window {
+		status.Phase, status.Step, status.Weight = RolloutPhaseCompleted, len(r.Steps)-1, 100
+		return 0
+	}
+	interval := r.Window / time.Duration(len(r.Steps))
+	status.Phase = RolloutPhaseProgressing
+	status.Step = int(elapsed / interval)
+	status.Weight = r.Steps[status.Step]
+	return status.StartedAt.Add(interval * time.Duration(status.Step+1)).Sub(now)
+}
+
+// rolloutWeights returns the weight of the sync target of each target while the traffic is shifted from the sync
+// targets being deleted to the other sync targets, and the period after which the rollout moves to its next step. The
+// progress of the rollout is stored in the rollout status annotation of the traffic object. It returns nil when the
+// rollout annotation is not set, when no sync target is being replaced, or once the rollout has completed, in which case
+// the sync targets being deleted are not sent any traffic.
+func rolloutWeights(accessor Interface, targets []dns.Target, now time.Time) (map[string]int, time.Duration, error) {
+	rollout, err := rolloutForObject(accessor)
+	if err != nil || rollout == nil {
+		metadata.RemoveAnnotation(accessor, ANNOTATION_DNS_ROLLOUT_STATUS)
+		return nil, 0, err
+	}
+	from, to := sets.NewString(), sets.NewString()
+	for _, target := range targets {
+		if metadata.HasAnnotation(accessor, workload.InternalClusterDeletionTimestampAnnotationPrefix+target.Cluster) {
+			from.Insert(target.Cluster)
+		} else {
+			to.Insert(target.Cluster)
+		}
+	}
+	if len(from) == 0 || len(to) == 0 {
+		metadata.RemoveAnnotation(accessor, ANNOTATION_DNS_ROLLOUT_STATUS)
+		return nil, 0, nil
+	}
+
+	// A new rollout starts whenever the sync targets being deleted change
+	status := rolloutStatusForObject(accessor)
+	if status == nil || !reflect.DeepEqual(status.From, from.List()) {
+		status = &RolloutStatus{From: from.List(), StartedAt: metav1.Time{Time: now}}
+	}
+	status.To = to.List()
+	next := rollout.progress(status, metadata.GetAnnotation(accessor, ANNOTATION_DNS_ROLLOUT_CONTROL), now)
+	value, err := json.Marshal(status)
+	if err != nil {
+		return nil, 0, err
+	}
+	metadata.AddAnnotation(accessor, ANNOTATION_DNS_ROLLOUT_STATUS, string(value))
+	if status.Phase == RolloutPhaseCompleted {
+		return nil, 0, nil
+	}
+
+	// The weights are a share of the weight of a load balancer when the traffic is split evenly
+	weights := map[string]int{}
+	for _, cluster := range from.List() {
+		weights[cluster] = splitWeight((100-status.Weight)*maxHostWeight/100, from.Len())
+	}
+	for _, cluster := range to.List() {
+		weights[cluster] = splitWeight(status.Weight*maxHostWeight/100, to.Len())
+	}
+	return weights, next, nil
+}
+
+// RolloutHoldUntil returns the time until which the deletion of the object from the sync targets being deleted is
+// held, so that they keep serving the traffic shifted away from them, or the zero time when the object is not rolled
+// out. The deletion is held while the rollout is paused or aborted, and for the rollout window from the time the rollout
+// was aborted once the abort control is removed. The objects without rollout status, e.g. the Deployments annotated with
+// rollout annotations, are held for the rollout window from the time their sync targets started being deleted.
+func RolloutHoldUntil(obj metav1.Object, now time.Time) time.Time {
+	rollout, err := rolloutForObject(obj)
+	if err != nil || rollout == nil {
+		return time.Time{}
+	}
+	control := metadata.GetAnnotation(obj, ANNOTATION_DNS_ROLLOUT_CONTROL)
+	status := rolloutStatusForObject(obj)
+	switch {
+	case control == RolloutControlPause || control == RolloutControlAbort:
+		return now.Add(rollout.Window)
+	case status != nil && status.Phase == RolloutPhaseAborted && status.AbortedAt != nil:
+		return status.AbortedAt.Add(rollout.Window)
+	case status != nil:
+		return status.StartedAt.Add(rollout.Window)
+	}
+	var holdUntil time.Time
+	for key, value := range obj.GetAnnotations() {
+		if !strings.HasPrefix(key, workload.InternalClusterDeletionTimestampAnnotationPrefix) {
+			continue
+		}
+		if deletedAt, err := time.Parse(time.RFC3339, value); err == nil && deletedAt.Add(rollout.Window).After(holdUntil) {
+			holdUntil = deletedAt.Add(rollout.Window)
+		}
+	}
+	return holdUntil
+}
+
+// rolloutAnnotations are the annotations the rollout of a traffic object is configured, controlled and tracked with
+var rolloutAnnotations = []string{
+	ANNOTATION_DNS_ROLLOUT_STEPS,
+	ANNOTATION_DNS_ROLLOUT_WINDOW,
+	ANNOTATION_DNS_ROLLOUT_CONTROL,
+	ANNOTATION_DNS_ROLLOUT_STATUS,
+}
+
+// copyRolloutAnnotations copies the rollout annotations of the traffic object to its DNS record, so that the deletion
+// of the workloads of its namespace is held while the traffic is shifted away from the sync targets being deleted.
+func copyRolloutAnnotations(dnsRecord *v1.DNSRecord, objectMeta metav1.Object) {
+	for _, key := range rolloutAnnotations {
+		if value, ok := objectMeta.GetAnnotations()[key]; ok {
+			metadata.AddAnnotation(dnsRecord, key, value)
+		} else {
+			metadata.RemoveAnnotation(dnsRecord, key)
+		}
+	}
+}
+
+// WorkloadRolloutHoldUntil returns the time until which the deletion of a workload, e.g. a Deployment, from the sync
+// targets being deleted is held, i.e. the latest time the traffic objects of its namespace are held until, as tracked
+// by their DNS records, as the workloads are not linked to the traffic objects exposing them.
+func WorkloadRolloutHoldUntil(obj metav1.Object, dnsRecords []*v1.DNSRecord, now time.Time) time.Time {
+	holdUntil := RolloutHoldUntil(obj, now)
+	for _, dnsRecord := range dnsRecords {
+		if recordHoldUntil := RolloutHoldUntil(dnsRecord, now); recordHoldUntil.After(holdUntil) {
+			holdUntil = recordHoldUntil
+		}
+	}
+	return holdUntil
+}
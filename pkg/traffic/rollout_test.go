@@ -0,0 +1,194 @@
+package traffic
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+)
+
+func TestRolloutWeights(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "ingress",
+			Annotations: map[string]string{
+				ANNOTATION_DNS_ROLLOUT_STEPS:                                      "10,50,100",
+				ANNOTATION_DNS_ROLLOUT_WINDOW:                                     "1h",
+				workload.InternalClusterDeletionTimestampAnnotationPrefix + "old": start.Format(time.RFC3339),
+			},
+		},
+	}
+	accessor := NewIngress(ingress)
+	targets := []dns.Target{{Cluster: "old", Value: "192.168.0.1"}, {Cluster: "new", Value: "192.168.1.1"}}
+
+	steps := []struct {
+		name            string
+		at              time.Duration
+		control         string
+		expectedWeights map[string]int
+		expectedNext    time.Duration
+		expectedPhase   string
+	}{
+		{name: "first step", at: 0, expectedWeights: map[string]int{"old": 108, "new": 12}, expectedNext: 20 * time.Minute, expectedPhase: RolloutPhaseProgressing},
+		{name: "second step", at: 25 * time.Minute, expectedWeights: map[string]int{"old": 60, "new": 60}, expectedNext: 15 * time.Minute, expectedPhase: RolloutPhaseProgressing},
+		{name: "paused", at: 30 * time.Minute, control: RolloutControlPause, expectedWeights: map[string]int{"old": 60, "new": 60}, expectedPhase: RolloutPhasePaused},
+		{name: "still paused", at: 50 * time.Minute, control: RolloutControlPause, expectedWeights: map[string]int{"old": 60, "new": 60}, expectedPhase: RolloutPhasePaused},
+		{name: "resumed", at: 60 * time.Minute, expectedWeights: map[string]int{"old": 60, "new": 60}, expectedNext: 10 * time.Minute, expectedPhase: RolloutPhaseProgressing},
+		{name: "last step", at: 70 * time.Minute, expectedWeights: map[string]int{"old": 0, "new": 120}, expectedNext: 20 * time.Minute, expectedPhase: RolloutPhaseProgressing},
+		{name: "completed", at: 90 * time.Minute, expectedPhase: RolloutPhaseCompleted},
+	}
+	for _, step := range steps {
+		if step.control != "" {
+			ingress.Annotations[ANNOTATION_DNS_ROLLOUT_CONTROL] = step.control
+		} else {
+			delete(ingress.Annotations, ANNOTATION_DNS_ROLLOUT_CONTROL)
+		}
+		weights, next, err := rolloutWeights(accessor, targets, start.Add(step.at))
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", step.name, err)
+		}
+		if !reflect.DeepEqual(weights, step.expectedWeights) {
+			t.Errorf("%s: expected weights %v but got %v", step.name, step.expectedWeights, weights)
+		}
+		if next != step.expectedNext {
+			t.Errorf("%s: expected next step after %s but got %s", step.name, step.expectedNext, next)
+		}
+		if status := rolloutStatusForObject(ingress); status == nil || status.Phase != step.expectedPhase {
+			t.Errorf("%s: expected phase %s but got %v", step.name, step.expectedPhase, status)
+		}
+	}
+
+	// The rollout is over once the sync target being deleted is gone
+	if weights, _, _ := rolloutWeights(accessor, targets[1:], start.Add(2*time.Hour)); weights != nil {
+		t.Errorf("expected no rollout weights but got %v", weights)
+	}
+	if _, ok := ingress.Annotations[ANNOTATION_DNS_ROLLOUT_STATUS]; ok {
+		t.Errorf("expected the rollout status to be removed")
+	}
+}
+
+func TestRolloutWeightsAbort(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "ingress",
+			Annotations: map[string]string{
+				ANNOTATION_DNS_ROLLOUT_STEPS:                                      "50,100",
+				workload.InternalClusterDeletionTimestampAnnotationPrefix + "old": start.Format(time.RFC3339),
+			},
+		},
+	}
+	accessor := NewIngress(ingress)
+	targets := []dns.Target{{Cluster: "old", Value: "192.168.0.1"}, {Cluster: "new", Value: "192.168.1.1"}}
+
+	if weights, _, _ := rolloutWeights(accessor, targets, start); !reflect.DeepEqual(weights, map[string]int{"old": 60, "new": 60}) {
+		t.Errorf("expected the traffic to be split but got %v", weights)
+	}
+	ingress.Annotations[ANNOTATION_DNS_ROLLOUT_CONTROL] = RolloutControlAbort
+	if weights, _, _ := rolloutWeights(accessor, targets, start.Add(time.Minute)); !reflect.DeepEqual(weights, map[string]int{"old": 120, "new": 0}) {
+		t.Errorf("expected the traffic to be sent back to the old sync target but got %v", weights)
+	}
+	if weights, _, _ := rolloutWeights(accessor, targets, start.Add(2*time.Hour)); !reflect.DeepEqual(weights, map[string]int{"old": 120, "new": 0}) {
+		t.Errorf("expected the rollout to stay aborted but got %v", weights)
+	}
+	if status := rolloutStatusForObject(ingress); status == nil || status.AbortedAt == nil || !status.AbortedAt.Equal(&metav1.Time{Time: start.Add(time.Minute)}) {
+		t.Errorf("expected the rollout to be aborted at %s but got %v", start.Add(time.Minute), status)
+	}
+	if holdUntil := RolloutHoldUntil(ingress, start.Add(2*time.Hour)); !holdUntil.Equal(start.Add(3 * time.Hour)) {
+		t.Errorf("expected the deletion to be held while the abort control is set but got %s", holdUntil)
+	}
+
+	// The rollout stays aborted once the abort control is removed, the deletion being held for the window from the abort
+	delete(ingress.Annotations, ANNOTATION_DNS_ROLLOUT_CONTROL)
+	if weights, next, _ := rolloutWeights(accessor, targets, start.Add(3*time.Hour)); !reflect.DeepEqual(weights, map[string]int{"old": 120, "new": 0}) || next != 0 {
+		t.Errorf("expected the rollout to stay aborted but got %v", weights)
+	}
+	if holdUntil := RolloutHoldUntil(ingress, start.Add(3*time.Hour)); !holdUntil.Equal(start.Add(61 * time.Minute)) {
+		t.Errorf("expected the deletion to be held for the window from the abort but got %s", holdUntil)
+	}
+}
+
+func TestRolloutHoldUntil(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	deployment := &metav1.ObjectMeta{
+		Annotations: map[string]string{
+			ANNOTATION_DNS_ROLLOUT_STEPS:                                      "10,50,100",
+			ANNOTATION_DNS_ROLLOUT_WINDOW:                                     "30m",
+			workload.InternalClusterDeletionTimestampAnnotationPrefix + "old": start.Format(time.RFC3339),
+		},
+	}
+	if holdUntil := RolloutHoldUntil(deployment, start.Add(time.Minute)); !holdUntil.Equal(start.Add(30 * time.Minute)) {
+		t.Errorf("expected the deletion to be held for the window but got %s", holdUntil)
+	}
+	deployment.Annotations[ANNOTATION_DNS_ROLLOUT_CONTROL] = RolloutControlPause
+	if holdUntil := RolloutHoldUntil(deployment, start.Add(time.Hour)); !holdUntil.Equal(start.Add(90 * time.Minute)) {
+		t.Errorf("expected the deletion to be held while paused but got %s", holdUntil)
+	}
+	if holdUntil := RolloutHoldUntil(&metav1.ObjectMeta{}, start); !holdUntil.IsZero() {
+		t.Errorf("expected no hold without rollout but got %s", holdUntil)
+	}
+}
+
+func TestWorkloadRolloutHoldUntil(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "ingress",
+			Annotations: map[string]string{
+				ANNOTATION_DNS_ROLLOUT_STEPS:                                      "50,100",
+				ANNOTATION_DNS_ROLLOUT_WINDOW:                                     "1h",
+				workload.InternalClusterDeletionTimestampAnnotationPrefix + "old": start.Format(time.RFC3339),
+			},
+		},
+	}
+	targets := []dns.Target{{Cluster: "old", Value: "192.168.0.1"}, {Cluster: "new", Value: "192.168.1.1"}}
+	if _, _, err := rolloutWeights(NewIngress(ingress), targets, start); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dnsRecord := &v1.DNSRecord{}
+	copyRolloutAnnotations(dnsRecord, ingress)
+
+	// The Deployment is held along with the Ingress of its namespace, without being annotated itself
+	deployment := &metav1.ObjectMeta{
+		Annotations: map[string]string{
+			workload.InternalClusterDeletionTimestampAnnotationPrefix + "old": start.Format(time.RFC3339),
+		},
+	}
+	if holdUntil := WorkloadRolloutHoldUntil(deployment, []*v1.DNSRecord{dnsRecord}, start.Add(time.Minute)); !holdUntil.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected the deletion to be held for the rollout of the ingress but got %s", holdUntil)
+	}
+	ingress.Annotations[ANNOTATION_DNS_ROLLOUT_CONTROL] = RolloutControlPause
+	copyRolloutAnnotations(dnsRecord, ingress)
+	if holdUntil := WorkloadRolloutHoldUntil(deployment, []*v1.DNSRecord{dnsRecord}, start.Add(2*time.Hour)); !holdUntil.Equal(start.Add(3 * time.Hour)) {
+		t.Errorf("expected the deletion to be held while the rollout of the ingress is paused but got %s", holdUntil)
+	}
+
+	// The rollout annotations removed from the Ingress are removed from its DNS record
+	ingress.Annotations = map[string]string{}
+	copyRolloutAnnotations(dnsRecord, ingress)
+	if holdUntil := WorkloadRolloutHoldUntil(deployment, []*v1.DNSRecord{dnsRecord}, start); !holdUntil.IsZero() {
+		t.Errorf("expected no hold without rollout but got %s", holdUntil)
+	}
+}
+
+func Test_parseRolloutSteps(t *testing.T) {
+	steps, err := parseRolloutSteps("10%, 50,100")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(steps, []int{10, 50, 100}) {
+		t.Errorf("expected steps [10 50 100] but got %v", steps)
+	}
+	for _, value := range []string{",", "0,100", "50,10", "10,101", "ten"} {
+		if _, err := parseRolloutSteps(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
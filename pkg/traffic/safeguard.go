@@ -0,0 +1,59 @@
+package traffic
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// DefaultMaxEndpointDropPercent is the default percentage of the targets of a DNS record an update can remove at once,
+// so that only the updates removing all the targets are refused
+const DefaultMaxEndpointDropPercent = 100
+
+// EndpointSafeguard refuses the updates of the DNS records that would remove all their targets, or more than the given
+// percentage of them at once, e.g. when the status of the traffic object has been badly synced, as the DNS providers
+// would then delete the records and take the hosts offline.
+type EndpointSafeguard struct {
+	// MaxDropPercent is the percentage of the targets an update can remove at once, the updates removing all the
+	// targets are refused regardless
+	MaxDropPercent int
+}
+
+// Validate returns an error when the percentage of the safeguard is out of bounds.
+func (s *EndpointSafeguard) Validate() error {
+	if s.MaxDropPercent < 0 || s.MaxDropPercent > 100 {
+		return fmt.Errorf("the maximum percentage of DNS record targets dropped at once must be between 0 and 100, got %d", s.MaxDropPercent)
+	}
+	return nil
+}
+
+// Check returns an error when updating the DNS record from the existing endpoints to the updated ones would remove all
+// its targets, or drop more than the maximum percentage of them. The targets are compared by number, so that the
+// targets replaced by others, e.g. during a migration, are not a drop. A nil safeguard allows any update.
+func (s *EndpointSafeguard) Check(existing, updated []*v1.Endpoint) error {
+	if s == nil {
+		return nil
+	}
+	before, after := endpointTargets(existing).Len(), endpointTargets(updated).Len()
+	if before == 0 || after >= before {
+		return nil
+	}
+	if after == 0 {
+		return fmt.Errorf("the update would remove all the %d targets of the DNS record", before)
+	}
+	if dropped := (before - after) * 100 / before; dropped > s.MaxDropPercent {
+		return fmt.Errorf("the update would drop %d%% of the targets of the DNS record, from %d to %d, more than the maximum of %d%%", dropped, before, after, s.MaxDropPercent)
+	}
+	return nil
+}
+
+// endpointTargets returns the distinct targets of the endpoints
+func endpointTargets(endpoints []*v1.Endpoint) sets.String {
+	targets := sets.NewString()
+	for _, endpoint := range endpoints {
+		targets.Insert(endpoint.Targets...)
+	}
+	return targets
+}
@@ -0,0 +1,39 @@
+package traffic
+
+import (
+	"testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+func TestEndpointSafeguardCheck(t *testing.T) {
+	endpoints := func(targets ...string) []*v1.Endpoint {
+		var endpoints []*v1.Endpoint
+		for _, target := range targets {
+			endpoints = append(endpoints, &v1.Endpoint{DNSName: "xyz.dev.hcpapps.net", Targets: v1.Targets{target}})
+		}
+		return endpoints
+	}
+	tests := []struct {
+		name      string
+		safeguard *EndpointSafeguard
+		existing  []*v1.Endpoint
+		updated   []*v1.Endpoint
+		wantErr   bool
+	}{
+		{name: "no safeguard", existing: endpoints("a", "b"), updated: nil},
+		{name: "new record", safeguard: &EndpointSafeguard{MaxDropPercent: 0}, existing: nil, updated: endpoints("a")},
+		{name: "all targets removed", safeguard: &EndpointSafeguard{MaxDropPercent: 100}, existing: endpoints("a", "b"), updated: nil, wantErr: true},
+		{name: "targets replaced", safeguard: &EndpointSafeguard{MaxDropPercent: 0}, existing: endpoints("a", "b"), updated: endpoints("c", "d")},
+		{name: "drop within bound", safeguard: &EndpointSafeguard{MaxDropPercent: 50}, existing: endpoints("a", "b"), updated: endpoints("a")},
+		{name: "drop beyond bound", safeguard: &EndpointSafeguard{MaxDropPercent: 50}, existing: endpoints("a", "b", "c"), updated: endpoints("a"), wantErr: true},
+		{name: "targets split between endpoints", safeguard: &EndpointSafeguard{MaxDropPercent: 0}, existing: []*v1.Endpoint{{Targets: v1.Targets{"a", "b"}}}, updated: endpoints("a", "b")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.safeguard.Check(tt.existing, tt.updated); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
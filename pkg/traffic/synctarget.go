@@ -0,0 +1,100 @@
+package traffic
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	conditionsv1alpha1 "github.com/kcp-dev/kcp/pkg/apis/third_party/conditions/apis/conditions/v1alpha1"
+	"github.com/kcp-dev/kcp/pkg/apis/third_party/conditions/util/conditions"
+	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
+	workloadlisters "github.com/kcp-dev/kcp/pkg/client/listers/workload/v1alpha1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// continentCodes are the continent codes supported by geolocation routing policies
+var continentCodes = map[string]struct{}{
+	"AF": {}, "AN": {}, "AS": {}, "EU": {}, "NA": {}, "OC": {}, "SA": {},
+}
+
+// NewSyncTargetGetter returns a function that gets the SyncTargets from the lister by sync target key, which is how
+// the traffic objects refer to the sync targets they are scheduled to. It returns nil when the lister is nil, i.e.
+// when SyncTargets are not watched.
+func NewSyncTargetGetter(lister workloadlisters.SyncTargetLister) func(key string) (*workload.SyncTarget, error) {
+	if lister == nil {
+		return nil
+	}
+	return func(key string) (*workload.SyncTarget, error) {
+		syncTargets, err := lister.List(labels.SelectorFromSet(labels.Set{workload.InternalSyncTargetKeyLabel: key}))
+		if err != nil {
+			return nil, err
+		}
+		if len(syncTargets) == 0 {
+			return nil, k8errors.NewNotFound(workload.Resource("synctargets"), key)
+		}
+		return syncTargets[0], nil
+	}
+}
+
+// syncTargetContinent returns the continent code set on the SyncTarget with the continent code label, or an empty
+// string if the label is missing or is not a valid continent code.
+func syncTargetContinent(syncTarget *workload.SyncTarget) string {
+	continent := strings.ToUpper(syncTarget.GetLabels()[LABEL_CONTINENT_CODE])
+	if _, ok := continentCodes[continent]; !ok {
+		return ""
+	}
+	return continent
+}
+
+// syncTargetRegion returns the region set on the SyncTarget with the region label, or an empty string if the label is
+// missing or is not a region supported by latency routing policies.
+func syncTargetRegion(syncTarget *workload.SyncTarget) string {
+	region := strings.ToLower(syncTarget.GetLabels()[LABEL_REGION])
+	if !aws.IsLatencyRegion(region) {
+		return ""
+	}
+	return region
+}
+
+// syncTargetDrained returns whether the SyncTarget is drained from the DNS records with the drain label.
+func syncTargetDrained(syncTarget *workload.SyncTarget) bool {
+	return syncTarget.GetLabels()[LABEL_DRAIN] == "true"
+}
+
+// syncTargetReady returns whether the SyncTarget is ready, i.e. its Ready condition is true, which is false when its
+// syncer has missed its heartbeat. The SyncTargets that have not reported their readiness yet are considered ready.
+func syncTargetReady(syncTarget *workload.SyncTarget) bool {
+	ready := conditions.Get(syncTarget, conditionsv1alpha1.ReadyCondition)
+	return ready == nil || ready.Status == corev1.ConditionTrue
+}
+
+// SyncTargetChanged returns the key of the SyncTarget, and whether it has been drained or undrained, has become ready
+// or unready, or has changed continent or region, by the update, so that the traffic objects scheduled to it are
+// requeued.
+func SyncTargetChanged(oldObj, newObj interface{}) (string, bool) {
+	oldSyncTarget, ok := oldObj.(*workload.SyncTarget)
+	if !ok {
+		return "", false
+	}
+	newSyncTarget, ok := newObj.(*workload.SyncTarget)
+	if !ok {
+		return "", false
+	}
+	changed := syncTargetDrained(oldSyncTarget) != syncTargetDrained(newSyncTarget) ||
+		syncTargetReady(oldSyncTarget) != syncTargetReady(newSyncTarget) ||
+		syncTargetContinent(oldSyncTarget) != syncTargetContinent(newSyncTarget) ||
+		syncTargetRegion(oldSyncTarget) != syncTargetRegion(newSyncTarget)
+	return newSyncTarget.GetLabels()[workload.InternalSyncTargetKeyLabel], changed
+}
+
+// SyncTargetSelector returns the selector of the objects scheduled to the SyncTarget with the given key.
+func SyncTargetSelector(key string) (labels.Selector, error) {
+	requirement, err := labels.NewRequirement(workload.ClusterResourceStateLabelPrefix+key, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+	return labels.NewSelector().Add(*requirement), nil
+}
@@ -0,0 +1,113 @@
+package traffic
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	conditionsv1alpha1 "github.com/kcp-dev/kcp/pkg/apis/third_party/conditions/apis/conditions/v1alpha1"
+	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
+	workloadlisters "github.com/kcp-dev/kcp/pkg/client/listers/workload/v1alpha1"
+)
+
+func TestNewSyncTargetGetter(t *testing.T) {
+	if NewSyncTargetGetter(nil) != nil {
+		t.Fatalf("expected no getter when SyncTargets are not watched")
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	err := indexer.Add(&workload.SyncTarget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+			Labels: map[string]string{
+				workload.InternalSyncTargetKeyLabel: "key",
+				LABEL_CONTINENT_CODE:                "eu",
+				LABEL_REGION:                        "EU-West-1",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	getSyncTarget := NewSyncTargetGetter(workloadlisters.NewSyncTargetLister(indexer))
+
+	syncTarget, err := getSyncTarget("key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if syncTarget.Name != "cluster" {
+		t.Errorf("expected SyncTarget cluster but got %s", syncTarget.Name)
+	}
+	if continent := syncTargetContinent(syncTarget); continent != "EU" {
+		t.Errorf("expected continent EU but got %s", continent)
+	}
+	if region := syncTargetRegion(syncTarget); region != "eu-west-1" {
+		t.Errorf("expected region eu-west-1 but got %s", region)
+	}
+	invalid := syncTarget.DeepCopy()
+	invalid.Labels[LABEL_CONTINENT_CODE] = "Europe"
+	invalid.Labels[LABEL_REGION] = "europe"
+	if continent := syncTargetContinent(invalid); continent != "" {
+		t.Errorf("expected no continent but got %s", continent)
+	}
+	if region := syncTargetRegion(invalid); region != "" {
+		t.Errorf("expected no region but got %s", region)
+	}
+
+	if _, err := getSyncTarget("other"); !k8errors.IsNotFound(err) {
+		t.Errorf("expected not found error but got %v", err)
+	}
+}
+
+func TestSyncTargetChanged(t *testing.T) {
+	syncTarget := &workload.SyncTarget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "cluster",
+			Labels: map[string]string{workload.InternalSyncTargetKeyLabel: "key"},
+		},
+	}
+	drained := syncTarget.DeepCopy()
+	drained.Labels[LABEL_DRAIN] = "true"
+
+	if key, changed := SyncTargetChanged(syncTarget, drained); !changed || key != "key" {
+		t.Errorf("expected drain change of SyncTarget key but got %q %t", key, changed)
+	}
+	if _, changed := SyncTargetChanged(drained, drained.DeepCopy()); changed {
+		t.Errorf("expected no drain change")
+	}
+
+	unready := syncTarget.DeepCopy()
+	unready.Status.Conditions = conditionsv1alpha1.Conditions{{Type: conditionsv1alpha1.ReadyCondition, Status: corev1.ConditionFalse}}
+	if !syncTargetReady(syncTarget) || syncTargetReady(unready) {
+		t.Errorf("expected SyncTarget without Ready condition to be ready, and with false Ready condition to be unready")
+	}
+	if _, changed := SyncTargetChanged(syncTarget, unready); !changed {
+		t.Errorf("expected readiness change")
+	}
+
+	relocated := syncTarget.DeepCopy()
+	relocated.Labels[LABEL_CONTINENT_CODE] = "EU"
+	if _, changed := SyncTargetChanged(syncTarget, relocated); !changed {
+		t.Errorf("expected continent change")
+	}
+	relocated = syncTarget.DeepCopy()
+	relocated.Labels[LABEL_REGION] = "eu-west-1"
+	if _, changed := SyncTargetChanged(syncTarget, relocated); !changed {
+		t.Errorf("expected region change")
+	}
+
+	selector, err := SyncTargetSelector("key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !selector.Matches(labels.Set{workload.ClusterResourceStateLabelPrefix + "key": "Sync"}) {
+		t.Errorf("expected the selector to match the objects scheduled to the SyncTarget")
+	}
+	if selector.Matches(labels.Set{workload.ClusterResourceStateLabelPrefix + "other": "Sync"}) {
+		t.Errorf("expected the selector not to match the objects scheduled to other SyncTargets")
+	}
+}
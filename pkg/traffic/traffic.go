@@ -33,9 +33,41 @@ const (
 	ANNOTATION_HCG_CUSTOM_HOST_REPLACED = "kuadrant.dev/custom-hosts-status.removed"
 	ANNOTATION_PENDING_CUSTOM_HOSTS     = "kuadrant.dev/pendingCustomHosts"
 	LABEL_HAS_PENDING_HOSTS             = "kuadrant.dev/hasPendingCustomHosts"
+	LABEL_CONTINENT_CODE                = "kuadrant.dev/continent-code"
+	LABEL_REGION                        = "kuadrant.dev/region"
+	LABEL_DRAIN                         = "kuadrant.dev/drain"
+	ANNOTATION_DNS_ROUTING_POLICY       = "kuadrant.dev/dns-routing-policy"
+	ANNOTATION_DNS_FAILOVER_PRIMARY     = "kuadrant.dev/dns-failover-primary"
+	ANNOTATION_DNS_RECORD_TTL           = "kuadrant.dev/dns-record-ttl"
+	ANNOTATION_DNS_RECORD_TTL_PHASE     = "kuadrant.dev/dns-record-ttl-phase"
+	ANNOTATION_DNS_RECORD_EFFECTIVE_TTL = "kuadrant.dev/dns-record-effective-ttl"
+	ANNOTATION_DNS_TARGETS              = "kuadrant.dev/dns-targets"
+	ANNOTATION_DNS_TARGETS_CHANGED_AT   = "kuadrant.dev/dns-targets-changed-at"
+	ANNOTATION_DNS_WEIGHTS              = "kuadrant.dev/dns-weights"
+	ANNOTATION_DNS_WEIGHTS_ERROR        = "kuadrant.dev/dns-weights-error"
+	ANNOTATION_DNS_ROLLOUT_STEPS        = "kuadrant.dev/dns-rollout-steps"
+	ANNOTATION_DNS_ROLLOUT_WINDOW       = "kuadrant.dev/dns-rollout-window"
+	ANNOTATION_DNS_ROLLOUT_CONTROL      = "kuadrant.dev/dns-rollout-control"
+	ANNOTATION_DNS_ROLLOUT_STATUS       = "kuadrant.dev/dns-rollout-status"
+	ANNOTATION_DNS_ROLLOUT_ERROR        = "kuadrant.dev/dns-rollout-error"
+	ANNOTATION_DNS_DRAIN                = "kuadrant.dev/dns-drain"
+	ANNOTATION_DNS_DRAINED              = "kuadrant.dev/dns-drained"
+	ANNOTATION_DNS_UNREADY              = "kuadrant.dev/dns-unready"
+	ANNOTATION_DNS_UPDATE_BLOCKED       = "kuadrant.dev/dns-update-blocked"
+	ANNOTATION_DNS_ALLOW_ENDPOINT_DROP  = "kuadrant.dev/dns-allow-endpoint-drop"
 	FINALIZER_CASCADE_CLEANUP           = "kuadrant.dev/cascade-cleanup"
 )
 
+const (
+	// RoutingPolicyGeo routes the traffic to the continent of the clients when the sync targets have continents
+	RoutingPolicyGeo = "geo"
+	// RoutingPolicyLatency routes the traffic to the region with the lowest latency when the sync targets have regions
+	RoutingPolicyLatency = "latency"
+	// RoutingPolicyWeighted splits the traffic evenly between the sync targets, or with the weights of the weights
+	// annotation
+	RoutingPolicyWeighted = "weighted"
+)
+
 type patch struct {
 	OP    string      `json:"op"`
 	Path  string      `json:"path"`
@@ -0,0 +1,218 @@
+package traffic
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kcp-dev/logicalcluster/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/metadata"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+)
+
+const (
+	// DefaultRecordTTL is the default TTL of the DNS records, in seconds
+	DefaultRecordTTL v1.TTL = 60
+	// DefaultMinRecordTTL and DefaultMaxRecordTTL are the default bounds of the TTL of the DNS records, in seconds
+	DefaultMinRecordTTL v1.TTL = 10
+	DefaultMaxRecordTTL v1.TTL = 3600
+	// DefaultMigrationRecordTTL is the default TTL of the DNS records while their targets are migrated, in seconds
+	DefaultMigrationRecordTTL v1.TTL = 10
+	// DefaultTTLStablePeriod is the default period the targets of the DNS records must be stable for, before the TTL is
+	// stepped back up
+	DefaultTTLStablePeriod = 10 * time.Minute
+)
+
+const (
+	// TTLPhaseStable is the TTL phase of the DNS records whose targets are stable, that have the effective TTL
+	TTLPhaseStable = "stable"
+	// TTLPhaseMigrating is the TTL phase of the DNS records whose targets are being migrated, or have changed recently,
+	// that have the migration TTL
+	TTLPhaseMigrating = "migrating"
+)
+
+// TTLPolicy is the policy of the TTL of the DNS records of the traffic objects. The TTL is set per object with the TTL
+// annotation, or per workspace, within the bounds set by the operator.
+type TTLPolicy struct {
+	// Default is the TTL of the records of the objects without TTL annotation, in the workspaces without TTL
+	Default v1.TTL
+	// Workspaces are the TTLs of the records of the objects without TTL annotation, indexed by logical cluster
+	Workspaces map[logicalcluster.Name]v1.TTL
+	// Min and Max bound the TTL of the records, a zero bound is not enforced
+	Min v1.TTL
+	Max v1.TTL
+	// Migration is the TTL of the records while any of their targets is being deleted, or their targets have changed
+	// within the stable period, so that the clients follow the migrations quickly. Zero disables the adaptive TTL.
+	Migration v1.TTL
+	// StablePeriod is the period the targets must be stable for, before the TTL is stepped back up
+	StablePeriod time.Duration
+}
+
+// Validate returns an error when the bounds of the policy are inconsistent, or when the default TTL is not positive.
+func (p *TTLPolicy) Validate() error {
+	if p.Min < 0 || p.Max < 0 {
+		return fmt.Errorf("DNS record TTLs cannot be negative")
+	}
+	if p.Default < 1 {
+		return fmt.Errorf("default DNS record TTL %d must be at least 1 second", p.Default)
+	}
+	if p.Max > 0 && p.Min > p.Max {
+		return fmt.Errorf("minimum DNS record TTL %d is greater than the maximum DNS record TTL %d", p.Min, p.Max)
+	}
+	if p.Migration < 0 || p.StablePeriod < 0 {
+		return fmt.Errorf("the DNS record migration TTL and stable period cannot be negative")
+	}
+	return nil
+}
+
+// RecordTTL returns the effective TTL of the DNS records of the object, i.e. the TTL of its annotation, of its workspace,
+// or the default TTL, within the bounds of the policy. The TTL of the workspace, or the default TTL, is returned along
+// with an error when the annotation is invalid. The default TTL is returned for a nil policy.
+func (p *TTLPolicy) RecordTTL(obj metav1.Object) (v1.TTL, error) {
+	if p == nil {
+		return DefaultRecordTTL, nil
+	}
+	ttl := p.Default
+	if workspaceTTL, ok := p.Workspaces[logicalcluster.From(obj)]; ok {
+		ttl = workspaceTTL
+	}
+	var err error
+	if value := metadata.GetAnnotation(obj, ANNOTATION_DNS_RECORD_TTL); value != "" {
+		annotationTTL, parseErr := strconv.ParseInt(value, 10, 64)
+		if parseErr != nil || annotationTTL < 1 {
+			err = fmt.Errorf("invalid %s annotation %q, the TTL must be a positive number of seconds", ANNOTATION_DNS_RECORD_TTL, value)
+		} else {
+			ttl = v1.TTL(annotationTTL)
+		}
+	}
+	return p.bound(ttl), err
+}
+
+// bound returns the TTL within the bounds of the policy
+func (p *TTLPolicy) bound(ttl v1.TTL) v1.TTL {
+	if p.Min > 0 && ttl < p.Min {
+		ttl = p.Min
+	}
+	if p.Max > 0 && ttl > p.Max {
+		ttl = p.Max
+	}
+	return ttl
+}
+
+// AdaptRecordTTL returns the TTL of the DNS record, lowered to the migration TTL while any of its targets is being
+// deleted, or its targets have changed within the stable period, and the period after which it is stepped back up to
+// the effective TTL, zero when it is not lowered. The sync targets of the record, the time they last changed, and the
+// TTL phase, are recorded in the annotations of the record. The targets being deleted refresh the time of the last
+// change once it is older than the stable period, so that the TTL is lowered for the whole migration.
+func (p *TTLPolicy) AdaptRecordTTL(dnsRecord *v1.DNSRecord, ttl v1.TTL, targets []dns.Target, deleting bool, now time.Time) (v1.TTL, time.Duration) {
+	if p == nil || p.Migration == 0 {
+		metadata.RemoveAnnotation(dnsRecord, ANNOTATION_DNS_RECORD_TTL_PHASE)
+		metadata.RemoveAnnotation(dnsRecord, ANNOTATION_DNS_TARGETS)
+		metadata.RemoveAnnotation(dnsRecord, ANNOTATION_DNS_TARGETS_CHANGED_AT)
+		return ttl, 0
+	}
+
+	clusters := targetClusters(targets)
+	previous, tracked := dnsRecord.GetAnnotations()[ANNOTATION_DNS_TARGETS]
+	metadata.AddAnnotation(dnsRecord, ANNOTATION_DNS_TARGETS, clusters)
+
+	changedAt, err := time.Parse(time.RFC3339, metadata.GetAnnotation(dnsRecord, ANNOTATION_DNS_TARGETS_CHANGED_AT))
+	recent := err == nil && now.Sub(changedAt) < p.StablePeriod
+	// The targets of a new record are not a change
+	if p.StablePeriod > 0 && ((tracked && previous != clusters) || (deleting && !recent)) {
+		changedAt, recent = now, true
+		metadata.AddAnnotation(dnsRecord, ANNOTATION_DNS_TARGETS_CHANGED_AT, changedAt.UTC().Format(time.RFC3339))
+	}
+
+	if !recent && !deleting {
+		metadata.AddAnnotation(dnsRecord, ANNOTATION_DNS_RECORD_TTL_PHASE, TTLPhaseStable)
+		return ttl, 0
+	}
+	metadata.AddAnnotation(dnsRecord, ANNOTATION_DNS_RECORD_TTL_PHASE, TTLPhaseMigrating)
+	if migrationTTL := p.bound(p.Migration); migrationTTL < ttl {
+		ttl = migrationTTL
+	}
+	if !recent {
+		return ttl, 0
+	}
+	return ttl, changedAt.Add(p.StablePeriod).Sub(now)
+}
+
+// targetClusters returns the sorted, comma separated, list of the sync targets of the targets
+func targetClusters(targets []dns.Target) string {
+	clusters := map[string]bool{}
+	for _, target := range targets {
+		clusters[target.Cluster] = true
+	}
+	sorted := make([]string, 0, len(clusters))
+	for cluster := range clusters {
+		sorted = append(sorted, cluster)
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// Duration returns the effective TTL of the DNS records of the object, as a duration.
+func (p *TTLPolicy) Duration(obj metav1.Object) time.Duration {
+	ttl, _ := p.RecordTTL(obj)
+	return time.Duration(ttl) * time.Second
+}
+
+// WorkloadDuration returns the TTL the deletion of a migrated workload, e.g. a Deployment, is delayed by, i.e. the
+// highest effective TTL of the DNS records of the traffic objects of its namespace. The maximum TTL of the policy is
+// used when the TTL of the records is unknown, or the TTL of the workspace of the workload when the TTL is not bounded.
+func (p *TTLPolicy) WorkloadDuration(obj metav1.Object, dnsRecords []*v1.DNSRecord) time.Duration {
+	var ttl v1.TTL
+	for _, dnsRecord := range dnsRecords {
+		if recordTTL := effectiveRecordTTL(dnsRecord); recordTTL > ttl {
+			ttl = recordTTL
+		}
+	}
+	if ttl == 0 {
+		if p == nil || p.Max == 0 {
+			return p.Duration(obj)
+		}
+		ttl = p.Max
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// effectiveRecordTTL returns the effective TTL of the DNS record, rather than the migration TTL it may have been
+// lowered to, or the highest TTL of its endpoints for the records whose effective TTL has not been recorded.
+func effectiveRecordTTL(dnsRecord *v1.DNSRecord) v1.TTL {
+	if ttl, err := strconv.ParseInt(metadata.GetAnnotation(dnsRecord, ANNOTATION_DNS_RECORD_EFFECTIVE_TTL), 10, 64); err == nil && ttl > 0 {
+		return v1.TTL(ttl)
+	}
+	var ttl v1.TTL
+	for _, endpoint := range dnsRecord.Spec.Endpoints {
+		if endpoint.RecordTTL > ttl {
+			ttl = endpoint.RecordTTL
+		}
+	}
+	return ttl
+}
+
+// ParseWorkspaceTTLs returns the TTLs of the comma separated list of workspace=seconds pairs, indexed by workspace.
+func ParseWorkspaceTTLs(value string) (map[logicalcluster.Name]v1.TTL, error) {
+	ttls := map[logicalcluster.Name]v1.TTL{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid workspace TTL %q, expected workspace=seconds", pair)
+		}
+		ttl, err := strconv.ParseInt(strings.TrimSpace(pair[i+1:]), 10, 64)
+		if err != nil || ttl < 1 {
+			return nil, fmt.Errorf("invalid workspace TTL %q, expected workspace=seconds", pair)
+		}
+		ttls[logicalcluster.New(strings.TrimSpace(pair[:i]))] = v1.TTL(ttl)
+	}
+	return ttls, nil
+}
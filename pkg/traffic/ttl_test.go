@@ -0,0 +1,259 @@
+package traffic
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kcp-dev/logicalcluster/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+)
+
+func TestTTLPolicyRecordTTL(t *testing.T) {
+	policy := &TTLPolicy{
+		Default:    60,
+		Workspaces: map[logicalcluster.Name]v1.TTL{logicalcluster.New("root:org:slow"): 300},
+		Min:        10,
+		Max:        600,
+	}
+	object := func(workspace, ttl string) metav1.Object {
+		annotations := map[string]string{logicalcluster.AnnotationKey: workspace}
+		if ttl != "" {
+			annotations[ANNOTATION_DNS_RECORD_TTL] = ttl
+		}
+		return &metav1.ObjectMeta{Annotations: annotations}
+	}
+
+	cases := []struct {
+		name        string
+		object      metav1.Object
+		expected    v1.TTL
+		expectedErr bool
+	}{
+		{name: "default", object: object("root:org:fast", ""), expected: 60},
+		{name: "workspace", object: object("root:org:slow", ""), expected: 300},
+		{name: "annotation", object: object("root:org:slow", "30"), expected: 30},
+		{name: "below minimum", object: object("root:org:fast", "1"), expected: 10},
+		{name: "above maximum", object: object("root:org:fast", "86400"), expected: 600},
+		{name: "invalid annotation", object: object("root:org:slow", "5m"), expected: 300, expectedErr: true},
+		{name: "negative annotation", object: object("root:org:fast", "-1"), expected: 60, expectedErr: true},
+		{name: "zero annotation", object: object("root:org:slow", "0"), expected: 300, expectedErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ttl, err := policy.RecordTTL(tc.object)
+			if ttl != tc.expected {
+				t.Errorf("expected TTL %d but got %d", tc.expected, ttl)
+			}
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("expected error %t but got %v", tc.expectedErr, err)
+			}
+		})
+	}
+
+	var nilPolicy *TTLPolicy
+	if duration := nilPolicy.Duration(object("root:org:fast", "300")); duration != time.Minute {
+		t.Errorf("expected default TTL for nil policy but got %s", duration)
+	}
+}
+
+func TestTTLPolicyAdaptRecordTTL(t *testing.T) {
+	policy := &TTLPolicy{Min: 10, Max: 600, Migration: 10, StablePeriod: 10 * time.Minute}
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	targets := func(clusters ...string) []dns.Target {
+		var targets []dns.Target
+		for _, cluster := range clusters {
+			targets = append(targets, dns.Target{Cluster: cluster})
+		}
+		return targets
+	}
+	record := func(clusters string, changedAt time.Time) *v1.DNSRecord {
+		record := &v1.DNSRecord{}
+		if clusters != "" {
+			record.Annotations = map[string]string{
+				ANNOTATION_DNS_TARGETS:            clusters,
+				ANNOTATION_DNS_TARGETS_CHANGED_AT: changedAt.Format(time.RFC3339),
+			}
+		}
+		return record
+	}
+
+	cases := []struct {
+		name             string
+		policy           *TTLPolicy
+		record           *v1.DNSRecord
+		targets          []dns.Target
+		deleting         bool
+		expectedTTL      v1.TTL
+		expectedAfter    time.Duration
+		expectedPhase    string
+		expectedTargets  string
+		expectedChangeAt string
+	}{
+		{
+			name:            "new record",
+			policy:          policy,
+			record:          record("", time.Time{}),
+			targets:         targets("c2", "c1"),
+			expectedTTL:     300,
+			expectedPhase:   TTLPhaseStable,
+			expectedTargets: "c1,c2",
+		},
+		{
+			name:             "targets changed",
+			policy:           policy,
+			record:           record("c1", now.Add(-time.Hour)),
+			targets:          targets("c1", "c2"),
+			expectedTTL:      10,
+			expectedAfter:    10 * time.Minute,
+			expectedPhase:    TTLPhaseMigrating,
+			expectedTargets:  "c1,c2",
+			expectedChangeAt: now.Format(time.RFC3339),
+		},
+		{
+			name:             "targets changed recently",
+			policy:           policy,
+			record:           record("c1,c2", now.Add(-4*time.Minute)),
+			targets:          targets("c1", "c2"),
+			expectedTTL:      10,
+			expectedAfter:    6 * time.Minute,
+			expectedPhase:    TTLPhaseMigrating,
+			expectedTargets:  "c1,c2",
+			expectedChangeAt: now.Add(-4 * time.Minute).Format(time.RFC3339),
+		},
+		{
+			name:             "targets stable",
+			policy:           policy,
+			record:           record("c1,c2", now.Add(-10*time.Minute)),
+			targets:          targets("c1", "c2"),
+			expectedTTL:      300,
+			expectedPhase:    TTLPhaseStable,
+			expectedTargets:  "c1,c2",
+			expectedChangeAt: now.Add(-10 * time.Minute).Format(time.RFC3339),
+		},
+		{
+			name:             "target deleting",
+			policy:           policy,
+			record:           record("c1,c2", now.Add(-time.Hour)),
+			targets:          targets("c1", "c2"),
+			deleting:         true,
+			expectedTTL:      10,
+			expectedAfter:    10 * time.Minute,
+			expectedPhase:    TTLPhaseMigrating,
+			expectedTargets:  "c1,c2",
+			expectedChangeAt: now.Format(time.RFC3339),
+		},
+		{
+			name:            "target deleting without stable period",
+			policy:          &TTLPolicy{Migration: 10},
+			record:          record("", time.Time{}),
+			targets:         targets("c1"),
+			deleting:        true,
+			expectedTTL:     10,
+			expectedPhase:   TTLPhaseMigrating,
+			expectedTargets: "c1",
+		},
+		{
+			name:        "disabled",
+			policy:      &TTLPolicy{},
+			record:      record("c1", now),
+			targets:     targets("c1", "c2"),
+			deleting:    true,
+			expectedTTL: 300,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ttl, after := tc.policy.AdaptRecordTTL(tc.record, 300, tc.targets, tc.deleting, now)
+			if ttl != tc.expectedTTL {
+				t.Errorf("expected TTL %d but got %d", tc.expectedTTL, ttl)
+			}
+			if after != tc.expectedAfter {
+				t.Errorf("expected TTL to be stepped up after %s but got %s", tc.expectedAfter, after)
+			}
+			annotations := tc.record.GetAnnotations()
+			if phase := annotations[ANNOTATION_DNS_RECORD_TTL_PHASE]; phase != tc.expectedPhase {
+				t.Errorf("expected TTL phase %q but got %q", tc.expectedPhase, phase)
+			}
+			if clusters := annotations[ANNOTATION_DNS_TARGETS]; clusters != tc.expectedTargets {
+				t.Errorf("expected targets %q but got %q", tc.expectedTargets, clusters)
+			}
+			if changedAt := annotations[ANNOTATION_DNS_TARGETS_CHANGED_AT]; changedAt != tc.expectedChangeAt {
+				t.Errorf("expected targets changed at %q but got %q", tc.expectedChangeAt, changedAt)
+			}
+		})
+	}
+}
+
+func TestTTLPolicyWorkloadDuration(t *testing.T) {
+	policy := &TTLPolicy{Default: 60, Min: 10, Max: 600, Migration: 10}
+	deployment := &metav1.ObjectMeta{Annotations: map[string]string{logicalcluster.AnnotationKey: "root:org:ws"}}
+	dnsRecord := func(effectiveTTL string, endpointTTLs ...v1.TTL) *v1.DNSRecord {
+		dnsRecord := &v1.DNSRecord{}
+		if effectiveTTL != "" {
+			dnsRecord.Annotations = map[string]string{ANNOTATION_DNS_RECORD_EFFECTIVE_TTL: effectiveTTL}
+		}
+		for _, ttl := range endpointTTLs {
+			dnsRecord.Spec.Endpoints = append(dnsRecord.Spec.Endpoints, &v1.Endpoint{RecordTTL: ttl})
+		}
+		return dnsRecord
+	}
+
+	cases := []struct {
+		name       string
+		policy     *TTLPolicy
+		dnsRecords []*v1.DNSRecord
+		expected   time.Duration
+	}{
+		{name: "effective TTL of the records lowered during the migration", policy: policy, dnsRecords: []*v1.DNSRecord{dnsRecord("300", 10)}, expected: 5 * time.Minute},
+		{name: "highest TTL of the records of the namespace", policy: policy, dnsRecords: []*v1.DNSRecord{dnsRecord("300", 10), dnsRecord("30", 30)}, expected: 5 * time.Minute},
+		{name: "TTL of the endpoints without effective TTL", policy: policy, dnsRecords: []*v1.DNSRecord{dnsRecord("", 30, 120)}, expected: 2 * time.Minute},
+		{name: "maximum TTL without records", policy: policy, expected: 10 * time.Minute},
+		{name: "workspace TTL without records nor maximum TTL", policy: &TTLPolicy{Default: 60}, expected: time.Minute},
+		{name: "nil policy", expected: time.Minute},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if duration := tc.policy.WorkloadDuration(deployment, tc.dnsRecords); duration != tc.expected {
+				t.Errorf("expected duration %s but got %s", tc.expected, duration)
+			}
+		})
+	}
+}
+
+func TestTTLPolicyValidate(t *testing.T) {
+	if err := (&TTLPolicy{Default: 60, Min: 10, Max: 3600}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (&TTLPolicy{Default: 60, Min: 10}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (&TTLPolicy{Default: 60, Min: 600, Max: 300}).Validate(); err == nil {
+		t.Errorf("expected error for minimum TTL greater than maximum TTL")
+	}
+	if err := (&TTLPolicy{Default: 0, Min: 10}).Validate(); err == nil {
+		t.Errorf("expected error for zero default TTL")
+	}
+}
+
+func TestParseWorkspaceTTLs(t *testing.T) {
+	ttls, err := ParseWorkspaceTTLs(" root:org:slow=300, ,root:org:fast = 10")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[logicalcluster.Name]v1.TTL{
+		logicalcluster.New("root:org:slow"): 300,
+		logicalcluster.New("root:org:fast"): 10,
+	}
+	if !reflect.DeepEqual(ttls, expected) {
+		t.Errorf("expected TTLs %v but got %v", expected, ttls)
+	}
+	for _, value := range []string{"root:org", "=300", "root:org=5m", "root:org=-1", "root:org=0"} {
+		if _, err := ParseWorkspaceTTLs(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
@@ -87,6 +87,15 @@ var (
 		},
 	)
 
+	// DNSRecordUpdateBlockedTotal is a prometheus counter metrics which holds the total
+	// number of DNS record updates refused by the endpoint safeguard.
+	DNSRecordUpdateBlockedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "glbc_dns_record_update_blocked_total",
+			Help: "GLBC total number of DNS record updates refused for removing too many targets",
+		},
+	)
+
 	// TlsCertificateRequestErrors is a prometheus counter metrics which holds the total
 	// number of failed TLS certificate requests.
 	TlsCertificateRequestErrors = prometheus.NewCounterVec(
@@ -109,6 +118,7 @@ func init() {
 		TlsCertificateRequestErrors,
 		TlsCertificateRequestTotal,
 		TlsCertificateIssuanceDuration,
+		DNSRecordUpdateBlockedTotal,
 	)
 }
 